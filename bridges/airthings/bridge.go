@@ -78,9 +78,6 @@ func NewAirthingsBridge(logger *zap.Logger, svc *bridge.Service, s *airthings.Se
 				},
 			},
 		},
-		State: &api2.Bridge_State{
-			IsPaired: true,
-		},
 	}
 
 	cb := &AirthingsBridge{
@@ -107,9 +104,7 @@ func (ab *AirthingsBridge) SetBridgeConfig(ctx context.Context, config bridge.Co
 
 	viper.Set("bridge.name", config.Name)
 	viper.Set("bridge.description", config.Description)
-	viper.WriteConfig()
-
-	return nil
+	return viper.WriteConfig()
 }
 
 // Refresh is present to conform to the bridge.Handler interface. In this implementation it queries
@@ -126,20 +121,18 @@ func (ab *AirthingsBridge) Refresh(ctx context.Context) error {
 	return nil
 }
 
-// Run begins the process of polling the sensor and reporting back the state.
-func (ab *AirthingsBridge) Run() {
+// Run begins the process of polling the sensor and reporting back the state, until the context is cancelled.
+func (ab *AirthingsBridge) Run(ctx context.Context) {
 	refreshTimer := time.NewTicker(time.Minute * 5)
+	defer refreshTimer.Stop()
+
 	for {
 		select {
 		case <-refreshTimer.C:
-			err := ab.s.Refresh()
-			if err != nil {
-				ab.logger.Error("unable to refresh sensor",
-					zap.Error(err))
-				continue
-			}
-
-			ab.svc.UpdateDevice(sensorToDevice(ab.s))
+			// Failures are logged by Refresh and reported as the health of the bridge.
+			ab.svc.Refresh(ctx)
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"go.uber.org/zap"
 
@@ -25,6 +28,7 @@ func main() {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("$HOME/.config/house")
 	viper.AddConfigPath(".")
+	viper.SetDefault("pairing.file", "$HOME/.config/house/airthings-pairing.json")
 
 	if err := viper.ReadInConfig(); err != nil {
 		logger.Fatal("unable to read config", zap.Error(err))
@@ -44,6 +48,9 @@ func main() {
 		}
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	svc := bridge.NewService(logger)
 
 	sensorID := viper.GetInt("sensor.id")
@@ -81,8 +88,19 @@ func main() {
 	svc.UpdateDevice(sensorToDevice(sensor))
 
 	// Check for updates periodically
-	go cb.Run()
+	go cb.Run(ctx)
+
+	var serverConfig bridge.ServerConfig
+	if err := viper.Unmarshal(&serverConfig); err != nil {
+		logger.Fatal("unable to parse 'tls' and 'auth' keys from config", zap.Error(err))
+	}
+	serverOpts, err := bridge.ServerOptions(logger, svc, serverConfig)
+	if err != nil {
+		logger.Fatal("unable to configure server", zap.Error(err))
+	}
 
-	s := bridge.NewServer(logger, svc)
-	s.Serve()
+	s := bridge.NewServer(logger, svc, serverOpts...)
+	if err := s.Serve(ctx); err != nil {
+		logger.Fatal("unable to serve", zap.Error(err))
+	}
 }
@@ -78,9 +78,6 @@ func NewAPCUPSBridge(logger *zap.Logger, svc *bridge.Service, client *apcupsd.Cl
 				},
 			},
 		},
-		State: &api2.Bridge_State{
-			IsPaired: true,
-		},
 	}
 	return &APCUPSBridge{
 		logger: logger,
@@ -104,9 +101,7 @@ func (ab *APCUPSBridge) SetBridgeConfig(ctx context.Context, config bridge.Confi
 
 	viper.Set("bridge.name", config.Name)
 	viper.Set("bridge.description", config.Description)
-	viper.WriteConfig()
-
-	return nil
+	return viper.WriteConfig()
 }
 
 // Refresh is present to conform to the bridge.Handler interface. In this implementation it queries
@@ -123,20 +118,17 @@ func (ab *APCUPSBridge) Refresh(ctx context.Context) error {
 	return nil
 }
 
-// Run begins the process of polling the sensor and reporting back the state.
-func (ab *APCUPSBridge) Run() {
+// Run begins the process of polling the UPS and reporting back the state, until the context is cancelled.
+func (ab *APCUPSBridge) Run(ctx context.Context) {
 	refreshTimer := time.NewTicker(time.Minute * 5)
+	defer refreshTimer.Stop()
+
 	for {
 		select {
 		case <-refreshTimer.C:
-			status, err := ab.client.Status()
-			if err != nil {
-				ab.logger.Error("unable to get status from ups",
-					zap.Error(err))
-				continue
-			}
-
-			ab.svc.UpdateDevice(statusToDevice(status))
+			ab.svc.Refresh(ctx)
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"go.uber.org/zap"
 
@@ -22,6 +26,7 @@ func main() {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("$HOME/.config/house")
 	viper.AddConfigPath(".")
+	viper.SetDefault("pairing.file", "$HOME/.config/house/apc-ups-pairing.json")
 
 	if err := viper.ReadInConfig(); err != nil {
 		logger.Fatal("unable to read config", zap.Error(err))
@@ -41,6 +46,9 @@ func main() {
 		}
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	svc := bridge.NewService(logger)
 
 	ipAddr := viper.GetString("ups.ip")
@@ -70,8 +78,19 @@ func main() {
 	svc.UpdateDevice(statusToDevice(status))
 
 	// Check for updates periodically
-	go upsb.Run()
+	go upsb.Run(ctx)
+
+	var serverConfig bridge.ServerConfig
+	if err := viper.Unmarshal(&serverConfig); err != nil {
+		logger.Fatal("unable to parse 'tls' and 'auth' keys from config", zap.Error(err))
+	}
+	serverOpts, err := bridge.ServerOptions(logger, svc, serverConfig)
+	if err != nil {
+		logger.Fatal("unable to configure server", zap.Error(err))
+	}
 
-	s := bridge.NewServer(logger, svc)
-	s.Serve()
+	s := bridge.NewServer(logger, svc, serverOpts...)
+	if err := s.Serve(ctx); err != nil {
+		logger.Fatal("unable to serve", zap.Error(err))
+	}
 }
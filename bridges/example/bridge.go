@@ -104,9 +104,6 @@ func NewExampleBridge(logger *zap.Logger, svc *bridge.Service) *ExampleBridge {
 		Config: &api2.Bridge_Config{
 			Timezone: time.Local.String(),
 		},
-		State: &api2.Bridge_State{
-			IsPaired: true,
-		},
 	}
 
 	return &ExampleBridge{
@@ -169,14 +166,19 @@ func (b *ExampleBridge) SetBridgeConfig(ctx context.Context, config bridge.Confi
 // Run begins processing async updates - instead of interfacing with real-world devices
 // instead the SIGUSR1 and SIGUSR2 signals are listened as triggers for state changes.
 // This also starts a timer which updates the lux of the second device every 30 seconds.
-func (b *ExampleBridge) Run() {
+// It runs until the context is cancelled.
+func (b *ExampleBridge) Run(ctx context.Context) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(sigChan)
 
 	devTimer := time.NewTicker(30 * time.Second)
+	defer devTimer.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case sig := <-sigChan:
 			b.logger.Debug("received signal", zap.String("value", sig.String()))
 			if sig == syscall.SIGUSR1 {
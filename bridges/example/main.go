@@ -1,6 +1,10 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"go.uber.org/zap"
@@ -14,6 +18,9 @@ func main() {
 		panic(err)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	svc := bridge.NewService(logger)
 
 	eb := NewExampleBridge(logger, svc)
@@ -29,8 +36,10 @@ func main() {
 		svc.UpdateDevice(eb.d2.toDevice())
 	}()
 
-	go eb.Run()
+	go eb.Run(ctx)
 
 	s := bridge.NewServer(logger, svc)
-	s.Serve()
+	if err := s.Serve(ctx); err != nil {
+		logger.Fatal("unable to serve", zap.Error(err))
+	}
 }
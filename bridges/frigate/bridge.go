@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/spf13/viper"
@@ -40,11 +41,17 @@ type FrigateBridge struct {
 	client                 *frigate.Client
 	cameraRestreamHostname string
 
-	cameras map[string]*Camera
+	camerasLock sync.Mutex
+	cameras     map[string]*Camera
+	// eventsRefreshedAt is when the events were last retrieved, so only newer events need to be retrieved next time.
+	eventsRefreshedAt time.Time
+
+	snapshotConfig SnapshotConfig
+	snapshots      *snapshotCache
 }
 
 // NewFrigateBridge returns a new instance of the Frigate bridge.
-func NewFrigateBridge(logger *zap.Logger, svc *bridge.Service, client *frigate.Client, cameraRestreamHostname string) *FrigateBridge {
+func NewFrigateBridge(logger *zap.Logger, svc *bridge.Service, client *frigate.Client, cameraRestreamHostname string, snapshotConfig SnapshotConfig) *FrigateBridge {
 	b := &api2.Bridge{
 		Id:           viper.GetString("bridge.id"),
 		IsReachable:  true,
@@ -60,9 +67,6 @@ func NewFrigateBridge(logger *zap.Logger, svc *bridge.Service, client *frigate.C
 				},
 			},
 		},
-		State: &api2.Bridge_State{
-			IsPaired: true,
-		},
 	}
 	return &FrigateBridge{
 		logger:                 logger,
@@ -71,14 +75,41 @@ func NewFrigateBridge(logger *zap.Logger, svc *bridge.Service, client *frigate.C
 		client:                 client,
 		cameraRestreamHostname: cameraRestreamHostname,
 		cameras:                map[string]*Camera{},
+		snapshotConfig:         snapshotConfig,
+		snapshots:              newSnapshotCache(snapshotConfig.CacheFor),
 	}
 }
 
 // ProcessCommand takes a given command request and attempts to execute it.
 // We only worry about processing valid commands for the given device traits.
 func (fb *FrigateBridge) ProcessCommand(ctx context.Context, cmd *command.Command) (*device.Device, error) {
-	fb.logger.Error("received unsupported command - shouldn't happen")
-	return nil, bridge.ErrUnsupportedCommand
+	fb.camerasLock.Lock()
+	defer fb.camerasLock.Unlock()
+
+	camera := fb.cameraWithID(cmd.DeviceId)
+	if camera == nil {
+		return nil, bridge.ErrDeviceNotFound
+	}
+
+	// Cameras support the CameraDetection and CameraRecording commands.
+	if cmd.GetCameraDetection() != nil {
+		if err := fb.client.SetCameraDetection(ctx, camera.Name, cmd.GetCameraDetection().On); err != nil {
+			fb.logger.Error("unable to set camera detection", zap.String("camera", camera.Name), zap.Error(err))
+			return nil, status.Error(codes.Internal, "unable to set camera detection")
+		}
+		camera.DetectionEnabled = cmd.GetCameraDetection().On
+	} else if cmd.GetCameraRecording() != nil {
+		if err := fb.client.SetCameraRecording(ctx, camera.Name, cmd.GetCameraRecording().On); err != nil {
+			fb.logger.Error("unable to set camera recording", zap.String("camera", camera.Name), zap.Error(err))
+			return nil, status.Error(codes.Internal, "unable to set camera recording")
+		}
+		camera.RecordingEnabled = cmd.GetCameraRecording().On
+	} else {
+		fb.logger.Error("received unsupported command - shouldn't happen")
+		return nil, bridge.ErrUnsupportedCommand
+	}
+
+	return camera.ToDevice(), nil
 }
 
 // SetBridgeConfig takes the supplied config params and saves them for future reference.
@@ -88,13 +119,14 @@ func (fb *FrigateBridge) SetBridgeConfig(ctx context.Context, config bridge.Conf
 
 	viper.Set("bridge.name", config.Name)
 	viper.Set("bridge.description", config.Description)
-	viper.WriteConfig()
-
-	return nil
+	return viper.WriteConfig()
 }
 
 // Setup loads the configured cameras into the bridge for use. It then retrieves initial state and errors if it can't reach the Frigate API.
 func (fb *FrigateBridge) Setup(ctx context.Context, cameras []CameraConfig) error {
+	fb.camerasLock.Lock()
+	defer fb.camerasLock.Unlock()
+
 	for _, camera := range cameras {
 		fb.cameras[camera.Name] = fb.newCamera(camera)
 	}
@@ -121,11 +153,12 @@ func (fb *FrigateBridge) Setup(ctx context.Context, cameras []CameraConfig) erro
 
 		if camera, cameraPresent := fb.cameras[cameraName]; cameraPresent {
 			camera.Enabled = frigateCameraConfig.Enabled
+			camera.DetectionEnabled = frigateCameraConfig.Detect.Enabled
+			camera.RecordingEnabled = frigateCameraConfig.Record.Enabled
 			camera.Endpoint = ep
 
 			if cameraStats, statsPresent := stats.Cameras[cameraName]; statsPresent {
 				camera.Active = (cameraStats.CameraFPS > 0)
-				camera.LastActivity = time.Now() // TODO: use the 'events' feed for this
 			}
 
 			fb.cameras[cameraName] = camera
@@ -134,11 +167,12 @@ func (fb *FrigateBridge) Setup(ctx context.Context, cameras []CameraConfig) erro
 			// In this case we haven't gotten an initial config for this camera but we can mark the Model and Manufacturer as unknown
 			camera := fb.newCamera(CameraConfig{Name: frigateCameraConfig.Name, Manufacturer: "Unknown", ModelID: "Unknown"})
 			camera.Enabled = frigateCameraConfig.Enabled
+			camera.DetectionEnabled = frigateCameraConfig.Detect.Enabled
+			camera.RecordingEnabled = frigateCameraConfig.Record.Enabled
 			camera.Endpoint = ep
 
 			if cameraStats, statsPresent := stats.Cameras[cameraName]; statsPresent {
 				camera.Active = (cameraStats.CameraFPS > 0)
-				camera.LastActivity = time.Now() // TODO: use the 'events' feed for this
 			}
 
 			fb.cameras[cameraName] = camera
@@ -151,6 +185,16 @@ func (fb *FrigateBridge) Setup(ctx context.Context, cameras []CameraConfig) erro
 	return nil
 }
 
+// cameraWithID returns the camera with the supplied device ID, or nil if there isn't one. The camera lock must be held.
+func (fb *FrigateBridge) cameraWithID(id string) *Camera {
+	for _, camera := range fb.cameras {
+		if camera.ID == id {
+			return camera
+		}
+	}
+	return nil
+}
+
 func (fb *FrigateBridge) newCamera(config CameraConfig) *Camera {
 	idBytes := sha256.Sum256([]byte(fmt.Sprintf("%s:%s", fb.client.GetIP(), config.Name)))
 
@@ -165,45 +209,68 @@ func (fb *FrigateBridge) newCamera(config CameraConfig) *Camera {
 }
 
 // Refresh is present to conform to the bridge.Handler interface. In this implementation it queries
-// the Frigate API and returns the current state of the cameras.
+// the Frigate API and returns the current state of the cameras, including whether any objects are being tracked.
+// Recording is only reported in the Frigate config, so it is retrieved as well to pick up changes made in Frigate.
 func (fb *FrigateBridge) Refresh(ctx context.Context) error {
+	fb.camerasLock.Lock()
+	defer fb.camerasLock.Unlock()
+
+	config, err := fb.client.GetConfig(ctx)
+	if err != nil {
+		fb.logger.Error("unable to get config from frigate",
+			zap.Error(err))
+		return status.Error(codes.Internal, "unable to get config from frigate")
+	}
 	stats, err := fb.client.GetStats(ctx)
 	if err != nil {
 		fb.logger.Error("unable to get stats from frigate",
 			zap.Error(err))
 		return status.Error(codes.Internal, "unable to get stats from frigate")
 	}
+	if err := fb.refreshEvents(ctx); err != nil {
+		return err
+	}
 
 	for cameraName, camera := range fb.cameras {
+		if camera.Endpoint == nil {
+			continue
+		}
+		if frigateCameraConfig, configPresent := config.Cameras[cameraName]; configPresent {
+			camera.Enabled = frigateCameraConfig.Enabled
+			camera.RecordingEnabled = frigateCameraConfig.Record.Enabled
+		}
 		if cameraStats, statsPresent := stats.Cameras[cameraName]; statsPresent {
 			camera.Active = (cameraStats.CameraFPS > 0)
-			camera.LastActivity = time.Now() // TODO: use the 'events' feed for this
-			fb.cameras[cameraName] = camera
-			fb.svc.UpdateDevice(camera.ToDevice())
+			camera.DetectionEnabled = cameraStats.DetectionEnabled
 		}
+		fb.svc.UpdateDevice(camera.ToDevice())
 	}
 	return nil
 }
 
-// Run begins the process of polling the sensor and reporting back the state.
+// Run begins the process of polling the sensor and reporting back the state, until the context is cancelled.
 func (fb *FrigateBridge) Run(ctx context.Context) {
-	err := fb.Refresh(ctx)
+	err := fb.svc.Refresh(ctx)
 	if err != nil {
 		fb.logger.Error("unable to refresh bridges", zap.Error(err))
 		return
 	}
 
 	refreshTimer := time.NewTicker(time.Minute * 1)
+	defer refreshTimer.Stop()
+
 	for {
 		select {
 		case <-refreshTimer.C:
-			err := fb.Refresh(ctx)
+			err := fb.svc.Refresh(ctx)
 			if err != nil {
 				fb.logger.Error("unable to get cameras from frigate",
 					zap.Error(err))
 			} else {
 				fb.logger.Debug("refreshed")
 			}
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/api/command"
+	"github.com/rmrobinson/house/bridges/frigate/frigate"
+	"github.com/rmrobinson/house/service/bridge"
+)
+
+// newFixtureServer serves the recorded Frigate API responses in testdata.
+func newFixtureServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(fixtureHandler(t))
+}
+
+// fixtureHandler serves the recorded Frigate API responses in testdata.
+func fixtureHandler(t *testing.T) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fixture := ""
+		switch r.URL.Path {
+		case "/api/front/latest.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			fmt.Fprintf(w, "latest h=%s quality=%s", r.URL.Query().Get("h"), r.URL.Query().Get("quality"))
+			return
+		case "/api/events/1718034990.119467-9kq2rd/clip.mp4":
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Write([]byte("clip"))
+			return
+		case "/api/events/1718034990.119467-9kq2rd":
+			fixture = "testdata/event.json"
+		case "/api/config":
+			fixture = "testdata/config.json"
+		case "/api/stats":
+			fixture = "testdata/stats.json"
+		case "/api/events":
+			fixture = "testdata/events.json"
+			if r.URL.Query().Get("in_progress") == "1" {
+				fixture = "testdata/events_in_progress.json"
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		contents, err := os.ReadFile(fixture)
+		require.NoError(t, err)
+		if camera := r.URL.Query().Get("cameras"); len(camera) > 0 {
+			contents = filterEvents(t, contents, camera)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(contents)
+	})
+}
+
+// configServer serves the recorded Frigate API responses, with the camera settings changed through the config API
+// applied to the config and stats.
+type configServer struct {
+	t *testing.T
+
+	lock sync.Mutex
+	// settings holds the values set for the config keys, i.e. "cameras.front.record.enabled".
+	settings map[string]bool
+	// fail causes config changes to be rejected.
+	fail bool
+}
+
+func (cs *configServer) set(key string, enabled bool) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	cs.settings[key] = enabled
+}
+
+func (cs *configServer) get(key string) (bool, bool) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	enabled, found := cs.settings[key]
+	return enabled, found
+}
+
+func (cs *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/config/set":
+		cs.lock.Lock()
+		defer cs.lock.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if cs.fail {
+			fmt.Fprint(w, `{"success": false, "message": "unable to save config"}`)
+			return
+		}
+		for key, values := range r.URL.Query() {
+			cs.settings[key] = values[0] == "True"
+		}
+		fmt.Fprint(w, `{"success": true, "message": "Config successfully updated"}`)
+	case "/api/config":
+		var config map[string]map[string]map[string]any
+		cs.readFixture("testdata/config.json", &config)
+		for name, camera := range config["cameras"] {
+			for _, setting := range []string{"detect", "record"} {
+				if enabled, found := cs.get(fmt.Sprintf("cameras.%s.%s.enabled", name, setting)); found {
+					camera[setting] = map[string]bool{"enabled": enabled}
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(config)
+	case "/api/stats":
+		var stats map[string]any
+		cs.readFixture("testdata/stats.json", &stats)
+		for name, camera := range stats["cameras"].(map[string]any) {
+			if enabled, found := cs.get(fmt.Sprintf("cameras.%s.detect.enabled", name)); found {
+				camera.(map[string]any)["detection_enabled"] = enabled
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	default:
+		fixtureHandler(cs.t)(w, r)
+	}
+}
+
+func (cs *configServer) readFixture(path string, v any) {
+	contents, err := os.ReadFile(path)
+	require.NoError(cs.t, err)
+	require.NoError(cs.t, json.Unmarshal(contents, v))
+}
+
+// newTestBridge sets up a bridge with the front and back cameras, served by a config server.
+func newTestBridge(t *testing.T) (*FrigateBridge, *configServer) {
+	cs := &configServer{t: t, settings: map[string]bool{}}
+	srv := httptest.NewServer(cs)
+	t.Cleanup(srv.Close)
+
+	logger := zaptest.NewLogger(t)
+	endpoint, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	svc := bridge.NewService(logger)
+	fb := NewFrigateBridge(logger, svc, frigate.NewClient(logger, srv.Client(), endpoint), "localhost", SnapshotConfig{})
+	svc.RegisterHandler(fb, fb.b)
+	require.NoError(t, fb.Setup(context.Background(), []CameraConfig{{Name: "front"}, {Name: "back"}}))
+	return fb, cs
+}
+
+func TestProcessCommand(t *testing.T) {
+	ctx := context.Background()
+	fb, cs := newTestBridge(t)
+	front := fb.cameras["front"].ID
+
+	d, err := fb.ProcessCommand(ctx, &command.Command{DeviceId: front, Details: &command.Command_CameraRecording{CameraRecording: &command.CameraRecording{On: true}}})
+	require.NoError(t, err)
+	assert.True(t, d.GetCamera().GetRecording().GetState().IsOn)
+	enabled, found := cs.get("cameras.front.record.enabled")
+	assert.True(t, found && enabled)
+
+	d, err = fb.ProcessCommand(ctx, &command.Command{DeviceId: front, Details: &command.Command_CameraDetection{CameraDetection: &command.CameraDetection{On: false}}})
+	require.NoError(t, err)
+	assert.False(t, d.GetCamera().GetDetection().GetState().IsOn)
+	assert.True(t, d.GetCamera().GetRecording().GetState().IsOn)
+	enabled, found = cs.get("cameras.front.detect.enabled")
+	assert.True(t, found && !enabled)
+
+	_, err = fb.ProcessCommand(ctx, &command.Command{DeviceId: "missing", Details: &command.Command_CameraDetection{CameraDetection: &command.CameraDetection{On: true}}})
+	assert.Equal(t, bridge.ErrDeviceNotFound, err)
+	_, err = fb.ProcessCommand(ctx, &command.Command{DeviceId: front, Details: &command.Command_OnOff{OnOff: &command.OnOff{On: true}}})
+	assert.Equal(t, bridge.ErrUnsupportedCommand, err)
+
+	// The camera is left unchanged if Frigate doesn't save the change.
+	cs.lock.Lock()
+	cs.fail = true
+	cs.lock.Unlock()
+	_, err = fb.ProcessCommand(ctx, &command.Command{DeviceId: front, Details: &command.Command_CameraRecording{CameraRecording: &command.CameraRecording{On: false}}})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.True(t, fb.cameras["front"].RecordingEnabled)
+}
+
+func TestExecuteCameraCommand(t *testing.T) {
+	ctx := context.Background()
+	fb, cs := newTestBridge(t)
+	api := fb.svc.API()
+	back := fb.cameras["back"].ID
+
+	d, err := api.ExecuteCommand(ctx, &command.Command{DeviceId: back, Details: &command.Command_CameraRecording{CameraRecording: &command.CameraRecording{On: true}}})
+	require.NoError(t, err)
+	assert.True(t, d.GetCamera().GetRecording().GetState().IsOn)
+
+	d, err = api.ExecuteCommand(ctx, &command.Command{DeviceId: back, Details: &command.Command_CameraDetection{CameraDetection: &command.CameraDetection{On: false}}})
+	require.NoError(t, err)
+	assert.False(t, d.GetCamera().GetDetection().GetState().IsOn)
+
+	// The changes are published, so the bridge reports them to later callers.
+	stored, err := api.GetDevice(ctx, &api2.GetDeviceRequest{Id: back})
+	require.NoError(t, err)
+	assert.True(t, stored.GetCamera().GetRecording().GetState().IsOn)
+	assert.False(t, stored.GetCamera().GetDetection().GetState().IsOn)
+
+	// Commands cameras don't support aren't passed to the handler.
+	_, err = api.ExecuteCommand(ctx, &command.Command{DeviceId: back, Details: &command.Command_OnOff{OnOff: &command.OnOff{On: true}}})
+	assert.Equal(t, bridge.ErrCommandNotSupported, err)
+	_, err = api.ExecuteCommand(ctx, &command.Command{DeviceId: "missing", Details: &command.Command_CameraRecording{CameraRecording: &command.CameraRecording{On: true}}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	enabled, _ := cs.get("cameras.back.record.enabled")
+	assert.True(t, enabled)
+}
+
+func TestRefreshCameraSettings(t *testing.T) {
+	ctx := context.Background()
+	fb, cs := newTestBridge(t)
+	assert.False(t, fb.cameras["back"].RecordingEnabled)
+	assert.True(t, fb.cameras["back"].DetectionEnabled)
+
+	// Changes made in Frigate are picked up on the next refresh.
+	cs.set("cameras.back.record.enabled", true)
+	cs.set("cameras.back.detect.enabled", false)
+	require.NoError(t, fb.svc.Refresh(ctx))
+
+	d, err := fb.svc.API().GetDevice(ctx, &api2.GetDeviceRequest{Id: fb.cameras["back"].ID})
+	require.NoError(t, err)
+	assert.True(t, d.GetCamera().GetRecording().GetState().IsOn)
+	assert.False(t, d.GetCamera().GetDetection().GetState().IsOn)
+	assert.False(t, fb.cameras["front"].RecordingEnabled)
+}
+
+// filterEvents returns the recorded events which were seen by the specified camera.
+func filterEvents(t *testing.T, contents []byte, camera string) []byte {
+	var events []frigate.Event
+	require.NoError(t, json.Unmarshal(contents, &events))
+
+	filtered := []frigate.Event{}
+	for _, event := range events {
+		if event.Camera == camera {
+			filtered = append(filtered, event)
+		}
+	}
+	contents, err := json.Marshal(filtered)
+	require.NoError(t, err)
+	return contents
+}
+
+func TestRefreshEvents(t *testing.T) {
+	srv := newFixtureServer(t)
+	defer srv.Close()
+
+	logger := zaptest.NewLogger(t)
+	endpoint, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	fb := NewFrigateBridge(logger, bridge.NewService(logger), frigate.NewClient(logger, srv.Client(), endpoint), "localhost", SnapshotConfig{})
+
+	ctx := context.Background()
+	require.NoError(t, fb.Setup(ctx, []CameraConfig{{Name: "front"}, {Name: "back"}}))
+	require.NoError(t, fb.Refresh(ctx))
+
+	// A person is still being tracked by the front camera; the package doesn't indicate presence.
+	front := fb.cameras["front"].ToDevice()
+	presence := front.GetCamera().GetPresence().GetState()
+	assert.True(t, presence.MotionDetected)
+	assert.Equal(t, []string{"person"}, presence.Objects)
+	assert.Equal(t, []string{"porch"}, presence.Zones)
+	assert.WithinDuration(t, time.Now(), front.LastSeen.AsTime(), time.Minute)
+
+	// The back camera last saw a car; the later dog was a false positive.
+	back := fb.cameras["back"].ToDevice()
+	assert.False(t, back.GetCamera().GetPresence().GetState().MotionDetected)
+	assert.Empty(t, back.GetCamera().GetPresence().GetState().Objects)
+	assert.Equal(t, time.Unix(1718035011, 0), back.LastSeen.AsTime().Local())
+
+	// The person leaving is received over MQTT.
+	contents, err := os.ReadFile("testdata/event_end.json")
+	require.NoError(t, err)
+	update := frigate.EventUpdate{}
+	require.NoError(t, json.Unmarshal(contents, &update))
+	fb.handleEventUpdate(update)
+
+	presence = fb.cameras["front"].ToDevice().GetCamera().GetPresence().GetState()
+	assert.False(t, presence.MotionDetected)
+	assert.Empty(t, presence.Zones)
+}
+
+func TestCameraMedia(t *testing.T) {
+	srv := newFixtureServer(t)
+	defer srv.Close()
+
+	logger := zaptest.NewLogger(t)
+	endpoint, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	fb := NewFrigateBridge(logger, bridge.NewService(logger), frigate.NewClient(logger, srv.Client(), endpoint), "localhost",
+		SnapshotConfig{Height: 720, Quality: 70, CacheFor: time.Minute})
+
+	ctx := context.Background()
+	require.NoError(t, fb.Setup(ctx, []CameraConfig{{Name: "front"}, {Name: "back"}}))
+	front := fb.cameras["front"].ID
+	back := fb.cameras["back"].ID
+
+	// The configured size is used unless the request specifies its own, and the snapshot is reused while it is fresh.
+	image, err := fb.GetCameraSnapshot(ctx, &api2.GetCameraSnapshotRequest{DeviceId: front, Height: 360})
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", image.ContentType)
+	assert.Equal(t, "latest h=360 quality=70", string(image.Data))
+
+	cached, err := fb.GetCameraSnapshot(ctx, &api2.GetCameraSnapshotRequest{DeviceId: front, Height: 360})
+	require.NoError(t, err)
+	assert.Equal(t, image.TakenAt.AsTime(), cached.TakenAt.AsTime())
+
+	events, err := fb.ListCameraEvents(ctx, &api2.ListCameraEventsRequest{DeviceId: back})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "car", events[0].Label)
+	assert.Equal(t, back, events[0].DeviceId)
+	assert.NotNil(t, events[0].EndTime)
+
+	contentType, clip, err := fb.GetEventClip(ctx, &api2.GetEventClipRequest{DeviceId: back, EventId: events[0].Id})
+	require.NoError(t, err)
+	defer clip.Close()
+	contents, err := io.ReadAll(clip)
+	require.NoError(t, err)
+	assert.Equal(t, "video/mp4", contentType)
+	assert.Equal(t, "clip", string(contents))
+
+	// Events can only be retrieved through the camera which recorded them.
+	_, _, err = fb.GetEventClip(ctx, &api2.GetEventClipRequest{DeviceId: front, EventId: events[0].Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"net/url"
+	"sort"
 	"time"
 
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/rmrobinson/house/api/device"
 	"github.com/rmrobinson/house/api/trait"
+	"github.com/rmrobinson/house/bridges/frigate/frigate"
 )
 
 type Camera struct {
@@ -17,18 +19,44 @@ type Camera struct {
 	Name         string
 	Endpoint     *url.URL
 
-	Enabled        bool
-	Active         bool
-	MotionDetected bool
-	LastActivity   time.Time
+	Enabled bool
+	Active  bool
+	// DetectionEnabled and RecordingEnabled are whether Frigate is detecting objects in, and recording, the camera's view.
+	DetectionEnabled bool
+	RecordingEnabled bool
+	// LastActivity is when an object was last seen by the camera; it is zero if no events have been seen.
+	LastActivity time.Time
+
+	// events are the in-progress events indicating presence in the view of the camera, indexed by event ID.
+	events map[string]frigate.Event
+}
+
+// MotionDetected returns true if an object indicating presence is currently being tracked by the camera.
+func (c *Camera) MotionDetected() bool {
+	return len(c.events) > 0
+}
+
+// seenAt records activity by the camera at the supplied time, if it is later than the activity already seen.
+func (c *Camera) seenAt(t time.Time) {
+	if t.After(c.LastActivity) {
+		c.LastActivity = t
+	}
 }
 
 func (c *Camera) ToDevice() *device.Device {
-	return &device.Device{
+	objects := map[string]bool{}
+	zones := map[string]bool{}
+	for _, event := range c.events {
+		objects[event.Label] = true
+		for _, zone := range event.Zones {
+			zones[zone] = true
+		}
+	}
+
+	d := &device.Device{
 		Id:           c.ID,
 		ModelId:      c.ModelID,
 		Manufacturer: c.Manufacturer,
-		LastSeen:     timestamppb.New(c.LastActivity),
 		Address: &device.Device_Address{
 			Address:     c.Name,
 			IsReachable: c.Active,
@@ -41,9 +69,47 @@ func (c *Camera) ToDevice() *device.Device {
 					},
 				},
 				Presence: &trait.Presence{
-					State: &trait.Presence_State{},
+					State: &trait.Presence_State{
+						MotionDetected: c.MotionDetected(),
+						Objects:        sortedKeys(objects),
+						Zones:          sortedKeys(zones),
+					},
+				},
+				Detection: &trait.OnOff{
+					Attributes: &trait.OnOff_Attributes{
+						CanControl: true,
+					},
+					State: &trait.OnOff_State{
+						IsOn: c.DetectionEnabled,
+					},
+				},
+				Recording: &trait.OnOff{
+					Attributes: &trait.OnOff_Attributes{
+						CanControl: true,
+					},
+					State: &trait.OnOff_State{
+						IsOn: c.RecordingEnabled,
+					},
 				},
 			},
 		},
 	}
+	if !c.LastActivity.IsZero() {
+		d.LastSeen = timestamppb.New(c.LastActivity)
+	}
+	return d
+}
+
+// sortedKeys returns the keys of the supplied set in a stable order, so unchanged devices compare as equal.
+func sortedKeys(set map[string]bool) []string {
+	if len(set) < 1 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
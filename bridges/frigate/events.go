@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rmrobinson/house/bridges/frigate/frigate"
+)
+
+// maxEvents is the number of events requested from Frigate on each refresh.
+const maxEvents = 100
+
+// presenceLabels are the objects whose detection indicates presence in the view of a camera: people, vehicles and animals.
+// Other objects Frigate may track, such as packages, don't.
+var presenceLabels = map[string]bool{
+	"person": true,
+
+	"bicycle":    true,
+	"bus":        true,
+	"car":        true,
+	"motorcycle": true,
+	"truck":      true,
+
+	"bear":     true,
+	"bird":     true,
+	"cat":      true,
+	"cow":      true,
+	"deer":     true,
+	"dog":      true,
+	"fox":      true,
+	"horse":    true,
+	"raccoon":  true,
+	"rabbit":   true,
+	"sheep":    true,
+	"squirrel": true,
+}
+
+func indicatesPresence(event frigate.Event) bool {
+	return event.InProgress() && !event.FalsePositive && presenceLabels[event.Label]
+}
+
+// refreshEvents updates the presence and last activity of each camera from Frigate's events API.
+// The camera lock must be held.
+func (fb *FrigateBridge) refreshEvents(ctx context.Context) error {
+	refreshedAt := time.Now()
+
+	active, err := fb.client.GetEvents(ctx, frigate.EventsQuery{InProgress: true, Limit: maxEvents})
+	if err != nil {
+		fb.logger.Error("unable to get in progress events from frigate", zap.Error(err))
+		return status.Error(codes.Internal, "unable to get events from frigate")
+	}
+	recent, err := fb.client.GetEvents(ctx, frigate.EventsQuery{After: fb.eventsRefreshedAt, Limit: maxEvents})
+	if err != nil {
+		fb.logger.Error("unable to get recent events from frigate", zap.Error(err))
+		return status.Error(codes.Internal, "unable to get events from frigate")
+	}
+
+	for _, camera := range fb.cameras {
+		camera.events = map[string]frigate.Event{}
+	}
+	for _, event := range recent {
+		if camera, found := fb.cameras[event.Camera]; found && !event.FalsePositive {
+			camera.seenAt(event.LastSeenAt())
+		}
+	}
+	for _, event := range active {
+		camera, found := fb.cameras[event.Camera]
+		if !found || event.FalsePositive {
+			continue
+		}
+
+		// The object is still being tracked, so the camera is seeing it now.
+		camera.seenAt(refreshedAt)
+		if indicatesPresence(event) {
+			camera.events[event.ID] = event
+		}
+	}
+
+	fb.eventsRefreshedAt = refreshedAt
+	return nil
+}
+
+// handleEventUpdate applies an event update received over MQTT, so that presence is reported as soon as it changes
+// rather than at the next refresh.
+func (fb *FrigateBridge) handleEventUpdate(update frigate.EventUpdate) {
+	fb.camerasLock.Lock()
+	defer fb.camerasLock.Unlock()
+
+	event := update.After.Event()
+	camera, found := fb.cameras[event.Camera]
+	if !found || camera.Endpoint == nil {
+		fb.logger.Debug("ignoring event for unknown camera", zap.String("camera", event.Camera))
+		return
+	} else if camera.events == nil {
+		camera.events = map[string]frigate.Event{}
+	}
+
+	if update.Type == frigate.EventUpdateEnd || !indicatesPresence(event) {
+		delete(camera.events, event.ID)
+	} else {
+		camera.events[event.ID] = event
+	}
+	if !event.FalsePositive {
+		camera.seenAt(update.After.LastSeenAt())
+	}
+
+	fb.svc.UpdateDevice(camera.ToDevice())
+}
@@ -1,22 +1,31 @@
 package frigate
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"path"
 	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 )
 
 const (
-	apiConfigPath = "/api/config"
-	apiStatsPath  = "/api/stats"
+	apiConfigPath    = "/api/config"
+	apiStatsPath     = "/api/stats"
+	apiEventsPath    = "/api/events"
+	apiConfigSetPath = "/api/config/set"
 )
 
+// ErrNotFound is returned if the requested camera or event doesn't exist.
+var ErrNotFound = errors.New("not found")
+
 // CameraConfig contains some of the configured fields in a camera. This is only a partial definition.
 type CameraConfig struct {
 	Name    string `json:"name"`
@@ -30,6 +39,10 @@ type CameraConfig struct {
 		Enabled bool `json:"enabled"`
 	} `json:"detect"`
 
+	Record struct {
+		Enabled bool `json:"enabled"`
+	} `json:"record"`
+
 	FaceRecognition struct {
 		Enabled bool `json:"enabled"`
 	} `json:"face_recognition"`
@@ -71,6 +84,18 @@ type StatsResponse struct {
 	Service ServiceStats           `json:"service"`
 }
 
+// configSetRequest is the body of a request to the /api/config/set endpoint.
+type configSetRequest struct {
+	// RequiresRestart is set to 0 so the change is applied to the running cameras, not just saved to the config file.
+	RequiresRestart int `json:"requires_restart"`
+}
+
+// configSetResponse is returned from the /api/config/set endpoint.
+type configSetResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
 // Client is used to interact with an instance of the Frigate NVR API.
 type Client struct {
 	logger      *zap.Logger
@@ -110,34 +135,127 @@ func (c *Client) GetPort() int {
 // GetStats queries the stats HTTP endpoint and returns its response.
 func (c *Client) GetStats(ctx context.Context) (*StatsResponse, error) {
 	apiStats := &StatsResponse{}
-	err := c.apiRequest(ctx, apiStatsPath, apiStats)
+	err := c.apiRequest(ctx, apiStatsPath, nil, apiStats)
 	return apiStats, err
 }
 
 // GetConfig queries the config HTTP endpoint and returns its response.
 func (c *Client) GetConfig(ctx context.Context) (*ConfigResponse, error) {
 	apiConfig := &ConfigResponse{}
-	err := c.apiRequest(ctx, apiConfigPath, apiConfig)
+	err := c.apiRequest(ctx, apiConfigPath, nil, apiConfig)
 	return apiConfig, err
 }
 
-func (c *Client) apiRequest(ctx context.Context, path string, apiResp any) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s/%s", c.apiEndpoint.Scheme, c.apiEndpoint.Host, path), nil)
+// GetEvents queries the events HTTP endpoint for the events matching the supplied query, most recent first.
+func (c *Client) GetEvents(ctx context.Context, query EventsQuery) ([]Event, error) {
+	var events []Event
+	err := c.apiRequest(ctx, apiEventsPath, query.values(), &events)
+	return events, err
+}
+
+// SetCameraDetection turns object detection on or off for the specified camera.
+func (c *Client) SetCameraDetection(ctx context.Context, camera string, enabled bool) error {
+	return c.setConfig(ctx, fmt.Sprintf("cameras.%s.detect.enabled", camera), enabled)
+}
+
+// SetCameraRecording turns recording on or off for the specified camera.
+func (c *Client) SetCameraRecording(ctx context.Context, camera string, enabled bool) error {
+	return c.setConfig(ctx, fmt.Sprintf("cameras.%s.record.enabled", camera), enabled)
+}
+
+// setConfig updates the specified config key. Frigate saves the change to its config file, so it persists across restarts.
+func (c *Client) setConfig(ctx context.Context, key string, enabled bool) error {
+	value := "False"
+	if enabled {
+		value = "True"
+	}
+	query := url.Values{}
+	query.Set(key, value)
+
+	body, err := json.Marshal(configSetRequest{RequiresRestart: 0})
 	if err != nil {
-		c.logger.Error("unable to create http request", zap.Error(err))
 		return err
 	}
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(ctx, http.MethodPut, apiConfigSetPath, query, bytes.NewReader(body))
 	if err != nil {
-		c.logger.Error("unable to get config from frigate", zap.Error(err))
 		return err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		c.logger.Error("got failure response code", zap.Int("response_code", resp.StatusCode))
-		return errors.New("unsuccessful request")
-	} else if resp.Header.Get("Content-Type") != "application/json" {
+
+	setResp := &configSetResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(setResp); err != nil {
+		c.logger.Error("unable to decode response body", zap.Error(err))
+		return err
+	} else if !setResp.Success {
+		c.logger.Error("unable to update config", zap.String("key", key), zap.String("message", setResp.Message))
+		return errors.New(setResp.Message)
+	}
+	return nil
+}
+
+// GetEvent queries the events HTTP endpoint for the specified event.
+func (c *Client) GetEvent(ctx context.Context, id string) (*Event, error) {
+	if !validEventID(id) {
+		return nil, ErrNotFound
+	}
+	event := &Event{}
+	err := c.apiRequest(ctx, path.Join(apiEventsPath, id), nil, event)
+	return event, err
+}
+
+// GetLatestSnapshot returns the most recent frame seen by the specified camera, as a JPEG.
+func (c *Client) GetLatestSnapshot(ctx context.Context, camera string, opts SnapshotOptions) ([]byte, error) {
+	return c.imageRequest(ctx, path.Join("/api", camera, "latest.jpg"), opts.values())
+}
+
+// GetEventSnapshot returns the snapshot saved for the specified event, as a JPEG.
+func (c *Client) GetEventSnapshot(ctx context.Context, id string, opts SnapshotOptions) ([]byte, error) {
+	if !validEventID(id) {
+		return nil, ErrNotFound
+	}
+	return c.imageRequest(ctx, path.Join(apiEventsPath, id, "snapshot.jpg"), opts.values())
+}
+
+// GetEventClip returns the recording of the specified event, as an MP4. The caller must close the returned body.
+func (c *Client) GetEventClip(ctx context.Context, id string) (io.ReadCloser, error) {
+	if !validEventID(id) {
+		return nil, ErrNotFound
+	}
+	resp, err := c.get(ctx, path.Join(apiEventsPath, id, "clip.mp4"), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// validEventID returns false if the supplied ID would request a path other than that of an event.
+func validEventID(id string) bool {
+	return len(id) > 0 && id != "." && id != ".." && !strings.Contains(id, "/")
+}
+
+func (c *Client) imageRequest(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	resp, err := c.get(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	image, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.logger.Error("unable to read image", zap.Error(err))
+		return nil, err
+	}
+	return image, nil
+}
+
+func (c *Client) apiRequest(ctx context.Context, path string, query url.Values, apiResp any) error {
+	resp, err := c.get(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "application/json" {
 		c.logger.Error("got a non-json response", zap.String("content_type", resp.Header.Get("Content-Type")))
 		return errors.New("unsuccessful request")
 	}
@@ -149,3 +267,41 @@ func (c *Client) apiRequest(ctx context.Context, path string, apiResp any) error
 
 	return nil
 }
+
+// get requests the specified path from the API, returning the response if it was successful.
+// The caller must close the body of the response.
+func (c *Client) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	return c.do(ctx, http.MethodGet, path, query, nil)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	endpoint := url.URL{
+		Scheme:   c.apiEndpoint.Scheme,
+		Host:     c.apiEndpoint.Host,
+		Path:     path,
+		RawQuery: query.Encode(),
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), body)
+	if err != nil {
+		c.logger.Error("unable to create http request", zap.Error(err))
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.logger.Error("unable to make request to frigate", zap.String("path", path), zap.Error(err))
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		c.logger.Error("got failure response code", zap.String("path", path), zap.Int("response_code", resp.StatusCode))
+		return nil, errors.New("unsuccessful request")
+	}
+	return resp, nil
+}
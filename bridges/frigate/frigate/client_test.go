@@ -0,0 +1,88 @@
+package frigate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestGetEvents(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/events.json")
+	require.NoError(t, err)
+
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != apiEventsPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		query = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fixture)
+	}))
+	defer srv.Close()
+
+	endpoint, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	client := NewClient(zaptest.NewLogger(t), srv.Client(), endpoint)
+
+	events, err := client.GetEvents(context.Background(), EventsQuery{
+		Cameras:    []string{"front", "back"},
+		After:      time.Unix(1718030000, 0),
+		InProgress: true,
+		Limit:      10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "front,back", query.Get("cameras"))
+	assert.Equal(t, "1718030000", query.Get("after"))
+	assert.Equal(t, "1", query.Get("in_progress"))
+	assert.Equal(t, "10", query.Get("limit"))
+
+	require.Len(t, events, 2)
+	assert.Equal(t, "person", events[0].Label)
+	assert.Equal(t, []string{"porch", "walkway"}, events[0].Zones)
+	assert.True(t, events[0].InProgress())
+	assert.Equal(t, events[0].StartedAt(), events[0].LastSeenAt())
+	assert.Equal(t, int64(1718035242), events[0].StartedAt().Unix())
+
+	assert.False(t, events[1].InProgress())
+	assert.Equal(t, time.Unix(1718035011, int64(500*time.Millisecond)), events[1].LastSeenAt())
+}
+
+func TestSetCameraDetection(t *testing.T) {
+	var query url.Values
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != apiConfigSetPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		query = r.URL.Query()
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		success := query.Get("cameras.front.detect.enabled") == "True"
+		json.NewEncoder(w).Encode(configSetResponse{Success: success, Message: "invalid config"})
+	}))
+	defer srv.Close()
+
+	endpoint, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	client := NewClient(zaptest.NewLogger(t), srv.Client(), endpoint)
+
+	require.NoError(t, client.SetCameraDetection(context.Background(), "front", true))
+	assert.Equal(t, float64(0), body["requires_restart"])
+
+	err = client.SetCameraDetection(context.Background(), "front", false)
+	assert.EqualError(t, err, "invalid config")
+	assert.Equal(t, "False", query.Get("cameras.front.detect.enabled"))
+}
@@ -0,0 +1,87 @@
+package frigate
+
+import (
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event describes an object which Frigate tracked in the view of a camera. This is only a partial definition.
+type Event struct {
+	ID     string `json:"id"`
+	Camera string `json:"camera"`
+	// Label is the type of object which was detected, i.e. person or car.
+	Label string   `json:"label"`
+	Zones []string `json:"zones"`
+
+	// StartTime and EndTime are in seconds since the epoch. EndTime is nil while the event is in progress.
+	StartTime float64  `json:"start_time"`
+	EndTime   *float64 `json:"end_time"`
+
+	HasClip       bool    `json:"has_clip"`
+	HasSnapshot   bool    `json:"has_snapshot"`
+	TopScore      float64 `json:"top_score"`
+	FalsePositive bool    `json:"false_positive"`
+}
+
+// InProgress returns true if the object is still being tracked.
+func (e Event) InProgress() bool {
+	return e.EndTime == nil
+}
+
+// StartedAt returns when the object was first detected.
+func (e Event) StartedAt() time.Time {
+	return secondsToTime(e.StartTime)
+}
+
+// LastSeenAt returns when the object was last seen; this is when the event ended, or when it started if it is still
+// in progress.
+func (e Event) LastSeenAt() time.Time {
+	if e.EndTime == nil {
+		return e.StartedAt()
+	}
+	return secondsToTime(*e.EndTime)
+}
+
+// EventsQuery filters the events returned by GetEvents. Unset fields aren't filtered on.
+type EventsQuery struct {
+	Cameras []string
+	Labels  []string
+	// After and Before only include events which started within this range.
+	After  time.Time
+	Before time.Time
+	// InProgress only includes events which haven't yet ended.
+	InProgress bool
+	// Limit is the maximum number of events to return; Frigate returns 100 if it isn't set.
+	Limit int
+}
+
+func (q EventsQuery) values() url.Values {
+	values := url.Values{}
+	if len(q.Cameras) > 0 {
+		values.Set("cameras", strings.Join(q.Cameras, ","))
+	}
+	if len(q.Labels) > 0 {
+		values.Set("labels", strings.Join(q.Labels, ","))
+	}
+	if !q.After.IsZero() {
+		values.Set("after", strconv.FormatInt(q.After.Unix(), 10))
+	}
+	if !q.Before.IsZero() {
+		values.Set("before", strconv.FormatInt(q.Before.Unix(), 10))
+	}
+	if q.InProgress {
+		values.Set("in_progress", "1")
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return values
+}
+
+func secondsToTime(seconds float64) time.Time {
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*float64(time.Second)))
+}
@@ -0,0 +1,138 @@
+package frigate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTopicPrefix = "frigate"
+	eventsTopicFormat  = "%s/events"
+)
+
+// These are the types of EventUpdate published by Frigate.
+const (
+	EventUpdateNew    = "new"
+	EventUpdateUpdate = "update"
+	EventUpdateEnd    = "end"
+)
+
+// TrackedObject describes an object being tracked by Frigate, as published to MQTT. This is only a partial definition.
+type TrackedObject struct {
+	ID           string   `json:"id"`
+	Camera       string   `json:"camera"`
+	Label        string   `json:"label"`
+	CurrentZones []string `json:"current_zones"`
+
+	// FrameTime is when the object was last seen, in seconds since the epoch.
+	FrameTime float64  `json:"frame_time"`
+	StartTime float64  `json:"start_time"`
+	EndTime   *float64 `json:"end_time"`
+
+	HasClip       bool    `json:"has_clip"`
+	HasSnapshot   bool    `json:"has_snapshot"`
+	TopScore      float64 `json:"top_score"`
+	FalsePositive bool    `json:"false_positive"`
+}
+
+// LastSeenAt returns when the object was last seen; this is when the event ended, or the time of the last frame it
+// was seen in if it is still being tracked.
+func (o TrackedObject) LastSeenAt() time.Time {
+	if o.EndTime != nil {
+		return secondsToTime(*o.EndTime)
+	}
+	return secondsToTime(o.FrameTime)
+}
+
+// Event returns the tracked object as it would be returned by the events API.
+func (o TrackedObject) Event() Event {
+	return Event{
+		ID:            o.ID,
+		Camera:        o.Camera,
+		Label:         o.Label,
+		Zones:         o.CurrentZones,
+		StartTime:     o.StartTime,
+		EndTime:       o.EndTime,
+		HasClip:       o.HasClip,
+		HasSnapshot:   o.HasSnapshot,
+		TopScore:      o.TopScore,
+		FalsePositive: o.FalsePositive,
+	}
+}
+
+// EventUpdate is published by Frigate as an object is tracked; once when it is first detected, as its details
+// change, and once it is no longer tracked.
+type EventUpdate struct {
+	Type   string        `json:"type"`
+	Before TrackedObject `json:"before"`
+	After  TrackedObject `json:"after"`
+}
+
+// EventSubscriber receives event updates from the MQTT broker that Frigate publishes to.
+type EventSubscriber struct {
+	logger *zap.Logger
+	opts   *mqtt.ClientOptions
+	topic  string
+}
+
+// NewEventSubscriber creates a subscriber for the events published to the supplied broker, i.e. tcp://mqtt:1883.
+// The username and password are only used if set, and the topic prefix defaults to Frigate's default of 'frigate'.
+func NewEventSubscriber(logger *zap.Logger, broker string, username string, password string, topicPrefix string) *EventSubscriber {
+	if len(topicPrefix) < 1 {
+		topicPrefix = defaultTopicPrefix
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID("house-frigate-" + uuid.New().String()).
+		SetAutoReconnect(true)
+	if len(username) > 0 {
+		opts.SetUsername(username)
+		opts.SetPassword(password)
+	}
+
+	return &EventSubscriber{
+		logger: logger,
+		opts:   opts,
+		topic:  fmt.Sprintf(eventsTopicFormat, topicPrefix),
+	}
+}
+
+// Run connects to the broker and calls the supplied function with each update received, until the context is cancelled.
+// The subscription is restored whenever the connection to the broker is.
+func (s *EventSubscriber) Run(ctx context.Context, fn func(EventUpdate)) error {
+	onMessage := func(_ mqtt.Client, msg mqtt.Message) {
+		update := EventUpdate{}
+		if err := json.Unmarshal(msg.Payload(), &update); err != nil {
+			s.logger.Error("unable to decode event update", zap.String("topic", msg.Topic()), zap.Error(err))
+			return
+		}
+		fn(update)
+	}
+
+	opts := *s.opts
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		s.logger.Info("subscribing to frigate events", zap.String("topic", s.topic))
+		if token := c.Subscribe(s.topic, 0, onMessage); token.Wait() && token.Error() != nil {
+			s.logger.Error("unable to subscribe to frigate events", zap.String("topic", s.topic), zap.Error(token.Error()))
+		}
+	})
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		s.logger.Info("lost connection to mqtt broker; reconnecting", zap.Error(err))
+	})
+
+	client := mqtt.NewClient(&opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+
+	<-ctx.Done()
+	client.Disconnect(250)
+	return nil
+}
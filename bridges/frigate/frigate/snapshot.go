@@ -0,0 +1,25 @@
+package frigate
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// SnapshotOptions controls how a snapshot is rendered. Unset fields use Frigate's defaults.
+type SnapshotOptions struct {
+	// Height scales the snapshot to this many pixels high, keeping its aspect ratio.
+	Height int
+	// Quality is the JPEG quality, from 1 to 100.
+	Quality int
+}
+
+func (o SnapshotOptions) values() url.Values {
+	values := url.Values{}
+	if o.Height > 0 {
+		values.Set("h", strconv.Itoa(o.Height))
+	}
+	if o.Quality > 0 {
+		values.Set("quality", strconv.Itoa(o.Quality))
+	}
+	return values
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/google/uuid"
 	"github.com/spf13/viper"
@@ -12,6 +15,7 @@ import (
 
 	"github.com/rmrobinson/house/bridges/frigate/frigate"
 	"github.com/rmrobinson/house/service/bridge"
+	"github.com/rmrobinson/house/service/tracing"
 )
 
 func main() {
@@ -24,6 +28,7 @@ func main() {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("$HOME/.config/house")
 	viper.AddConfigPath(".")
+	viper.SetDefault("pairing.file", "$HOME/.config/house/frigate-pairing.json")
 
 	if err := viper.ReadInConfig(); err != nil {
 		logger.Fatal("unable to read config", zap.Error(err))
@@ -43,6 +48,9 @@ func main() {
 		}
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	svc := bridge.NewService(logger)
 
 	ipAddr := viper.GetString("frigate.ip")
@@ -64,9 +72,14 @@ func main() {
 		logger.Fatal("provided api details aren't a valid url")
 	}
 
-	frigateClient := frigate.NewClient(logger, &http.Client{}, frigateAPIEndpoint)
+	frigateClient := frigate.NewClient(logger, &http.Client{Transport: tracing.Transport(nil)}, frigateAPIEndpoint)
+
+	var snapshotConfig SnapshotConfig
+	if err := viper.UnmarshalKey("frigate.snapshots", &snapshotConfig); err != nil {
+		logger.Fatal("unable to parse 'snapshots' key from config", zap.Error(err))
+	}
 
-	fb := NewFrigateBridge(logger, svc, frigateClient, ipAddr)
+	fb := NewFrigateBridge(logger, svc, frigateClient, ipAddr, snapshotConfig)
 
 	// Once we've successfully gotten the device state, register the handler and device with the service
 	svc.RegisterHandler(fb, fb.b)
@@ -76,11 +89,36 @@ func main() {
 		logger.Fatal("unable to parse 'cameras' key from config")
 	}
 
-	fb.Setup(context.Background(), cameraConfigs)
+	fb.Setup(ctx, cameraConfigs)
+
+	// Events are polled for on each refresh; if Frigate publishes them to MQTT they can also be received as they happen.
+	if broker := viper.GetString("frigate.mqtt.broker"); len(broker) > 0 {
+		subscriber := frigate.NewEventSubscriber(logger, broker,
+			viper.GetString("frigate.mqtt.username"),
+			viper.GetString("frigate.mqtt.password"),
+			viper.GetString("frigate.mqtt.topic_prefix"))
+
+		go func() {
+			if err := subscriber.Run(ctx, fb.handleEventUpdate); err != nil {
+				logger.Error("unable to subscribe to frigate events", zap.Error(err))
+			}
+		}()
+	}
 
 	// Check for updates periodically
-	go fb.Run(context.Background())
+	go fb.Run(ctx)
 
-	s := bridge.NewServer(logger, svc)
-	s.Serve()
+	var serverConfig bridge.ServerConfig
+	if err := viper.Unmarshal(&serverConfig); err != nil {
+		logger.Fatal("unable to parse 'tls' and 'auth' keys from config", zap.Error(err))
+	}
+	serverOpts, err := bridge.ServerOptions(logger, svc, serverConfig)
+	if err != nil {
+		logger.Fatal("unable to configure server", zap.Error(err))
+	}
+
+	s := bridge.NewServer(logger, svc, serverOpts...)
+	if err := s.Serve(ctx); err != nil {
+		logger.Fatal("unable to serve", zap.Error(err))
+	}
 }
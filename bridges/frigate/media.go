@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/bridges/frigate/frigate"
+	"github.com/rmrobinson/house/service/bridge"
+)
+
+const (
+	snapshotContentType = "image/jpeg"
+	clipContentType     = "video/mp4"
+)
+
+var _ bridge.CameraMedia = (*FrigateBridge)(nil)
+
+// SnapshotConfig contains the defaults and caching used when serving camera snapshots.
+type SnapshotConfig struct {
+	// Height and Quality are used for requests which don't specify their own; Frigate's defaults are used if unset.
+	Height  int `mapstructure:"height"`
+	Quality int `mapstructure:"quality"`
+	// CacheFor is how long a snapshot is reused for, so that many viewers don't each fetch it from Frigate.
+	// Snapshots aren't cached if it is unset.
+	CacheFor time.Duration `mapstructure:"cache_for"`
+}
+
+// snapshotCache holds recently retrieved snapshots, keyed by the camera, event and rendering options.
+type snapshotCache struct {
+	cacheFor time.Duration
+
+	lock      sync.Mutex
+	snapshots map[string]*api2.Image
+}
+
+func newSnapshotCache(cacheFor time.Duration) *snapshotCache {
+	return &snapshotCache{
+		cacheFor:  cacheFor,
+		snapshots: map[string]*api2.Image{},
+	}
+}
+
+func (c *snapshotCache) get(key string) *api2.Image {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	image, found := c.snapshots[key]
+	if !found || time.Since(image.TakenAt.AsTime()) > c.cacheFor {
+		return nil
+	}
+	return image
+}
+
+func (c *snapshotCache) put(key string, image *api2.Image) {
+	if c.cacheFor <= 0 {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for k, cached := range c.snapshots {
+		if time.Since(cached.TakenAt.AsTime()) > c.cacheFor {
+			delete(c.snapshots, k)
+		}
+	}
+	c.snapshots[key] = image
+}
+
+// cameraByID returns the name of the camera with the supplied device ID.
+func (fb *FrigateBridge) cameraByID(id string) (string, error) {
+	fb.camerasLock.Lock()
+	defer fb.camerasLock.Unlock()
+
+	if camera := fb.cameraWithID(id); camera != nil {
+		return camera.Name, nil
+	}
+	return "", bridge.ErrDeviceNotFound
+}
+
+// cameraEvent retrieves the specified event, checking that it was recorded by the supplied camera.
+func (fb *FrigateBridge) cameraEvent(ctx context.Context, camera string, id string) (*frigate.Event, error) {
+	event, err := fb.client.GetEvent(ctx, id)
+	if errors.Is(err, frigate.ErrNotFound) {
+		return nil, bridge.ErrEventNotFound
+	} else if err != nil {
+		fb.logger.Error("unable to get event from frigate", zap.String("event_id", id), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get event from frigate")
+	} else if event.Camera != camera {
+		return nil, bridge.ErrEventNotFound
+	}
+	return event, nil
+}
+
+// GetCameraSnapshot returns the latest frame seen by the camera, or the snapshot saved for the requested event.
+func (fb *FrigateBridge) GetCameraSnapshot(ctx context.Context, req *api2.GetCameraSnapshotRequest) (*api2.Image, error) {
+	camera, err := fb.cameraByID(req.DeviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := frigate.SnapshotOptions{
+		Height:  int(req.Height),
+		Quality: int(req.Quality),
+	}
+	if opts.Height == 0 {
+		opts.Height = fb.snapshotConfig.Height
+	}
+	if opts.Quality == 0 {
+		opts.Quality = fb.snapshotConfig.Quality
+	}
+
+	key := fmt.Sprintf("%s/%s/%d/%d", camera, req.EventId, opts.Height, opts.Quality)
+	if image := fb.snapshots.get(key); image != nil {
+		return image, nil
+	}
+
+	var data []byte
+	if len(req.EventId) > 0 {
+		var event *frigate.Event
+		if event, err = fb.cameraEvent(ctx, camera, req.EventId); err != nil {
+			return nil, err
+		} else if !event.HasSnapshot {
+			return nil, status.Error(codes.NotFound, "the event has no snapshot")
+		}
+		data, err = fb.client.GetEventSnapshot(ctx, req.EventId, opts)
+	} else {
+		data, err = fb.client.GetLatestSnapshot(ctx, camera, opts)
+	}
+	if errors.Is(err, frigate.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "snapshot not available")
+	} else if err != nil {
+		fb.logger.Error("unable to get snapshot from frigate", zap.String("camera", camera), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get snapshot from frigate")
+	}
+
+	image := &api2.Image{
+		ContentType: snapshotContentType,
+		Data:        data,
+		TakenAt:     timestamppb.Now(),
+	}
+	fb.snapshots.put(key, image)
+	return image, nil
+}
+
+// ListCameraEvents returns the objects Frigate has detected in the view of the camera, excluding false positives.
+func (fb *FrigateBridge) ListCameraEvents(ctx context.Context, req *api2.ListCameraEventsRequest) ([]*api2.CameraEvent, error) {
+	camera, err := fb.cameraByID(req.DeviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	query := frigate.EventsQuery{
+		Cameras: []string{camera},
+		Labels:  req.Labels,
+		Limit:   int(req.Limit),
+	}
+	if req.After != nil {
+		query.After = req.After.AsTime()
+	}
+	if req.Before != nil {
+		query.Before = req.Before.AsTime()
+	}
+
+	events, err := fb.client.GetEvents(ctx, query)
+	if err != nil {
+		fb.logger.Error("unable to get events from frigate", zap.String("camera", camera), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get events from frigate")
+	}
+
+	ret := []*api2.CameraEvent{}
+	for _, event := range events {
+		if event.FalsePositive {
+			continue
+		}
+
+		cameraEvent := &api2.CameraEvent{
+			Id:          event.ID,
+			DeviceId:    req.DeviceId,
+			Label:       event.Label,
+			Zones:       event.Zones,
+			StartTime:   timestamppb.New(event.StartedAt()),
+			HasSnapshot: event.HasSnapshot,
+			HasClip:     event.HasClip,
+			Score:       float32(event.TopScore),
+		}
+		if !event.InProgress() {
+			cameraEvent.EndTime = timestamppb.New(event.LastSeenAt())
+		}
+		ret = append(ret, cameraEvent)
+	}
+	return ret, nil
+}
+
+// GetEventClip returns the recording Frigate made of the requested event.
+func (fb *FrigateBridge) GetEventClip(ctx context.Context, req *api2.GetEventClipRequest) (string, io.ReadCloser, error) {
+	camera, err := fb.cameraByID(req.DeviceId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	event, err := fb.cameraEvent(ctx, camera, req.EventId)
+	if err != nil {
+		return "", nil, err
+	} else if !event.HasClip {
+		return "", nil, status.Error(codes.NotFound, "the event has no clip")
+	}
+
+	clip, err := fb.client.GetEventClip(ctx, req.EventId)
+	if errors.Is(err, frigate.ErrNotFound) {
+		return "", nil, status.Error(codes.NotFound, "clip not available")
+	} else if err != nil {
+		fb.logger.Error("unable to get clip from frigate", zap.String("event_id", req.EventId), zap.Error(err))
+		return "", nil, status.Error(codes.Internal, "unable to get clip from frigate")
+	}
+	return clipContentType, clip, nil
+}
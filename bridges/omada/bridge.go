@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/viper"
@@ -23,16 +24,17 @@ import (
 )
 
 func omClientInfoToDevice(s *omapi.ClientInfo) *device.Device {
-	lastSeen := time.Unix(*s.LastSeen, 0)
 	cleanMAC := strings.ReplaceAll(*s.Mac, "-", ":")
 	cleanMAC = strings.ToUpper(cleanMAC)
 
 	state := &trait.NetworkPresence_State{
 		HardwareAddress: cleanMAC,
 		IpAddresses:     []string{},
-		DeviceCategory:  *s.DeviceType,
 	}
 
+	if s.DeviceType != nil {
+		state.DeviceCategory = *s.DeviceType
+	}
 	if s.HostName != nil {
 		state.Hostname = *s.HostName
 	}
@@ -45,16 +47,28 @@ func omClientInfoToDevice(s *omapi.ClientInfo) *device.Device {
 	if s.Ipv6List != nil {
 		state.IpAddresses = append(state.IpAddresses, *s.Ipv6List...)
 	}
-	if s.Ssid != nil {
-		state.NetworkId = *s.Ssid
-	}
-	if s.ApName != nil {
-		state.NetworkDeviceId = *s.ApName
+	if s.Wireless != nil && !*s.Wireless {
+		state.Wired = true
+		if s.NetworkName != nil {
+			state.NetworkId = *s.NetworkName
+		}
+		if s.SwitchName != nil {
+			state.NetworkDeviceId = *s.SwitchName
+		}
+		if s.Port != nil {
+			state.NetworkDevicePort = *s.Port
+		}
+	} else {
+		if s.Ssid != nil {
+			state.NetworkId = *s.Ssid
+		}
+		if s.ApName != nil {
+			state.NetworkDeviceId = *s.ApName
+		}
 	}
 
-	return &device.Device{
-		Id:       *s.Mac,
-		LastSeen: timestamppb.New(lastSeen),
+	d := &device.Device{
+		Id: *s.Mac,
 		Details: &device.Device_ConnectedDevice{
 			ConnectedDevice: &device.ConnectedDevice{
 				NetworkPresence: &trait.NetworkPresence{
@@ -63,21 +77,40 @@ func omClientInfoToDevice(s *omapi.ClientInfo) *device.Device {
 			},
 		},
 	}
+	if s.LastSeen != nil {
+		d.LastSeen = timestamppb.New(time.Unix(*s.LastSeen, 0))
+	}
+	return d
 }
 
-// OmadaBridge
+// omadaClient contains the Omada API calls used by the bridge; it is satisfied by *omada.Client.
+type omadaClient interface {
+	GetGridActiveClientsWithResponse(ctx context.Context, omadacId string, siteId string, params *omapi.GetGridActiveClientsParams, reqEditors ...omapi.RequestEditorFn) (*omapi.GetGridActiveClientsResponse, error)
+}
+
+var _ omadaClient = (*omada.Client)(nil)
+
+// OmadaBridge reports the clients connected to a site managed by an Omada controller.
 type OmadaBridge struct {
 	logger *zap.Logger
 	svc    *bridge.Service
 	b      *api2.Bridge
 
-	client *omada.Client
+	client omadaClient
 	cid    string
 	siteID string
+	// infra is nil if the network devices of the site aren't reported.
+	infra infrastructure
+
+	// devices contains the IDs of the devices seen at the last refresh, so those which have left can be removed.
+	devices map[string]bool
+
+	portsLock sync.Mutex
+	ports     map[string]poePort
 }
 
 // NewOmadaBridge creates a new Omada bridge from the supplied client
-func NewOmadaBridge(logger *zap.Logger, svc *bridge.Service, client *omada.Client, omadaIPAddr string, omadaPort int, siteID string, cid string) *OmadaBridge {
+func NewOmadaBridge(logger *zap.Logger, svc *bridge.Service, client omadaClient, infra infrastructure, omadaIPAddr string, omadaPort int, siteID string, cid string) *OmadaBridge {
 	b := &api2.Bridge{
 		Id:           viper.GetString("bridge.id"),
 		IsReachable:  true,
@@ -93,24 +126,29 @@ func NewOmadaBridge(logger *zap.Logger, svc *bridge.Service, client *omada.Clien
 				},
 			},
 		},
-		State: &api2.Bridge_State{
-			IsPaired: true,
-		},
 	}
 
 	return &OmadaBridge{
-		logger: logger,
-		svc:    svc,
-		b:      b,
-		client: client,
-		siteID: siteID,
-		cid:    cid,
+		logger:  logger,
+		svc:     svc,
+		b:       b,
+		client:  client,
+		siteID:  siteID,
+		cid:     cid,
+		infra:   infra,
+		devices: map[string]bool{},
+		ports:   map[string]poePort{},
 	}
 }
 
 // ProcessCommand takes a given command request and attempts to execute it.
 // We only worry about processing valid commands for the given device traits.
 func (omb *OmadaBridge) ProcessCommand(ctx context.Context, cmd *command.Command) (*device.Device, error) {
+	// Only the PoE ports of switches support commands, which is the OnOff command.
+	if cmd.GetOnOff() != nil && omb.infra != nil {
+		return omb.setPortPoE(ctx, cmd.DeviceId, cmd.GetOnOff().On)
+	}
+
 	omb.logger.Error("received unsupported command - shouldn't happen")
 	return nil, bridge.ErrUnsupportedCommand
 }
@@ -122,44 +160,83 @@ func (omb *OmadaBridge) SetBridgeConfig(ctx context.Context, config bridge.Confi
 
 	viper.Set("bridge.name", config.Name)
 	viper.Set("bridge.description", config.Description)
-	viper.WriteConfig()
-
-	return nil
+	return viper.WriteConfig()
 }
 
 // Refresh is present to conform to the bridge.Handler interface. In this implementation it queries
-// the Omada API and returns the current state of all the connected devices.
+// the Omada API for all of the active clients, both wired and wireless, and the network devices of the site.
+// Devices which have left since the last refresh are removed.
 func (omb *OmadaBridge) Refresh(ctx context.Context) error {
-	trueArg := "true"
-	req := &api.GetGridActiveClientsParams{
-		Page:            1,
-		PageSize:        50,
-		FiltersWireless: &trueArg,
-		SortsMac:        &trueArg,
-	}
-	resp, err := omb.client.GetGridActiveClientsWithResponse(context.Background(), omb.cid, omb.siteID, req)
+	activeClients, err := omb.getActiveClients(ctx)
 	if err != nil {
-		omb.logger.Error("unable to get status from API",
-			zap.Error(err), zap.String("site_id", omb.siteID))
-		return status.Error(codes.Internal, "unable to get status from API")
+		return err
 	}
 
-	for _, activeClient := range *resp.JSON200.Result.Data {
-		omb.svc.UpdateDevice(omClientInfoToDevice(&activeClient))
+	seen := map[string]bool{}
+	if omb.infra != nil {
+		if seen, err = omb.refreshInfrastructure(ctx); err != nil {
+			return err
+		}
 	}
 
+	for _, activeClient := range activeClients {
+		if activeClient.Mac == nil {
+			continue
+		}
+
+		d := omClientInfoToDevice(&activeClient)
+		seen[d.Id] = true
+		omb.svc.UpdateDevice(d)
+	}
+
+	// Only a complete listing is reconciled, so a failed refresh doesn't remove devices which are still present.
+	for id := range omb.devices {
+		if !seen[id] {
+			omb.logger.Debug("device has left", zap.String("device_id", id))
+			omb.svc.RemoveDevice(id)
+		}
+	}
+	omb.devices = seen
+
 	return nil
 }
 
-// Run begins the process of polling the API and reporting back the state.
-func (omb *OmadaBridge) Run() {
-	omb.Refresh(context.Background())
+// getActiveClients pages through the active clients of the site.
+func (omb *OmadaBridge) getActiveClients(ctx context.Context) ([]omapi.ClientInfo, error) {
+	trueArg := "true"
+	return getAllPages(func(page int32) ([]omapi.ClientInfo, error) {
+		req := &api.GetGridActiveClientsParams{
+			Page:     page,
+			PageSize: pageSize,
+			SortsMac: &trueArg,
+		}
+		resp, err := omb.client.GetGridActiveClientsWithResponse(ctx, omb.cid, omb.siteID, req)
+		if err != nil {
+			omb.logger.Error("unable to get status from API",
+				zap.Error(err), zap.String("site_id", omb.siteID), zap.Int("page", int(page)))
+			return nil, status.Error(codes.Internal, "unable to get status from API")
+		} else if resp.JSON200 == nil || resp.JSON200.Result == nil {
+			omb.logger.Error("received unexpected response from API",
+				zap.Int("status_code", resp.StatusCode()), zap.String("site_id", omb.siteID), zap.Int("page", int(page)))
+			return nil, status.Error(codes.Internal, "unable to get status from API")
+		} else if resp.JSON200.Result.Data == nil {
+			return nil, nil
+		}
+		return *resp.JSON200.Result.Data, nil
+	})
+}
+
+// Run begins the process of polling the API and reporting back the state, until the context is cancelled.
+func (omb *OmadaBridge) Run(ctx context.Context) {
+	omb.svc.Refresh(ctx)
 
 	refreshTimer := time.NewTicker(time.Minute * 1)
+	defer refreshTimer.Stop()
+
 	for {
 		select {
 		case <-refreshTimer.C:
-			err := omb.Refresh(context.Background())
+			err := omb.svc.Refresh(ctx)
 			if err != nil {
 				omb.logger.Error("unable to get status from API",
 					zap.Error(err))
@@ -167,6 +244,8 @@ func (omb *OmadaBridge) Run() {
 			} else {
 				omb.logger.Debug("refreshed")
 			}
+		case <-ctx.Done():
+			return
 		}
 	}
 }
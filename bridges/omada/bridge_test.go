@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	omapi "github.com/rmrobinson/omada/api"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/api/command"
+	"github.com/rmrobinson/house/api/device"
+	"github.com/rmrobinson/house/service/bridge"
+)
+
+// omadaStandIn serves the active clients of a site the way the controller does, a page at a time.
+type omadaStandIn struct {
+	t *testing.T
+
+	lock    sync.Mutex
+	clients []map[string]any
+}
+
+func (s *omadaStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/clients") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	assert.Empty(s.t, r.URL.Query().Get("filters.wireless"), "wired clients should also be requested")
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	require.NoError(s.t, err)
+	pageSize, err := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	require.NoError(s.t, err)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	start := min((page-1)*pageSize, len(s.clients))
+	end := min(start+pageSize, len(s.clients))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"errorCode": 0,
+		"msg":       "Success.",
+		"result": map[string]any{
+			"totalRows":   len(s.clients),
+			"currentPage": page,
+			"currentSize": pageSize,
+			"data":        s.clients[start:end],
+		},
+	})
+}
+
+func (s *omadaStandIn) setClients(clients []map[string]any) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.clients = clients
+}
+
+func wirelessClient(i int) map[string]any {
+	return map[string]any{
+		"mac":        fmt.Sprintf("AA-BB-CC-00-%02X-%02X", i/256, i%256),
+		"hostName":   fmt.Sprintf("phone-%d", i),
+		"deviceType": "iphone",
+		"ip":         fmt.Sprintf("192.168.1.%d", i%250+2),
+		"lastSeen":   1718035242,
+		"wireless":   true,
+		"ssid":       "house",
+		"apName":     "lounge-ap",
+		"rssi":       -52,
+	}
+}
+
+func wiredClient(i int) map[string]any {
+	return map[string]any{
+		"mac":         fmt.Sprintf("AA-BB-CC-01-%02X-%02X", i/256, i%256),
+		"hostName":    fmt.Sprintf("nas-%d", i),
+		"deviceType":  "unknown",
+		"ip":          fmt.Sprintf("192.168.2.%d", i%250+2),
+		"lastSeen":    1718035242,
+		"wireless":    false,
+		"networkName": "LAN",
+		"switchName":  "basement-switch",
+		"port":        i%24 + 1,
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	standIn := &omadaStandIn{t: t}
+	var clients []map[string]any
+	for i := 0; i < 2*pageSize+10; i++ {
+		clients = append(clients, wirelessClient(i))
+	}
+	clients = append(clients, wiredClient(1), wiredClient(2))
+	standIn.setClients(clients)
+
+	srv := httptest.NewServer(standIn)
+	defer srv.Close()
+
+	client, err := omapi.NewClientWithResponses(srv.URL)
+	require.NoError(t, err)
+
+	logger := zaptest.NewLogger(t)
+	svc := bridge.NewService(logger)
+	omb := NewOmadaBridge(logger, svc, client, nil, "127.0.0.1", 8043, "site", "cid")
+	svc.RegisterHandler(omb, omb.b)
+
+	ctx := context.Background()
+	require.NoError(t, svc.Refresh(ctx))
+
+	resp, err := svc.API().ListDevices(ctx, &api2.ListDevicesRequest{})
+	require.NoError(t, err)
+	assert.Len(t, resp.Devices, len(clients))
+
+	wired, err := svc.API().GetDevice(ctx, &api2.GetDeviceRequest{Id: "AA-BB-CC-01-00-02"})
+	require.NoError(t, err)
+	presence := wired.GetConnectedDevice().GetNetworkPresence().GetState()
+	assert.True(t, presence.Wired)
+	assert.Equal(t, "AA:BB:CC:01:00:02", presence.HardwareAddress)
+	assert.Equal(t, "basement-switch", presence.NetworkDeviceId)
+	assert.Equal(t, int32(3), presence.NetworkDevicePort)
+	assert.Equal(t, "LAN", presence.NetworkId)
+
+	wireless, err := svc.API().GetDevice(ctx, &api2.GetDeviceRequest{Id: "AA-BB-CC-00-00-01"})
+	require.NoError(t, err)
+	presence = wireless.GetConnectedDevice().GetNetworkPresence().GetState()
+	assert.False(t, presence.Wired)
+	assert.Equal(t, "lounge-ap", presence.NetworkDeviceId)
+	assert.Equal(t, "house", presence.NetworkId)
+
+	// Clients which have left are removed at the next refresh.
+	standIn.setClients(clients[1:])
+	require.NoError(t, svc.Refresh(ctx))
+
+	resp, err = svc.API().ListDevices(ctx, &api2.ListDevicesRequest{})
+	require.NoError(t, err)
+	assert.Len(t, resp.Devices, len(clients)-1)
+	_, err = svc.API().GetDevice(ctx, &api2.GetDeviceRequest{Id: "AA-BB-CC-00-00-00"})
+	assert.Equal(t, bridge.ErrDeviceNotFound, err)
+}
+
+// fakeInfrastructure contains a site with an access point and a switch with two PoE ports.
+type fakeInfrastructure struct {
+	devices []networkDevice
+	ports   []switchPort
+}
+
+func (f *fakeInfrastructure) getNetworkDevices(ctx context.Context) ([]networkDevice, error) {
+	return f.devices, nil
+}
+
+func (f *fakeInfrastructure) getPoEPorts(ctx context.Context, switchMAC string) ([]switchPort, error) {
+	return f.ports, nil
+}
+
+func (f *fakeInfrastructure) setPortPoE(ctx context.Context, switchMAC string, port int, enabled bool) error {
+	for i := range f.ports {
+		if f.ports[i].Port == port {
+			f.ports[i].PoEEnabled = enabled
+		}
+	}
+	return nil
+}
+
+func TestRefreshInfrastructure(t *testing.T) {
+	srv := httptest.NewServer(&omadaStandIn{t: t, clients: []map[string]any{}})
+	defer srv.Close()
+
+	client, err := omapi.NewClientWithResponses(srv.URL)
+	require.NoError(t, err)
+
+	infra := &fakeInfrastructure{
+		devices: []networkDevice{
+			{MAC: "00-11-22-00-00-01", Name: "lounge-ap", Type: networkDeviceTypeAP, Model: "EAP650", Connected: true, Uptime: time.Hour, Clients: 12},
+			{MAC: "00-11-22-00-00-02", Name: "basement-switch", Type: networkDeviceTypeSwitch, Model: "TL-SG2210P", Connected: true, Uptime: 2 * time.Hour, Clients: 4},
+		},
+		ports: []switchPort{
+			{Port: 1, Name: "camera", LinkUp: true, PoEEnabled: true, PowerWatts: 4.5, VoltageV: 53.2, CurrentMA: 85},
+			{Port: 2, LinkUp: true, PoEEnabled: true, PowerWatts: 6.1, VoltageV: 53.2, CurrentMA: 115},
+		},
+	}
+
+	logger := zaptest.NewLogger(t)
+	svc := bridge.NewService(logger)
+	omb := NewOmadaBridge(logger, svc, client, infra, "127.0.0.1", 8043, "site", "cid")
+	svc.RegisterHandler(omb, omb.b)
+
+	ctx := context.Background()
+	require.NoError(t, svc.Refresh(ctx))
+
+	ap, err := svc.API().GetDevice(ctx, &api2.GetDeviceRequest{Id: "00-11-22-00-00-01"})
+	require.NoError(t, err)
+	assert.Equal(t, device.NetworkDevice_TYPE_ACCESS_POINT, ap.GetNetworkDevice().Type)
+	assert.Equal(t, int32(12), ap.GetNetworkDevice().ClientCount)
+	assert.Equal(t, time.Hour, ap.GetNetworkDevice().Uptime.AsDuration())
+	assert.Nil(t, ap.GetNetworkDevice().Power)
+
+	sw, err := svc.API().GetDevice(ctx, &api2.GetDeviceRequest{Id: "00-11-22-00-00-02"})
+	require.NoError(t, err)
+	assert.Equal(t, device.NetworkDevice_TYPE_SWITCH, sw.GetNetworkDevice().Type)
+	assert.InDelta(t, 10.6, sw.GetNetworkDevice().GetPower().GetState().PowerW, 0.001)
+
+	port, err := svc.API().GetDevice(ctx, &api2.GetDeviceRequest{Id: portID("00-11-22-00-00-02", 1)})
+	require.NoError(t, err)
+	assert.Equal(t, "camera", port.Config.Name)
+	assert.True(t, port.GetNetworkPort().GetOnOff().GetState().IsOn)
+	assert.Equal(t, 4.5, port.GetNetworkPort().GetPower().GetState().PowerW)
+	assert.Equal(t, 53.2, port.GetNetworkPort().GetPower().GetState().VoltageV)
+	assert.InDelta(t, 0.085, port.GetNetworkPort().GetPower().GetState().CurrentA, 0.0001)
+
+	// Turning the port off cuts its power, i.e. to power cycle the camera plugged into it.
+	port, err = svc.API().ExecuteCommand(ctx, &command.Command{
+		DeviceId: portID("00-11-22-00-00-02", 1),
+		Details:  &command.Command_OnOff{OnOff: &command.OnOff{On: false}},
+	})
+	require.NoError(t, err)
+	assert.False(t, port.GetNetworkPort().GetOnOff().GetState().IsOn)
+	assert.Zero(t, port.GetNetworkPort().GetPower().GetState().PowerW)
+	assert.Zero(t, port.GetNetworkPort().GetPower().GetState().CurrentA)
+	assert.False(t, infra.ports[0].PoEEnabled)
+
+	// The ports of a disconnected switch are still reported, but can't be controlled.
+	infra.devices[1].Connected = false
+	require.NoError(t, svc.Refresh(ctx))
+
+	port, err = svc.API().GetDevice(ctx, &api2.GetDeviceRequest{Id: portID("00-11-22-00-00-02", 2)})
+	require.NoError(t, err)
+	assert.False(t, port.Address.IsReachable)
+
+	_, err = svc.API().ExecuteCommand(ctx, &command.Command{
+		DeviceId: portID("00-11-22-00-00-02", 2),
+		Details:  &command.Command_OnOff{OnOff: &command.OnOff{On: false}},
+	})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
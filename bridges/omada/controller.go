@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rmrobinson/omada"
+	omapi "github.com/rmrobinson/omada/api"
+)
+
+const (
+	// deviceStatusConnected is the status the controller reports for a device it is managing.
+	deviceStatusConnected = 1
+	// linkStatusUp is the link status the controller reports for a port with a device plugged into it.
+	linkStatusUp = 1
+
+	poeModeOff = 0
+	poeModeOn  = 1
+
+	// pageSize is the number of records requested from the controller at a time.
+	pageSize = 100
+)
+
+// getAllPages retrieves every page of a listing from the controller, starting from the first page. The controller
+// returns a short page once there are no more records.
+func getAllPages[T any](getPage func(page int32) ([]T, error)) ([]T, error) {
+	var all []T
+	for page := int32(1); ; page++ {
+		data, err := getPage(page)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, data...)
+
+		if len(data) < pageSize {
+			return all, nil
+		}
+	}
+}
+
+// controllerClient contains the Omada API calls used to manage the network devices; it is satisfied by *omada.Client.
+type controllerClient interface {
+	GetDeviceListWithResponse(ctx context.Context, omadacId string, siteId string, params *omapi.GetDeviceListParams, reqEditors ...omapi.RequestEditorFn) (*omapi.GetDeviceListResponse, error)
+	GetSwitchPortsWithResponse(ctx context.Context, omadacId string, siteId string, switchMac string, reqEditors ...omapi.RequestEditorFn) (*omapi.GetSwitchPortsResponse, error)
+	ModifySwitchPortPoeModeWithResponse(ctx context.Context, omadacId string, siteId string, switchMac string, port int32, body omapi.ModifySwitchPortPoeModeJSONRequestBody, reqEditors ...omapi.RequestEditorFn) (*omapi.ModifySwitchPortPoeModeResponse, error)
+}
+
+var _ controllerClient = (*omada.Client)(nil)
+
+// omadaInfrastructure retrieves and controls the network devices of a site through the Omada API.
+type omadaInfrastructure struct {
+	client controllerClient
+	cid    string
+	siteID string
+}
+
+func newOmadaInfrastructure(client controllerClient, cid string, siteID string) *omadaInfrastructure {
+	return &omadaInfrastructure{
+		client: client,
+		cid:    cid,
+		siteID: siteID,
+	}
+}
+
+func (o *omadaInfrastructure) getNetworkDevices(ctx context.Context) ([]networkDevice, error) {
+	infos, err := getAllPages(func(page int32) ([]omapi.DeviceInfo, error) {
+		req := &omapi.GetDeviceListParams{
+			Page:     page,
+			PageSize: pageSize,
+		}
+		resp, err := o.client.GetDeviceListWithResponse(ctx, o.cid, o.siteID, req)
+		if err != nil {
+			return nil, err
+		} else if resp.JSON200 == nil || resp.JSON200.Result == nil {
+			return nil, fmt.Errorf("unexpected response with status %d for page %d", resp.StatusCode(), page)
+		} else if resp.JSON200.Result.Data == nil {
+			return nil, nil
+		}
+		return *resp.JSON200.Result.Data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []networkDevice
+	for _, info := range infos {
+		if info.Mac == nil || info.Type == nil {
+			continue
+		}
+
+		nd := networkDevice{
+			MAC:  *info.Mac,
+			Type: *info.Type,
+		}
+		if info.Name != nil {
+			nd.Name = *info.Name
+		}
+		if info.Model != nil {
+			nd.Model = *info.Model
+		}
+		if info.FirmwareVersion != nil {
+			nd.Firmware = *info.FirmwareVersion
+		}
+		if info.Ip != nil {
+			nd.IP = *info.Ip
+		}
+		if info.Status != nil {
+			nd.Connected = *info.Status == deviceStatusConnected
+		}
+		if info.UptimeLong != nil {
+			nd.Uptime = time.Duration(*info.UptimeLong) * time.Second
+		}
+		if info.ClientNum != nil {
+			nd.Clients = int(*info.ClientNum)
+		}
+		devices = append(devices, nd)
+	}
+	return devices, nil
+}
+
+func (o *omadaInfrastructure) getPoEPorts(ctx context.Context, switchMAC string) ([]switchPort, error) {
+	resp, err := o.client.GetSwitchPortsWithResponse(ctx, o.cid, o.siteID, switchMAC)
+	if err != nil {
+		return nil, err
+	} else if resp.JSON200 == nil || resp.JSON200.Result == nil {
+		return nil, fmt.Errorf("unexpected response with status %d", resp.StatusCode())
+	}
+
+	var ports []switchPort
+	for _, info := range *resp.JSON200.Result {
+		if info.Port == nil || info.SupportPoe == nil || !*info.SupportPoe {
+			continue
+		}
+
+		port := switchPort{
+			Port: int(*info.Port),
+		}
+		if info.Name != nil {
+			port.Name = *info.Name
+		}
+		if info.LinkStatus != nil {
+			port.LinkUp = *info.LinkStatus == linkStatusUp
+		}
+		if info.PoeMode != nil {
+			port.PoEEnabled = *info.PoeMode == poeModeOn
+		}
+		if info.PortStatus != nil {
+			if info.PortStatus.PoePower != nil {
+				port.PowerWatts = *info.PortStatus.PoePower
+			}
+			if info.PortStatus.Voltage != nil {
+				port.VoltageV = *info.PortStatus.Voltage
+			}
+			if info.PortStatus.Current != nil {
+				port.CurrentMA = *info.PortStatus.Current
+			}
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+func (o *omadaInfrastructure) setPortPoE(ctx context.Context, switchMAC string, port int, enabled bool) error {
+	body := omapi.ModifySwitchPortPoeModeJSONRequestBody{
+		PoeMode: poeModeOff,
+	}
+	if enabled {
+		body.PoeMode = poeModeOn
+	}
+
+	resp, err := o.client.ModifySwitchPortPoeModeWithResponse(ctx, o.cid, o.siteID, switchMAC, int32(port), body)
+	if err != nil {
+		return err
+	} else if resp.JSON200 == nil {
+		return fmt.Errorf("unexpected response with status %d", resp.StatusCode())
+	} else if resp.JSON200.ErrorCode != nil && *resp.JSON200.ErrorCode != 0 {
+		if resp.JSON200.Msg != nil {
+			return errors.New(*resp.JSON200.Msg)
+		}
+		return fmt.Errorf("controller returned error %d", *resp.JSON200.ErrorCode)
+	}
+	return nil
+}
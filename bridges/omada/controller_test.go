@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	omapi "github.com/rmrobinson/omada/api"
+)
+
+// controllerStandIn serves the network devices and switch ports of a site the way the controller does.
+type controllerStandIn struct {
+	t *testing.T
+
+	devices []map[string]any
+	ports   []map[string]any
+	// pages counts the device list requests.
+	pages int
+	// poeModes records the PoE mode set for each port, and poeErr is returned instead if set.
+	poeModes map[string]int
+	poeErr   string
+}
+
+func (s *controllerStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/openapi/v1/cid/sites/site/"), "/")
+
+	switch {
+	case len(path) == 1 && path[0] == "devices":
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		require.NoError(s.t, err)
+		size, err := strconv.Atoi(r.URL.Query().Get("pageSize"))
+		require.NoError(s.t, err)
+		s.pages++
+
+		start := min((page-1)*size, len(s.devices))
+		end := min(start+size, len(s.devices))
+		json.NewEncoder(w).Encode(map[string]any{
+			"errorCode": 0,
+			"result": map[string]any{
+				"totalRows": len(s.devices),
+				"data":      s.devices[start:end],
+			},
+		})
+	case len(path) == 3 && path[0] == "switches" && path[2] == "ports":
+		json.NewEncoder(w).Encode(map[string]any{
+			"errorCode": 0,
+			"result":    s.ports,
+		})
+	case len(path) == 5 && path[0] == "switches" && path[4] == "poe-mode":
+		if len(s.poeErr) > 0 {
+			json.NewEncoder(w).Encode(map[string]any{"errorCode": -1, "msg": s.poeErr})
+			return
+		}
+		body := map[string]int{}
+		require.NoError(s.t, json.NewDecoder(r.Body).Decode(&body))
+		for _, mode := range body {
+			s.poeModes[path[1]+"/"+path[3]] = mode
+		}
+		json.NewEncoder(w).Encode(map[string]any{"errorCode": 0})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestInfrastructure(t *testing.T, standIn *controllerStandIn) *omadaInfrastructure {
+	standIn.t = t
+	standIn.poeModes = map[string]int{}
+	srv := httptest.NewServer(standIn)
+	t.Cleanup(srv.Close)
+
+	client, err := omapi.NewClientWithResponses(srv.URL)
+	require.NoError(t, err)
+	return newOmadaInfrastructure(client, "cid", "site")
+}
+
+func TestGetNetworkDevices(t *testing.T) {
+	standIn := &controllerStandIn{}
+	for i := 0; i < 2*pageSize+5; i++ {
+		standIn.devices = append(standIn.devices, map[string]any{
+			"mac":    fmt.Sprintf("AA-BB-CC-DD-%02X-%02X", i/256, i%256),
+			"type":   "ap",
+			"name":   fmt.Sprintf("ap-%d", i),
+			"status": deviceStatusConnected,
+		})
+	}
+	standIn.devices[0] = map[string]any{
+		"mac":             "AA-BB-CC-DD-00-00",
+		"type":            "switch",
+		"name":            "Core",
+		"model":           "TL-SG2210P",
+		"firmwareVersion": "3.0.1",
+		"ip":              "192.168.1.2",
+		"status":          0,
+		"uptimeLong":      3600,
+		"clientNum":       7,
+	}
+	// Devices without a MAC address or type can't be identified.
+	standIn.devices[1] = map[string]any{"name": "unknown"}
+	infra := newTestInfrastructure(t, standIn)
+
+	devices, err := infra.getNetworkDevices(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, devices, 2*pageSize+4)
+	assert.Equal(t, 3, standIn.pages)
+
+	assert.Equal(t, networkDevice{
+		MAC:       "AA-BB-CC-DD-00-00",
+		Type:      "switch",
+		Name:      "Core",
+		Model:     "TL-SG2210P",
+		Firmware:  "3.0.1",
+		IP:        "192.168.1.2",
+		Connected: false,
+		Uptime:    time.Hour,
+		Clients:   7,
+	}, devices[0])
+	assert.True(t, devices[1].Connected)
+	assert.Equal(t, "ap-2", devices[1].Name)
+
+	// A full last page is followed by an empty one.
+	standIn.devices = standIn.devices[:pageSize]
+	standIn.pages = 0
+	devices, err = infra.getNetworkDevices(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, devices, pageSize-1)
+	assert.Equal(t, 2, standIn.pages)
+}
+
+func TestPoEPorts(t *testing.T) {
+	standIn := &controllerStandIn{
+		ports: []map[string]any{
+			{
+				"port":       1,
+				"name":       "Camera",
+				"supportPoe": true,
+				"linkStatus": linkStatusUp,
+				"poeMode":    poeModeOn,
+				"portStatus": map[string]any{"poePower": 4.5, "voltage": 53.2, "current": 85.0},
+			},
+			{"port": 2, "name": "Spare", "supportPoe": true, "linkStatus": 0, "poeMode": poeModeOff},
+			{"port": 9, "name": "Uplink", "supportPoe": false, "linkStatus": linkStatusUp},
+		},
+	}
+	infra := newTestInfrastructure(t, standIn)
+	ctx := context.Background()
+
+	ports, err := infra.getPoEPorts(ctx, "AA-BB-CC-DD-00-00")
+	require.NoError(t, err)
+	assert.Equal(t, []switchPort{
+		{Port: 1, Name: "Camera", LinkUp: true, PoEEnabled: true, PowerWatts: 4.5, VoltageV: 53.2, CurrentMA: 85},
+		{Port: 2, Name: "Spare"},
+	}, ports)
+
+	require.NoError(t, infra.setPortPoE(ctx, "AA-BB-CC-DD-00-00", 2, true))
+	assert.Equal(t, poeModeOn, standIn.poeModes["AA-BB-CC-DD-00-00/2"])
+	require.NoError(t, infra.setPortPoE(ctx, "AA-BB-CC-DD-00-00", 1, false))
+	assert.Equal(t, poeModeOff, standIn.poeModes["AA-BB-CC-DD-00-00/1"])
+
+	standIn.poeErr = "port doesn't support poe"
+	assert.EqualError(t, infra.setPortPoE(ctx, "AA-BB-CC-DD-00-00", 9, true), "port doesn't support poe")
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/rmrobinson/house/api/device"
+	"github.com/rmrobinson/house/api/trait"
+	"github.com/rmrobinson/house/service/bridge"
+)
+
+const (
+	networkDeviceTypeAP      = "ap"
+	networkDeviceTypeSwitch  = "switch"
+	networkDeviceTypeGateway = "gateway"
+
+	omadaManufacturer = "TP-Link"
+)
+
+var networkDeviceTypes = map[string]device.NetworkDevice_Type{
+	networkDeviceTypeAP:      device.NetworkDevice_TYPE_ACCESS_POINT,
+	networkDeviceTypeSwitch:  device.NetworkDevice_TYPE_SWITCH,
+	networkDeviceTypeGateway: device.NetworkDevice_TYPE_GATEWAY,
+}
+
+// networkDevice is an access point, switch or gateway managed by the controller.
+type networkDevice struct {
+	MAC      string
+	Name     string
+	Type     string
+	Model    string
+	Firmware string
+	IP       string
+
+	Connected bool
+	Uptime    time.Duration
+	Clients   int
+}
+
+// switchPort is a port of a switch which is able to supply power over Ethernet.
+type switchPort struct {
+	Port   int
+	Name   string
+	LinkUp bool
+	// PoEEnabled is whether the port is configured to supply power.
+	PoEEnabled bool
+	PowerWatts float64
+	VoltageV   float64
+	CurrentMA  float64
+}
+
+// poePort is a port of a connected switch which can be controlled.
+type poePort struct {
+	sw   networkDevice
+	port switchPort
+}
+
+// infrastructure contains the calls used to manage the network devices of a site.
+type infrastructure interface {
+	getNetworkDevices(ctx context.Context) ([]networkDevice, error)
+	// getPoEPorts returns the ports of the specified switch which are able to supply power.
+	getPoEPorts(ctx context.Context, switchMAC string) ([]switchPort, error)
+	setPortPoE(ctx context.Context, switchMAC string, port int, enabled bool) error
+}
+
+func networkDeviceToDevice(nd networkDevice, ports []switchPort) *device.Device {
+	networkDevice := &device.NetworkDevice{
+		Type: networkDeviceTypes[nd.Type],
+		NetworkPresence: &trait.NetworkPresence{
+			State: &trait.NetworkPresence_State{
+				HardwareAddress: strings.ToUpper(strings.ReplaceAll(nd.MAC, "-", ":")),
+				IpAddresses:     []string{},
+				Hostname:        nd.Name,
+				DeviceCategory:  nd.Type,
+			},
+		},
+		Uptime:      durationpb.New(nd.Uptime),
+		ClientCount: int32(nd.Clients),
+	}
+	if len(nd.IP) > 0 {
+		networkDevice.NetworkPresence.State.IpAddresses = append(networkDevice.NetworkPresence.State.IpAddresses, nd.IP)
+	}
+	if nd.Type == networkDeviceTypeSwitch {
+		total := 0.0
+		for _, port := range ports {
+			total += port.PowerWatts
+		}
+		networkDevice.Power = &trait.Power{
+			State: &trait.Power_State{
+				PowerW: total,
+			},
+		}
+	}
+
+	d := &device.Device{
+		Id:           nd.MAC,
+		ModelId:      nd.Model,
+		Manufacturer: omadaManufacturer,
+		Address: &device.Device_Address{
+			Address:     nd.IP,
+			IsReachable: nd.Connected,
+		},
+		Config: &device.Device_Config{
+			Name: nd.Name,
+		},
+		Details: &device.Device_NetworkDevice{
+			NetworkDevice: networkDevice,
+		},
+	}
+	if len(nd.Firmware) > 0 {
+		d.SoftwareVersion = &nd.Firmware
+	}
+	return d
+}
+
+// portID returns the device ID of the specified port of a switch.
+func portID(switchMAC string, port int) string {
+	return fmt.Sprintf("%s-port-%d", switchMAC, port)
+}
+
+func switchPortToDevice(sw networkDevice, port switchPort) *device.Device {
+	name := port.Name
+	if len(name) < 1 {
+		name = fmt.Sprintf("%s port %d", sw.Name, port.Port)
+	}
+
+	return &device.Device{
+		Id:           portID(sw.MAC, port.Port),
+		ModelId:      sw.Model,
+		Manufacturer: omadaManufacturer,
+		Address: &device.Device_Address{
+			Address:     sw.IP,
+			IsReachable: sw.Connected,
+		},
+		Config: &device.Device_Config{
+			Name: name,
+		},
+		Details: &device.Device_NetworkPort{
+			NetworkPort: &device.NetworkPort{
+				NetworkDeviceId: sw.MAC,
+				Port:            int32(port.Port),
+				LinkUp:          port.LinkUp,
+				OnOff: &trait.OnOff{
+					Attributes: &trait.OnOff_Attributes{
+						CanControl: true,
+					},
+					State: &trait.OnOff_State{
+						IsOn: port.PoEEnabled,
+					},
+				},
+				Power: &trait.Power{
+					State: &trait.Power_State{
+						PowerW:   port.PowerWatts,
+						VoltageV: port.VoltageV,
+						CurrentA: port.CurrentMA / 1000,
+					},
+				},
+			},
+		},
+	}
+}
+
+// refreshInfrastructure updates the network devices of the site and the PoE ports of its switches, returning the IDs
+// of the devices which were seen.
+func (omb *OmadaBridge) refreshInfrastructure(ctx context.Context) (map[string]bool, error) {
+	networkDevices, err := omb.infra.getNetworkDevices(ctx)
+	if err != nil {
+		omb.logger.Error("unable to get network devices from API",
+			zap.Error(err), zap.String("site_id", omb.siteID))
+		return nil, status.Error(codes.Internal, "unable to get network devices from API")
+	}
+
+	omb.portsLock.Lock()
+	defer omb.portsLock.Unlock()
+
+	seen := map[string]bool{}
+	poePorts := map[string]poePort{}
+	for _, nd := range networkDevices {
+		var ports []switchPort
+		if nd.Type == networkDeviceTypeSwitch && nd.Connected {
+			ports, err = omb.infra.getPoEPorts(ctx, nd.MAC)
+			if err != nil {
+				omb.logger.Error("unable to get switch ports from API",
+					zap.Error(err), zap.String("switch_mac", nd.MAC))
+				return nil, status.Error(codes.Internal, "unable to get switch ports from API")
+			}
+		} else if nd.Type == networkDeviceTypeSwitch {
+			// Ports can't be retrieved while the switch is disconnected, so the last known ports are reported as unreachable.
+			for _, p := range omb.ports {
+				if p.sw.MAC == nd.MAC {
+					ports = append(ports, p.port)
+				}
+			}
+		}
+
+		d := networkDeviceToDevice(nd, ports)
+		seen[d.Id] = true
+		omb.svc.UpdateDevice(d)
+
+		for _, port := range ports {
+			d := switchPortToDevice(nd, port)
+			seen[d.Id] = true
+			poePorts[d.Id] = poePort{sw: nd, port: port}
+			omb.svc.UpdateDevice(d)
+		}
+	}
+	omb.ports = poePorts
+
+	return seen, nil
+}
+
+// setPortPoE turns the power supplied by the port with the specified device ID on or off.
+func (omb *OmadaBridge) setPortPoE(ctx context.Context, id string, enabled bool) (*device.Device, error) {
+	omb.portsLock.Lock()
+	defer omb.portsLock.Unlock()
+
+	p, found := omb.ports[id]
+	if !found {
+		return nil, bridge.ErrDeviceNotFound
+	} else if !p.sw.Connected {
+		return nil, status.Error(codes.Unavailable, "the switch of the port is not connected")
+	}
+
+	if err := omb.infra.setPortPoE(ctx, p.sw.MAC, p.port.Port, enabled); err != nil {
+		omb.logger.Error("unable to set port poe",
+			zap.Error(err), zap.String("switch_mac", p.sw.MAC), zap.Int("port", p.port.Port))
+		return nil, status.Error(codes.Internal, "unable to set port poe")
+	}
+
+	p.port.PoEEnabled = enabled
+	if !enabled {
+		p.port.PowerWatts = 0
+		p.port.VoltageV = 0
+		p.port.CurrentMA = 0
+	}
+	omb.ports[id] = p
+	return switchPortToDevice(p.sw, p.port), nil
+}
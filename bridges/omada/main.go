@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/google/uuid"
 	"github.com/spf13/viper"
@@ -12,6 +16,7 @@ import (
 	"github.com/rmrobinson/omada"
 
 	"github.com/rmrobinson/house/service/bridge"
+	"github.com/rmrobinson/house/service/tracing"
 )
 
 func main() {
@@ -24,6 +29,7 @@ func main() {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("$HOME/.config/house")
 	viper.AddConfigPath(".")
+	viper.SetDefault("pairing.file", "$HOME/.config/house/omada-pairing.json")
 
 	if err := viper.ReadInConfig(); err != nil {
 		logger.Fatal("unable to read config", zap.Error(err))
@@ -43,6 +49,9 @@ func main() {
 		}
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	svc := bridge.NewService(logger)
 
 	omIpAddr := viper.GetString("omada.ip")
@@ -71,24 +80,35 @@ func main() {
 	omSiteID := viper.GetString("omada.site_id")
 
 	httpClient := &http.Client{
-		Transport: &http.Transport{
+		Transport: tracing.Transport(&http.Transport{
 			TLSClientConfig: &tls.Config{
 				InsecureSkipVerify: omInsecureTls, // self-hosted Omada SDN controllers use self-signed certs. If you have a proper cert, don't use this.
 			},
-		},
+		}),
 	}
 	omadaClient := omada.NewClient(logger, fmt.Sprintf("%s://%s:%d", omProto, omIpAddr, omPort), omID, omClientID, omClientSecret, httpClient)
 
 	// Create Kea client
 
-	omb := NewOmadaBridge(logger, svc, omadaClient, omIpAddr, omPort, omSiteID, omID)
+	omb := NewOmadaBridge(logger, svc, omadaClient, newOmadaInfrastructure(omadaClient, omID, omSiteID), omIpAddr, omPort, omSiteID, omID)
 
 	// Once we've successfully gotten the device state, register the handler and device with the service
 	svc.RegisterHandler(omb, omb.b)
 
 	// Check for updates periodically
-	go omb.Run()
+	go omb.Run(ctx)
+
+	var serverConfig bridge.ServerConfig
+	if err := viper.Unmarshal(&serverConfig); err != nil {
+		logger.Fatal("unable to parse 'tls' and 'auth' keys from config", zap.Error(err))
+	}
+	serverOpts, err := bridge.ServerOptions(logger, svc, serverConfig)
+	if err != nil {
+		logger.Fatal("unable to configure server", zap.Error(err))
+	}
 
-	s := bridge.NewServer(logger, svc)
-	s.Serve()
+	s := bridge.NewServer(logger, svc, serverOpts...)
+	if err := s.Serve(ctx); err != nil {
+		logger.Fatal("unable to serve", zap.Error(err))
+	}
 }
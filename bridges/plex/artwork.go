@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// artworkPath is where artwork is served from on the callback port.
+	artworkPath = "/artwork/"
+	// maxArtwork limits how many pieces of artwork are remembered; the oldest are forgotten first.
+	maxArtwork = 200
+	// maxArtworkSize limits the size of the artwork which is retrieved from Plex.
+	maxArtworkSize = 10 << 20
+)
+
+var (
+	errArtworkServerURLMissing = errors.New("plex server url is required to retrieve artwork")
+	errArtworkTooLarge         = errors.New("artwork is too large")
+)
+
+type artwork struct {
+	contentType string
+	data        []byte
+}
+
+type artworkEntry struct {
+	// path is the Plex path of the artwork, which must be requested with the API token.
+	path  string
+	image *artwork
+}
+
+// artworkCache holds the artwork which has been offered to clients, keyed by an ID derived from its Plex path.
+// Only artwork with an ID issued by the cache is served, so clients can't use the bridge to make other requests of
+// the Plex server.
+type artworkCache struct {
+	lock    sync.Mutex
+	entries map[string]*artworkEntry
+	order   []string
+}
+
+func newArtworkCache() *artworkCache {
+	return &artworkCache{
+		entries: map[string]*artworkEntry{},
+	}
+}
+
+func artworkID(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return hex.EncodeToString(sum[:16])
+}
+
+// register issues an ID for the artwork at the supplied Plex path.
+func (c *artworkCache) register(path string) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	id, _ := c.entry(path)
+	return id
+}
+
+// put stores the artwork at the supplied Plex path, such as a thumbnail sent with a webhook, returning its ID.
+func (c *artworkCache) put(path string, image *artwork) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	id, e := c.entry(path)
+	e.image = image
+	return id
+}
+
+// get returns the Plex path and, if it has been retrieved, the artwork with the supplied ID.
+func (c *artworkCache) get(id string) (string, *artwork, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, found := c.entries[id]
+	if !found {
+		return "", nil, false
+	}
+	return e.path, e.image, true
+}
+
+// entry returns the ID and entry for the supplied path, creating it if needed. The lock must be held.
+func (c *artworkCache) entry(path string) (string, *artworkEntry) {
+	id := artworkID(path)
+	if e, found := c.entries[id]; found {
+		return id, e
+	}
+
+	if len(c.order) >= maxArtwork {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	e := &artworkEntry{path: path}
+	c.entries[id] = e
+	c.order = append(c.order, id)
+	return id, e
+}
+
+// artworkURL returns the URL clients can retrieve the artwork at the Plex path from, or nil if artwork isn't served.
+func (p *Plex) artworkURL(path string) *string {
+	if len(p.artworkBaseURL) < 1 || len(path) < 1 {
+		return nil
+	}
+
+	url := strings.TrimSuffix(p.artworkBaseURL, "/") + artworkPath + p.artwork.register(path)
+	return &url
+}
+
+// getArtwork retrieves the artwork at the supplied path from the Plex server, authenticating with the API token.
+func (p *Plex) getArtwork(ctx context.Context, path string) (*artwork, error) {
+	if len(p.serverURL) < 1 {
+		return nil, errArtworkServerURLMissing
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.serverURL, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Plex-Token", p.apiKey)
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// One byte more than the limit is read so artwork which is too large is refused rather than cut short.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxArtworkSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(data) > maxArtworkSize {
+		return nil, errArtworkTooLarge
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if len(contentType) < 1 {
+		contentType = http.DetectContentType(data)
+	}
+	return &artwork{
+		contentType: contentType,
+		data:        data,
+	}, nil
+}
+
+// handleArtwork serves the artwork with the ID in the request path, retrieving it from Plex if it isn't cached.
+func (p *Plex) handleArtwork(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, artworkPath)
+	path, image, found := p.artwork.get(id)
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	if image == nil {
+		var err error
+		image, err = p.getArtwork(r.Context(), path)
+		if err != nil {
+			p.logger.Error("unable to get artwork from plex",
+				zap.Error(err), zap.String("path", path))
+			http.Error(w, "unable to get artwork from plex", http.StatusBadGateway)
+			return
+		}
+		p.artwork.put(path, image)
+	}
+
+	w.Header().Set("Content-Type", image.contentType)
+	w.Header().Set("Cache-Control", "max-age=86400")
+	if _, err := w.Write(image.data); err != nil {
+		p.logger.Debug("unable to write artwork", zap.Error(err))
+	}
+}
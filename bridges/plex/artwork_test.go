@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/rmrobinson/house/service/bridge"
+)
+
+func TestArtwork(t *testing.T) {
+	var requests atomic.Int32
+	plexSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/library/metadata/4/art/1700000000" {
+			w.Write(make([]byte, maxArtworkSize+1))
+			return
+		} else if r.Header.Get("X-Plex-Token") != "token" || r.URL.Path != "/library/metadata/1/art/1700000000" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("poster"))
+	}))
+	defer plexSrv.Close()
+
+	logger := zaptest.NewLogger(t)
+	p := NewPlex(logger, bridge.NewService(logger), plexSrv.URL, "token", "http://plex-bridge.local:8080/", time.Minute)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.handleArtwork(rec, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(url, "http://plex-bridge.local:8080"), nil))
+		return rec
+	}
+
+	artURL := p.artworkURL("/library/metadata/1/art/1700000000")
+	require.NotNil(t, artURL)
+	assert.True(t, strings.HasPrefix(*artURL, "http://plex-bridge.local:8080/artwork/"))
+	assert.NotContains(t, *artURL, "token")
+
+	// Artwork is retrieved from Plex once, then served from the cache.
+	for i := 0; i < 2; i++ {
+		rec := get(*artURL)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "poster", rec.Body.String())
+	}
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Thumbnails sent with webhooks are served without asking Plex.
+	thumbURL := p.artworkURL("/library/metadata/2/thumb/1700000000")
+	p.artwork.put("/library/metadata/2/thumb/1700000000", &artwork{contentType: "image/png", data: []byte("thumb")})
+	rec := get(*thumbURL)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "thumb", rec.Body.String())
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Artwork which is too large is refused rather than served cut short.
+	largeURL := p.artworkURL("/library/metadata/4/art/1700000000")
+	assert.Equal(t, http.StatusBadGateway, get(*largeURL).Code)
+	_, image, _ := p.artwork.get(strings.TrimPrefix(*largeURL, "http://plex-bridge.local:8080"+artworkPath))
+	assert.Nil(t, image)
+
+	// Only artwork which has been offered is served.
+	assert.Equal(t, http.StatusNotFound, get(artworkPath+artworkID("/library/metadata/3/art/1")).Code)
+	assert.Equal(t, http.StatusNotFound, get(artworkPath+"../library/sections").Code)
+
+	// Artwork isn't offered without a URL to serve it from.
+	p.artworkBaseURL = ""
+	assert.Nil(t, p.artworkURL("/library/metadata/1/art/1700000000"))
+}
@@ -57,9 +57,6 @@ func NewPlexBridge(logger *zap.Logger, svc *bridge.Service, client *Plex) *PlexB
 				},
 			},
 		},
-		State: &api2.Bridge_State{
-			IsPaired: true,
-		},
 	}
 
 	cb := &PlexBridge{
@@ -84,9 +81,7 @@ func (pb *PlexBridge) SetBridgeConfig(ctx context.Context, config bridge.Config)
 	pb.b.Config.Description = config.Description
 
 	viper.Set("bridge.description", config.Description)
-	viper.WriteConfig()
-
-	return nil
+	return viper.WriteConfig()
 }
 
 // Refresh is present to conform to the bridge.Handler interface. In this implementation
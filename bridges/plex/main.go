@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,6 +26,9 @@ func main() {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("$HOME/.config/house")
 	viper.AddConfigPath(".")
+	viper.SetDefault("pairing.file", "$HOME/.config/house/plex-pairing.json")
+	viper.SetDefault("plex.pollInterval", 5*time.Second)
+	viper.SetDefault("plex.inactiveTimeout", 10*time.Minute)
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
@@ -41,6 +47,9 @@ func main() {
 		logger.Fatal("unable to read config", zap.Error(err))
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	svc := bridge.NewService(logger)
 
 	plexURL := viper.GetString("plex.serverURL")
@@ -49,33 +58,62 @@ func main() {
 		logger.Fatal("no plex API key specified")
 	}
 
-	p := NewPlex(logger, svc, plexURL, plexAPIKey)
+	plexCallbackPort := viper.GetInt("plex.callbackPort")
+	artworkURL := viper.GetString("plex.artworkURL")
+	if len(artworkURL) > 0 && plexCallbackPort < 1 {
+		logger.Fatal("artwork is served on the callback port, so it must be specified")
+	}
 
-	if err := p.Start(context.Background()); err != nil {
+	p := NewPlex(logger, svc, plexURL, plexAPIKey, artworkURL, viper.GetDuration("plex.inactiveTimeout"))
+
+	if err := p.Start(ctx); err != nil {
 		logger.Fatal("unable to start plex", zap.Error(err))
 	}
 
+	pb := NewPlexBridge(logger, svc, p)
+
+	svc.RegisterHandler(pb, pb.b)
+
 	go func() {
+		refreshTimer := time.NewTicker(time.Minute * 30)
+		defer refreshTimer.Stop()
+		pollTimer := time.NewTicker(viper.GetDuration("plex.pollInterval"))
+		defer pollTimer.Stop()
+
 		for {
-			time.Sleep(time.Minute * 30)
-			if err := p.Refresh(context.Background()); err != nil {
-				logger.Error("unable to refresh plex state", zap.Error(err))
+			select {
+			case <-refreshTimer.C:
+				if err := svc.Refresh(ctx); err != nil {
+					logger.Error("unable to refresh plex state", zap.Error(err))
+				}
+			case <-pollTimer.C:
+				// Errors are logged by the poll; they don't affect the health of the bridge.
+				p.pollSessions(ctx)
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
 
-	pb := NewPlexBridge(logger, svc, p)
-
-	svc.RegisterHandler(pb, pb.b)
-
-	plexCallbackPort := viper.GetInt("plex.callbackPort")
 	if plexCallbackPort > 0 {
 		http.HandleFunc("/", p.handleWebhook)
+		http.HandleFunc(artworkPath, p.handleArtwork)
 
 		logger.Info("listening for plex callbacks", zap.Int("port", plexCallbackPort))
 		go http.ListenAndServe(fmt.Sprintf(":%d", plexCallbackPort), http.DefaultServeMux)
 	}
 
-	s := bridge.NewServer(logger, svc)
-	s.Serve()
+	var serverConfig bridge.ServerConfig
+	if err := viper.Unmarshal(&serverConfig); err != nil {
+		logger.Fatal("unable to parse 'tls' and 'auth' keys from config", zap.Error(err))
+	}
+	serverOpts, err := bridge.ServerOptions(logger, svc, serverConfig)
+	if err != nil {
+		logger.Fatal("unable to configure server", zap.Error(err))
+	}
+
+	s := bridge.NewServer(logger, svc, serverOpts...)
+	if err := s.Serve(ctx); err != nil {
+		logger.Fatal("unable to serve", zap.Error(err))
+	}
 }
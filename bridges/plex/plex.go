@@ -3,10 +3,9 @@ package main
 import (
 	"context"
 	"errors"
-	"fmt"
-	"image"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/LukeHagar/plexgo"
 	"github.com/LukeHagar/plexgo/models/operations"
@@ -17,6 +16,7 @@ import (
 	"github.com/rmrobinson/house/api/device"
 	"github.com/rmrobinson/house/api/trait"
 	"github.com/rmrobinson/house/service/bridge"
+	"github.com/rmrobinson/house/service/tracing"
 )
 
 var errPlexServerMissingCapabilities = errors.New("plex server capabilities are empty")
@@ -38,15 +38,15 @@ func webhookEventTypeToPlaybackState(event plexwebhooks.EventType) trait.Media_P
 	}
 }
 
-func webhookPayloadToDevice(payload *plexwebhooks.Payload) *device.Device {
-	var artURL *string
-	if len(payload.Metadata.Art) > 0 {
-		artURL = &payload.Metadata.Art
-	}
+// webhookPayloadToDevice converts the player in the payload; artURL is where the artwork of the media is served.
+func webhookPayloadToDevice(payload *plexwebhooks.Payload, artURL *string) *device.Device {
+	var mediaType trait.Media_Type
 	var showDetails *trait.Media_ShowDetails
 	var movieDetails *trait.Media_MovieDetails
+	var songDetails *trait.Media_SongDetails
 
 	if payload.Metadata.Type == plexwebhooks.MediaTypeEpisode {
+		mediaType = trait.Media_TYPE_SHOW
 		showDetails = &trait.Media_ShowDetails{
 			Id:             payload.Metadata.GUID.String(),
 			EpisodeTitle:   payload.Metadata.Title,
@@ -61,6 +61,7 @@ func webhookPayloadToDevice(payload *plexwebhooks.Payload) *device.Device {
 			ShowTitle:   payload.Metadata.GrandparentTitle,
 		}
 	} else if payload.Metadata.Type == plexwebhooks.MediaTypeMovie {
+		mediaType = trait.Media_TYPE_MOVIE
 		movieDetails = &trait.Media_MovieDetails{
 			Id:            payload.Metadata.GUID.String(),
 			Title:         payload.Metadata.Title,
@@ -70,6 +71,21 @@ func webhookPayloadToDevice(payload *plexwebhooks.Payload) *device.Device {
 			ContentRating: payload.Metadata.ContentRating,
 			ArtUrl:        artURL,
 		}
+	} else if payload.Metadata.Type == plexwebhooks.MediaTypeTrack {
+		mediaType = trait.Media_TYPE_SONG
+		songDetails = &trait.Media_SongDetails{
+			Id:          payload.Metadata.GUID.String(),
+			SongName:    payload.Metadata.Title,
+			AlbumName:   payload.Metadata.ParentTitle,
+			ReleaseYear: int32(payload.Metadata.ParentYear),
+			AlbumArtUrl: artURL,
+		}
+		// The track artist is only set when it differs from the album artist.
+		if len(payload.Metadata.OriginalTitle) > 0 {
+			songDetails.Artists = []string{payload.Metadata.OriginalTitle}
+		} else if len(payload.Metadata.GrandparentTitle) > 0 {
+			songDetails.Artists = []string{payload.Metadata.GrandparentTitle}
+		}
 	}
 
 	return &device.Device{
@@ -88,8 +104,11 @@ func webhookPayloadToDevice(payload *plexwebhooks.Payload) *device.Device {
 					State: &trait.Media_State{
 						DeviceState:     trait.Media_DEVICE_STATE_ACTIVE,
 						PlaybackState:   webhookEventTypeToPlaybackState(payload.Event),
+						MediaType:       mediaType,
+						UserName:        payload.Account.Title,
 						ShowDetails:     showDetails,
 						MovieDetails:    movieDetails,
+						SongDetails:     songDetails,
 						PlaybackLengthS: payload.Metadata.Duration.Seconds(),
 					},
 				},
@@ -105,24 +124,41 @@ type Plex struct {
 
 	serverURL string
 	apiKey    string
+	// artworkBaseURL is the URL clients reach the callback port at; artwork isn't served if it is empty.
+	artworkBaseURL string
 
-	api *plexgo.PlexAPI
+	api        *plexgo.PlexAPI
+	httpClient *http.Client
+	artwork    *artworkCache
 
 	id   string
 	name string
 
 	plexDeviceCache     map[string]operations.Device
 	plexDeviceCacheLock sync.Mutex
+
+	// inactiveTimeout is how long a player remains active after playback stops.
+	inactiveTimeout time.Duration
+	players         map[string]*player
+	playersLock     sync.Mutex
 }
 
 // NewPlex creates a new instance of the Plex struct.
-func NewPlex(logger *zap.Logger, svc *bridge.Service, serverURL string, apiKey string) *Plex {
+func NewPlex(logger *zap.Logger, svc *bridge.Service, serverURL string, apiKey string, artworkBaseURL string, inactiveTimeout time.Duration) *Plex {
 	return &Plex{
-		logger:          logger,
-		svc:             svc,
-		serverURL:       serverURL,
-		apiKey:          apiKey,
+		logger:         logger,
+		svc:            svc,
+		serverURL:      serverURL,
+		apiKey:         apiKey,
+		artworkBaseURL: artworkBaseURL,
+		httpClient: &http.Client{
+			Transport: tracing.Transport(nil),
+			Timeout:   60 * time.Second,
+		},
+		artwork:         newArtworkCache(),
 		plexDeviceCache: map[string]operations.Device{},
+		inactiveTimeout: inactiveTimeout,
+		players:         map[string]*player{},
 	}
 }
 
@@ -130,6 +166,7 @@ func NewPlex(logger *zap.Logger, svc *bridge.Service, serverURL string, apiKey s
 func (p *Plex) Start(ctx context.Context) error {
 	opts := []plexgo.SDKOption{
 		plexgo.WithSecurity(p.apiKey),
+		plexgo.WithClient(p.httpClient),
 	}
 	if len(p.serverURL) < 1 {
 		p.logger.Info("no plex url specified, defaulting to plex.tv")
@@ -145,7 +182,7 @@ func (p *Plex) Start(ctx context.Context) error {
 
 // Refresh refreshes the server information cached in this struct.
 func (p *Plex) Refresh(ctx context.Context) error {
-	res, err := p.api.Server.GetServerCapabilities(context.Background())
+	res, err := p.api.Server.GetServerCapabilities(ctx)
 	if err != nil {
 		p.logger.Error("unable to retrieve plex server capabilities", zap.Error(err))
 		return err
@@ -179,12 +216,6 @@ func (p *Plex) Refresh(ctx context.Context) error {
 	return nil
 }
 
-func (p *Plex) getArtwork(ctx context.Context, path string) (image.Image, error) {
-	_ = fmt.Sprintf("%s%s?X-Plex-Token=%s", p.serverURL, path, p.apiKey)
-	// TODO: get the image and parse it
-	return nil, errors.New("not implemented")
-}
-
 func (p *Plex) handleWebhook(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
@@ -218,12 +249,19 @@ func (p *Plex) handleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if thumb != nil {
-		p.logger.Debug("thumbnail present")
-		// TODO: what is best to do with this?
+	// The thumbnail of the media is sent with the webhook, so it is kept to save retrieving it when it is requested.
+	artPath := payload.Metadata.Thumb
+	if len(artPath) < 1 {
+		artPath = payload.Metadata.Art
+	}
+	if thumb != nil && len(payload.Metadata.Thumb) > 0 {
+		p.artwork.put(payload.Metadata.Thumb, &artwork{
+			contentType: http.DetectContentType(thumb.Data),
+			data:        thumb.Data,
+		})
 	}
 
-	updatedDevice := webhookPayloadToDevice(payload)
+	updatedDevice := webhookPayloadToDevice(payload, p.artworkURL(artPath))
 
 	p.plexDeviceCacheLock.Lock()
 	if d, found := p.plexDeviceCache[updatedDevice.Id]; found {
@@ -231,5 +269,7 @@ func (p *Plex) handleWebhook(w http.ResponseWriter, r *http.Request) {
 	}
 	p.plexDeviceCacheLock.Unlock()
 
-	p.svc.UpdateDevice(updatedDevice)
+	p.playersLock.Lock()
+	defer p.playersLock.Unlock()
+	p.updatePlayer(updatedDevice, time.Now())
 }
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/LukeHagar/plexgo/models/operations"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/rmrobinson/house/api/device"
+	"github.com/rmrobinson/house/api/trait"
+)
+
+var errPlexSessionsMissing = errors.New("plex sessions are empty")
+
+// sessionPlayerStates maps the states Plex reports for the player of a session to the playback state.
+var sessionPlayerStates = map[string]trait.Media_PlaybackState{
+	"playing":   trait.Media_PS_PLAYING,
+	"paused":    trait.Media_PS_PAUSED,
+	"buffering": trait.Media_PS_BUFFERING,
+	"stopped":   trait.Media_PS_STOPPED,
+}
+
+// player is the last state published for a Plex player.
+type player struct {
+	device *device.Device
+	// stoppedAt is when playback was last seen to stop; it is zero while the player is playing.
+	stoppedAt time.Time
+}
+
+func value[T any](v *T) T {
+	if v == nil {
+		var zero T
+		return zero
+	}
+	return *v
+}
+
+// sessionArtPath returns the Plex path of the artwork to show for the session.
+func sessionArtPath(session *operations.GetSessionsMetadata) string {
+	for _, path := range []*string{session.Thumb, session.ParentThumb, session.GrandparentThumb, session.Art} {
+		if len(value(path)) > 0 {
+			return *path
+		}
+	}
+	return ""
+}
+
+// sessionToDevice converts the player of the session; artURL is where the artwork of the media is served.
+func sessionToDevice(session *operations.GetSessionsMetadata, artURL *string) *device.Device {
+	state := &trait.Media_State{
+		DeviceState:       trait.Media_DEVICE_STATE_ACTIVE,
+		PlaybackState:     sessionPlayerStates[value(session.Player.State)],
+		PlaybackLengthS:   (time.Duration(value(session.Duration)) * time.Millisecond).Seconds(),
+		PlaybackPositionS: (time.Duration(value(session.ViewOffset)) * time.Millisecond).Seconds(),
+	}
+	if session.User != nil {
+		state.UserName = value(session.User.Title)
+	}
+
+	switch value(session.Type) {
+	case "episode":
+		state.MediaType = trait.Media_TYPE_SHOW
+		state.ShowDetails = &trait.Media_ShowDetails{
+			Id:           value(session.GUID),
+			EpisodeTitle: value(session.Title),
+			ArtUrl:       artURL,
+
+			SeasonId:    value(session.ParentKey),
+			SeasonTitle: value(session.ParentTitle),
+			ShowId:      value(session.GrandparentKey),
+			ShowTitle:   value(session.GrandparentTitle),
+		}
+	case "movie":
+		state.MediaType = trait.Media_TYPE_MOVIE
+		state.MovieDetails = &trait.Media_MovieDetails{
+			Id:     value(session.GUID),
+			Title:  value(session.Title),
+			Studio: value(session.ParentStudio),
+			ArtUrl: artURL,
+		}
+	case "track":
+		state.MediaType = trait.Media_TYPE_SONG
+		state.SongDetails = &trait.Media_SongDetails{
+			Id:          value(session.GUID),
+			SongName:    value(session.Title),
+			AlbumName:   value(session.ParentTitle),
+			ReleaseYear: int32(value(session.ParentYear)),
+			AlbumArtUrl: artURL,
+		}
+		if artist := value(session.GrandparentTitle); len(artist) > 0 {
+			state.SongDetails.Artists = []string{artist}
+		}
+	}
+
+	return &device.Device{
+		Id: value(session.Player.MachineIdentifier),
+		Config: &device.Device_Config{
+			Name: value(session.Player.Title),
+		},
+		Address: &device.Device_Address{
+			Address: value(session.Player.Address),
+		},
+		ModelDescription: session.Player.Platform,
+		LastSeen:         timestamppb.Now(),
+		Details: &device.Device_MediaPlayer{
+			MediaPlayer: &device.MediaPlayer{
+				Media: &trait.Media{
+					Attributes: &trait.Media_Attributes{},
+					State:      state,
+				},
+			},
+		},
+	}
+}
+
+// updatePlayer publishes the state of a player, remembering when it stopped so it can later be marked inactive.
+// The player lock must be held.
+func (p *Plex) updatePlayer(d *device.Device, now time.Time) {
+	pl, found := p.players[d.Id]
+	if !found {
+		pl = &player{}
+		p.players[d.Id] = pl
+	}
+
+	switch d.GetMediaPlayer().GetMedia().GetState().GetPlaybackState() {
+	case trait.Media_PS_STOPPED, trait.Media_PS_COMPLETED:
+		if pl.stoppedAt.IsZero() {
+			pl.stoppedAt = now
+		}
+	default:
+		pl.stoppedAt = time.Time{}
+	}
+
+	// The previous last seen time is kept unless the player has changed, so polling an unchanged player, such as one
+	// which is paused, doesn't publish an update.
+	if pl.device != nil {
+		lastSeen := d.LastSeen
+		d.LastSeen = pl.device.LastSeen
+		if !proto.Equal(d, pl.device) {
+			d.LastSeen = lastSeen
+		}
+	}
+	pl.device = d
+
+	p.svc.UpdateDevice(d)
+}
+
+// pollSessions retrieves the active sessions from Plex to update the position and media of their players.
+// Players without a session are marked stopped, and then inactive once they have been stopped for the inactive timeout.
+func (p *Plex) pollSessions(ctx context.Context) error {
+	resp, err := p.api.Sessions.GetSessions(ctx)
+	if err != nil {
+		p.logger.Error("unable to get plex sessions", zap.Error(err))
+		return err
+	} else if resp.Object == nil || resp.Object.MediaContainer == nil {
+		p.logger.Error("plex sessions are empty")
+		return errPlexSessionsMissing
+	}
+
+	now := time.Now()
+	playing := map[string]bool{}
+
+	p.playersLock.Lock()
+	defer p.playersLock.Unlock()
+
+	for idx := range resp.Object.MediaContainer.Metadata {
+		session := &resp.Object.MediaContainer.Metadata[idx]
+		if session.Player == nil || len(value(session.Player.MachineIdentifier)) < 1 {
+			continue
+		}
+
+		d := sessionToDevice(session, p.artworkURL(sessionArtPath(session)))
+		if d.ModelDescription == nil {
+			p.plexDeviceCacheLock.Lock()
+			if pd, found := p.plexDeviceCache[d.Id]; found {
+				d.ModelDescription = pd.Platform
+			}
+			p.plexDeviceCacheLock.Unlock()
+		}
+
+		playing[d.Id] = true
+		p.updatePlayer(d, now)
+	}
+
+	for id, pl := range p.players {
+		state := pl.device.GetMediaPlayer().GetMedia().GetState()
+		if playing[id] || state == nil || state.DeviceState == trait.Media_DEVICE_STATE_INACTIVE {
+			continue
+		}
+
+		d := proto.Clone(pl.device).(*device.Device)
+		state = d.GetMediaPlayer().GetMedia().GetState()
+		if pl.stoppedAt.IsZero() {
+			state.PlaybackState = trait.Media_PS_STOPPED
+		} else if now.Sub(pl.stoppedAt) >= p.inactiveTimeout {
+			d.GetMediaPlayer().GetMedia().State = &trait.Media_State{
+				DeviceState:   trait.Media_DEVICE_STATE_INACTIVE,
+				PlaybackState: trait.Media_PS_STOPPED,
+			}
+		} else {
+			continue
+		}
+		p.updatePlayer(d, now)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LukeHagar/plexgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/protobuf/proto"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/api/device"
+	"github.com/rmrobinson/house/api/trait"
+	"github.com/rmrobinson/house/service/bridge"
+)
+
+// sessionsStandIn answers the sessions query of a Plex server with the sessions it has been given.
+type sessionsStandIn struct {
+	lock     sync.Mutex
+	sessions string
+}
+
+func (s *sessionsStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if r.URL.Path != "/status/sessions" || r.Header.Get("X-Plex-Token") != "token" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"MediaContainer":{"size":1,"Metadata":[%s]}}`, s.sessions)
+}
+
+func (s *sessionsStandIn) set(sessions string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sessions = sessions
+}
+
+func trackSession(state string, viewOffset int) string {
+	return fmt.Sprintf(`{"type":"track","guid":"plex://track/5d07cdd1403c640290f5c2a4","title":"Harvest Moon",`+
+		`"parentTitle":"Harvest Moon","parentYear":1992,"grandparentTitle":"Neil Young","thumb":"/library/metadata/12/thumb/1700000000",`+
+		`"duration":303000,"viewOffset":%d,"User":{"id":"1","title":"sam"},`+
+		`"Player":{"address":"192.168.1.30","machineIdentifier":"c7a8a1f0-kitchen","platform":"Android","state":"%s","title":"Kitchen"}}`,
+		viewOffset, state)
+}
+
+func TestPollSessions(t *testing.T) {
+	standIn := &sessionsStandIn{sessions: trackSession("playing", 60000)}
+	srv := httptest.NewServer(standIn)
+	defer srv.Close()
+
+	logger := zaptest.NewLogger(t)
+	svc := bridge.NewService(logger)
+	p := NewPlex(logger, svc, srv.URL, "token", "http://plex-bridge.local:8080", time.Hour)
+	p.api = plexgo.New(plexgo.WithServerURL(srv.URL), plexgo.WithSecurity("token"), plexgo.WithClient(p.httpClient))
+	pb := NewPlexBridge(logger, svc, p)
+	svc.RegisterHandler(pb, pb.b)
+
+	ctx := context.Background()
+	getDevice := func() *device.Device {
+		d, err := svc.API().GetDevice(ctx, &api2.GetDeviceRequest{Id: "c7a8a1f0-kitchen"})
+		require.NoError(t, err)
+		return d
+	}
+	getMedia := func() *trait.Media_State {
+		return getDevice().GetMediaPlayer().GetMedia().GetState()
+	}
+
+	require.NoError(t, p.pollSessions(ctx))
+	media := getMedia()
+	assert.Equal(t, trait.Media_DEVICE_STATE_ACTIVE, media.DeviceState)
+	assert.Equal(t, trait.Media_PS_PLAYING, media.PlaybackState)
+	assert.Equal(t, trait.Media_TYPE_SONG, media.MediaType)
+	assert.Equal(t, "sam", media.UserName)
+	assert.Equal(t, 60.0, media.PlaybackPositionS)
+	assert.Equal(t, 303.0, media.PlaybackLengthS)
+	require.NotNil(t, media.SongDetails)
+	assert.Equal(t, "Harvest Moon", media.SongDetails.SongName)
+	assert.Equal(t, "Harvest Moon", media.SongDetails.AlbumName)
+	assert.Equal(t, []string{"Neil Young"}, media.SongDetails.Artists)
+	assert.Equal(t, int32(1992), media.SongDetails.ReleaseYear)
+	assert.Equal(t, *p.artworkURL("/library/metadata/12/thumb/1700000000"), media.SongDetails.GetAlbumArtUrl())
+
+	standIn.set(trackSession("paused", 75000))
+	require.NoError(t, p.pollSessions(ctx))
+	media = getMedia()
+	assert.Equal(t, trait.Media_PS_PAUSED, media.PlaybackState)
+	assert.Equal(t, 75.0, media.PlaybackPositionS)
+
+	// Polling the paused player again doesn't change when it was last seen.
+	lastSeen := getDevice().LastSeen
+	require.NotNil(t, lastSeen)
+	require.NoError(t, p.pollSessions(ctx))
+	assert.True(t, proto.Equal(lastSeen, getDevice().LastSeen))
+
+	// It is seen again once it changes.
+	standIn.set(trackSession("paused", 80000))
+	require.NoError(t, p.pollSessions(ctx))
+	assert.False(t, proto.Equal(lastSeen, getDevice().LastSeen))
+
+	// Once the session ends the player is stopped, but remains active until the timeout passes.
+	standIn.set("")
+	require.NoError(t, p.pollSessions(ctx))
+	require.NoError(t, p.pollSessions(ctx))
+	media = getMedia()
+	assert.Equal(t, trait.Media_DEVICE_STATE_ACTIVE, media.DeviceState)
+	assert.Equal(t, trait.Media_PS_STOPPED, media.PlaybackState)
+	assert.Equal(t, "Harvest Moon", media.SongDetails.GetSongName())
+
+	p.inactiveTimeout = 0
+	require.NoError(t, p.pollSessions(ctx))
+	media = getMedia()
+	assert.Equal(t, trait.Media_DEVICE_STATE_INACTIVE, media.DeviceState)
+	assert.Nil(t, media.SongDetails)
+
+	// Starting a new session makes the player active again.
+	standIn.set(trackSession("playing", 0))
+	require.NoError(t, p.pollSessions(ctx))
+	assert.Equal(t, trait.Media_DEVICE_STATE_ACTIVE, getMedia().DeviceState)
+}
@@ -46,9 +46,6 @@ func NewClockBridge(logger *zap.Logger, svc *bridge.Service, c *Clock) *ClockBri
 			},
 			Timezone: c.timezone.String(),
 		},
-		State: &api2.Bridge_State{
-			IsPaired: true,
-		},
 	}
 
 	deviceModelName := "Adafruit 7 Segment Display"
@@ -162,9 +159,12 @@ func (cb *ClockBridge) SetBridgeConfig(ctx context.Context, config bridge.Config
 
 	viper.Set("bridge.name", config.Name)
 	viper.Set("bridge.description", config.Description)
-	viper.WriteConfig()
+	return viper.WriteConfig()
+}
 
-	return nil
+// ShowPairingCode displays the code used to pair the bridge with a house on the clock.
+func (cb *ClockBridge) ShowPairingCode(code string) {
+	cb.c.ShowCode(code)
 }
 
 // Refresh is present to conform to the bridge.Handler interface. In this implementation it does nothing
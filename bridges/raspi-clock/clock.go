@@ -33,6 +33,11 @@ type Clock struct {
 	// the current state of whether the clock is on or off
 	isOn bool
 
+	// a channel used to request a code be shown in place of the time
+	codeUpdates chan string
+	// the code being shown; the time is shown if it is empty
+	code string
+
 	// whether we're going to display this in 12 or 24 hour mode
 	timeMode int
 
@@ -50,6 +55,7 @@ func NewClock(d *sevensegment.SevenSegment) *Clock {
 		currBrightness:    100,
 		isOnUpdates:       make(chan bool),
 		isOn:              true,
+		codeUpdates:       make(chan string),
 		timeMode:          TwentyFourHour,
 		timezone:          time.Now().Local().Location(),
 		display:           d,
@@ -71,6 +77,8 @@ func (c *Clock) Run(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			fmt.Printf("context cancelled\n")
+			c.display.Clear()
+			return
 		case brightness := <-c.brightnessUpdates:
 			newBrightness := int(math.Round(float64(brightness) * 0.15))
 
@@ -87,6 +95,14 @@ func (c *Clock) Run(ctx context.Context) {
 				c.isOn = false
 			}
 			// If isOn the next clock ticker will set it appropriately; we don't explicitly set 'on' here.
+		case code := <-c.codeUpdates:
+			c.code = code
+
+			if len(code) > 0 {
+				c.display.DisplayOn()
+			} else if !c.isOn {
+				c.display.Clear()
+			}
 		case <-colonTicker.C:
 			if !colonOn {
 				colonOn = true
@@ -94,7 +110,17 @@ func (c *Clock) Run(ctx context.Context) {
 				colonOn = false
 			}
 		case <-refreshTicker.C:
-			if !c.isOn {
+			if len(c.code) > 0 {
+				// The code is shown even if the display has been turned off, as someone is waiting to enter it.
+				for idx := 0; idx < 4; idx++ {
+					c.display.SetDigit(idx, int(c.code[3-idx]-'0'))
+				}
+				c.display.SetSegments(4, [7]bool{false, false, false, false, false, false, false})
+
+				fmt.Printf("\033[2K\r%s", c.code)
+				c.display.WriteData()
+				continue
+			} else if !c.isOn {
 				continue
 			}
 
@@ -185,6 +211,11 @@ func (c *Clock) SetTimeZone(tz string) error {
 	return nil
 }
 
+// ShowCode displays the supplied 4 digit code in place of the time, until it is called with an empty code.
+func (c *Clock) ShowCode(code string) {
+	c.codeUpdates <- code
+}
+
 // SetOnOff changes whether the clock display is on or off
 func (c *Clock) SetOnOff(isOn bool) {
 	c.isOnUpdates <- isOn
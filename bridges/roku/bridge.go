@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/picatz/roku"
@@ -19,7 +20,35 @@ import (
 	"github.com/rmrobinson/house/service/bridge"
 )
 
-func rokuStateToDevice(info *roku.DeviceInfo, apps roku.Apps, activeApp *roku.App) *device.Device {
+var playbackStates = map[string]trait.Media_PlaybackState{
+	"startup":  trait.Media_PS_BUFFERING,
+	"open":     trait.Media_PS_BUFFERING,
+	"buffer":   trait.Media_PS_BUFFERING,
+	"play":     trait.Media_PS_PLAYING,
+	"pause":    trait.Media_PS_PAUSED,
+	"stop":     trait.Media_PS_STOPPED,
+	"finished": trait.Media_PS_COMPLETED,
+}
+
+// mediaPlayerToMedia converts the state of the media player; the player is inactive when no media is open.
+func mediaPlayerToMedia(player *mediaPlayer) *trait.Media {
+	media := &trait.Media{
+		Attributes: &trait.Media_Attributes{},
+		State: &trait.Media_State{
+			DeviceState: trait.Media_DEVICE_STATE_INACTIVE,
+		},
+	}
+
+	if playbackState, found := playbackStates[player.State]; found {
+		media.State.DeviceState = trait.Media_DEVICE_STATE_ACTIVE
+		media.State.PlaybackState = playbackState
+		media.State.PlaybackLengthS = player.length().Seconds()
+		media.State.PlaybackPositionS = player.position().Seconds()
+	}
+	return media
+}
+
+func rokuStateToDevice(info *roku.DeviceInfo, apps roku.Apps, activeApp *roku.App, player *mediaPlayer, lastSeen time.Time) *device.Device {
 	inputTrait := &trait.Input{
 		Attributes: &trait.Input_Attributes{
 			CanControl: true,
@@ -35,6 +64,22 @@ func rokuStateToDevice(info *roku.DeviceInfo, apps roku.Apps, activeApp *roku.Ap
 		},
 		State: &trait.App_State{},
 	}
+	// Only TVs can be turned on and off; other Rokus report that they are always on.
+	onOffTrait := &trait.OnOff{
+		Attributes: &trait.OnOff_Attributes{
+			CanControl: info.IsTv == "true",
+		},
+		State: &trait.OnOff_State{
+			IsOn: info.PowerMode == powerModeOn,
+		},
+	}
+
+	remoteTrait := &trait.Remote{
+		Attributes: &trait.Remote_Attributes{
+			CanControl:        true,
+			SupportsTextInput: true,
+		},
+	}
 
 	for _, app := range apps {
 		if strings.HasPrefix(app.Name, roku.TVInput) {
@@ -54,6 +99,11 @@ func rokuStateToDevice(info *roku.DeviceInfo, apps roku.Apps, activeApp *roku.Ap
 		appTrait.State.ApplicationId = activeApp.ID
 	}
 
+	var mediaTrait *trait.Media
+	if player != nil {
+		mediaTrait = mediaPlayerToMedia(player)
+	}
+
 	var modelName *string
 	if len(info.FriendlyModelName) > 0 {
 		modelName = &info.FriendlyModelName
@@ -74,19 +124,55 @@ func rokuStateToDevice(info *roku.DeviceInfo, apps roku.Apps, activeApp *roku.Ap
 		Config: &device.Device_Config{
 			Name: info.UserDeviceName,
 		},
-		LastSeen: timestamppb.Now(),
+		LastSeen: timestamppb.New(lastSeen),
 		Details: &device.Device_Television{
 			Television: &device.Television{
-				OnOff:  nil, // We don't know the on/off state of the TV
-				Volume: nil, // We don't know the volume of the TV
+				OnOff:  onOffTrait,
+				Volume: nil, // ECP doesn't report the volume of the TV
 				Input:  inputTrait,
 				App:    appTrait,
-				Media:  nil, // TODO: determine media info, if available
+				Media:  mediaTrait,
+				Remote: remoteTrait,
 			},
 		},
 	}
 }
 
+// rokuDevice is the last known state of a Roku found on the network.
+type rokuDevice struct {
+	endpoint  *roku.Endpoint
+	info      *roku.DeviceInfo
+	apps      roku.Apps
+	activeApp *roku.App
+	player    *mediaPlayer
+
+	// lastSeen is updated when the device is discovered, rather than each time it is polled, so polling only
+	// publishes an update when the state of the device has changed.
+	lastSeen   time.Time
+	lastPolled time.Time
+}
+
+// active returns whether the TV is on or media is being played, and so whether the state is likely to change soon.
+func (rd *rokuDevice) active() bool {
+	if rd.player != nil {
+		if _, playing := playbackStates[rd.player.State]; playing {
+			return true
+		}
+	}
+	return rd.info.IsTv == "true" && rd.info.PowerMode == powerModeOn
+}
+
+func (rd *rokuDevice) toDevice() *device.Device {
+	return rokuStateToDevice(rd.info, rd.apps, rd.activeApp, rd.player, rd.lastSeen)
+}
+
+// rokuState is the part of the state of a Roku which is polled for.
+type rokuState struct {
+	info      *roku.DeviceInfo
+	activeApp *roku.App
+	player    *mediaPlayer
+}
+
 // RokuBridge monitors the network for Roku advertisements and uses the ECP API to perform basic operations.
 // There only needs to be one bridge per network, as it will listen for the advertisements and forward all devices.
 type RokuBridge struct {
@@ -94,11 +180,16 @@ type RokuBridge struct {
 	svc    *bridge.Service
 	b      *api2.Bridge
 
-	endpoints map[string]*roku.Endpoint
+	// pollInterval is how often active devices are polled for their state; idlePollInterval is used for the others.
+	pollInterval     time.Duration
+	idlePollInterval time.Duration
+
+	lock    sync.Mutex
+	devices map[string]*rokuDevice
 }
 
 // NewRokuBridge creates a new Roku bridge
-func NewRokuBridge(logger *zap.Logger, svc *bridge.Service) *RokuBridge {
+func NewRokuBridge(logger *zap.Logger, svc *bridge.Service, pollInterval time.Duration, idlePollInterval time.Duration) *RokuBridge {
 	b := &api2.Bridge{
 		Id:           viper.GetString("bridge.id"),
 		IsReachable:  true,
@@ -114,28 +205,118 @@ func NewRokuBridge(logger *zap.Logger, svc *bridge.Service) *RokuBridge {
 	}
 
 	return &RokuBridge{
-		logger:    logger,
-		svc:       svc,
-		b:         b,
-		endpoints: map[string]*roku.Endpoint{},
+		logger:           logger,
+		svc:              svc,
+		b:                b,
+		pollInterval:     pollInterval,
+		idlePollInterval: idlePollInterval,
+		devices:          map[string]*rokuDevice{},
 	}
 }
 
 // ProcessCommand takes a given command request and attempts to execute it.
 // We only worry about processing valid commands for the given device traits.
 func (rb *RokuBridge) ProcessCommand(ctx context.Context, cmd *command.Command) (*device.Device, error) {
+	rb.lock.Lock()
+	rd, found := rb.devices[cmd.DeviceId]
+	rb.lock.Unlock()
+	if !found {
+		return nil, bridge.ErrDeviceNotFound
+	}
+
+	switch details := cmd.Details.(type) {
+	case *command.Command_OnOff:
+		return rb.setPower(ctx, rd, details.OnOff.On)
+	case *command.Command_RemoteKey:
+		return rb.pressRemoteKey(ctx, rd, details.RemoteKey)
+	case *command.Command_TextInput:
+		return rb.inputText(ctx, rd, details.TextInput.Text)
+	}
+
 	rb.logger.Error("received unsupported command - shouldn't happen")
 	return nil, bridge.ErrUnsupportedCommand
 }
 
+// setPower turns the TV on or off using the power keys of the remote.
+func (rb *RokuBridge) setPower(ctx context.Context, rd *rokuDevice, on bool) (*device.Device, error) {
+	key := powerOffKey
+	if on {
+		key = powerOnKey
+	}
+	if err := keypress(ctx, rd.endpoint, key); err != nil {
+		rb.logger.Error("unable to set roku power",
+			zap.Error(err), zap.String("endpoint", rd.endpoint.String()))
+		return nil, status.Error(codes.Internal, "unable to set roku power")
+	}
+
+	rb.lock.Lock()
+	defer rb.lock.Unlock()
+
+	// The TV takes a moment to change its power mode, so the expected mode is reported until it is next polled.
+	info := *rd.info
+	if on {
+		info.PowerMode = powerModeOn
+	} else {
+		info.PowerMode = powerModeDisplayOff
+	}
+	rd.info = &info
+	return rd.toDevice(), nil
+}
+
+// pressRemoteKey presses, holds or releases the requested key of the remote.
+func (rb *RokuBridge) pressRemoteKey(ctx context.Context, rd *rokuDevice, remoteKey *command.RemoteKey) (*device.Device, error) {
+	key, found := remoteKeys[remoteKey.Key]
+	if !found {
+		return nil, status.Error(codes.InvalidArgument, "unsupported remote key")
+	} else if remoteKey.Repeat < 0 || remoteKey.Repeat > maxKeyRepeat {
+		return nil, status.Errorf(codes.InvalidArgument, "repeat must be between 0 and %d", maxKeyRepeat)
+	}
+
+	var err error
+	switch remoteKey.Action {
+	case command.RemoteKey_ACTION_DOWN:
+		err = keyDown(ctx, rd.endpoint, key)
+	case command.RemoteKey_ACTION_UP:
+		err = keyUp(ctx, rd.endpoint, key)
+	default:
+		for i := int32(0); i < max(remoteKey.Repeat, 1) && err == nil; i++ {
+			err = keypress(ctx, rd.endpoint, key)
+		}
+	}
+	if err != nil {
+		rb.logger.Error("unable to press roku key",
+			zap.Error(err), zap.String("endpoint", rd.endpoint.String()), zap.String("key", key))
+		return nil, status.Error(codes.Internal, "unable to press roku key")
+	}
+
+	rb.lock.Lock()
+	defer rb.lock.Unlock()
+	return rd.toDevice(), nil
+}
+
+// inputText enters the supplied text into the field which has focus on the Roku.
+func (rb *RokuBridge) inputText(ctx context.Context, rd *rokuDevice, text string) (*device.Device, error) {
+	if len(text) < 1 {
+		return nil, status.Error(codes.InvalidArgument, "text must be supplied")
+	}
+
+	if err := typeText(ctx, rd.endpoint, text); err != nil {
+		rb.logger.Error("unable to input roku text",
+			zap.Error(err), zap.String("endpoint", rd.endpoint.String()))
+		return nil, status.Error(codes.Internal, "unable to input roku text")
+	}
+
+	rb.lock.Lock()
+	defer rb.lock.Unlock()
+	return rd.toDevice(), nil
+}
+
 // SetBridgeConfig takes the supplied config params and saves them for future reference.
 func (rb *RokuBridge) SetBridgeConfig(ctx context.Context, config bridge.Config) error {
 	rb.b.Config.Description = config.Description
 
 	viper.Set("bridge.description", config.Description)
-	viper.WriteConfig()
-
-	return nil
+	return viper.WriteConfig()
 }
 
 // Refresh is present to conform to the bridge.Handler interface. In this implementation it
@@ -151,51 +332,135 @@ func (rb *RokuBridge) Refresh(ctx context.Context) error {
 	foundEndpoints := map[string]bool{}
 
 	for _, endpoint := range endpoints {
-		info, err := endpoint.DeviceInfo()
-		if err != nil {
-			rb.logger.Error("unable to get roku device info",
-				zap.Error(err), zap.String("endpoint", endpoint.String()))
-			continue
-		}
-		apps, err := endpoint.Apps()
+		rd, err := rb.discover(ctx, endpoint)
 		if err != nil {
-			rb.logger.Error("unable to get roku apps",
-				zap.Error(err), zap.String("endpoint", endpoint.String()))
 			continue
 		}
-		activeApp, err := endpoint.ActiveApp()
-		if err != nil {
-			rb.logger.Error("unable to get roku active app",
-				zap.Error(err), zap.String("endpoint", endpoint.String()))
-			continue
-		}
-
-		rb.svc.UpdateDevice(rokuStateToDevice(info, apps, activeApp))
-		foundEndpoints[info.DeviceID] = true
-		rb.endpoints[info.DeviceID] = endpoint
+		foundEndpoints[rd.info.DeviceID] = true
 	}
 
-	for existingEndpointID := range rb.endpoints {
+	rb.lock.Lock()
+	defer rb.lock.Unlock()
+
+	for existingEndpointID := range rb.devices {
 		if _, found := foundEndpoints[existingEndpointID]; !found {
 			rb.logger.Info("device id not found in set of endpoints, removing", zap.String("device_id", existingEndpointID))
 			rb.svc.RemoveDevice(existingEndpointID)
+			delete(rb.devices, existingEndpointID)
 		}
 	}
 
 	return nil
 }
 
+// discover retrieves the full state of a Roku found on the network, including its installed apps, and reports it.
+func (rb *RokuBridge) discover(ctx context.Context, endpoint *roku.Endpoint) (*rokuDevice, error) {
+	state, err := rb.getState(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	apps, err := endpoint.Apps()
+	if err != nil {
+		rb.logger.Error("unable to get roku apps",
+			zap.Error(err), zap.String("endpoint", endpoint.String()))
+		return nil, err
+	}
+
+	rd := &rokuDevice{
+		endpoint:   endpoint,
+		info:       state.info,
+		apps:       apps,
+		activeApp:  state.activeApp,
+		player:     state.player,
+		lastSeen:   time.Now(),
+		lastPolled: time.Now(),
+	}
+
+	rb.lock.Lock()
+	defer rb.lock.Unlock()
+
+	rb.devices[rd.info.DeviceID] = rd
+	rb.svc.UpdateDevice(rd.toDevice())
+	return rd, nil
+}
+
+// getState retrieves the power mode, active app and media player state of the Roku.
+func (rb *RokuBridge) getState(ctx context.Context, endpoint *roku.Endpoint) (*rokuState, error) {
+	info, err := endpoint.DeviceInfo()
+	if err != nil {
+		rb.logger.Error("unable to get roku device info",
+			zap.Error(err), zap.String("endpoint", endpoint.String()))
+		return nil, err
+	}
+	activeApp, err := endpoint.ActiveApp()
+	if err != nil {
+		rb.logger.Error("unable to get roku active app",
+			zap.Error(err), zap.String("endpoint", endpoint.String()))
+		return nil, err
+	}
+	player, err := queryMediaPlayer(ctx, endpoint)
+	if err != nil {
+		rb.logger.Error("unable to get roku media player",
+			zap.Error(err), zap.String("endpoint", endpoint.String()))
+		return nil, err
+	}
+
+	return &rokuState{
+		info:      info,
+		activeApp: activeApp,
+		player:    player,
+	}, nil
+}
+
+// poll updates the state of the devices which are active, and of the others once the idle poll interval has passed.
+// Devices which can't be reached are left for the next discovery to remove.
+func (rb *RokuBridge) poll(ctx context.Context) {
+	rb.lock.Lock()
+	var due []*rokuDevice
+	for _, rd := range rb.devices {
+		if rd.active() || time.Since(rd.lastPolled) >= rb.idlePollInterval {
+			due = append(due, rd)
+		}
+	}
+	rb.lock.Unlock()
+
+	for _, rd := range due {
+		state, err := rb.getState(ctx, rd.endpoint)
+		if err != nil {
+			continue
+		}
+
+		rb.lock.Lock()
+		rd.info = state.info
+		rd.activeApp = state.activeApp
+		rd.player = state.player
+		rd.lastPolled = time.Now()
+		// The device may have been removed, or replaced by a newer discovery, while it was being polled.
+		if rb.devices[rd.info.DeviceID] == rd {
+			rb.svc.UpdateDevice(rd.toDevice())
+		}
+		rb.lock.Unlock()
+	}
+}
+
 // Run begins the process of rerunning the Roku discovery process on an interval and updating the device cache.
+// In between, the state of the devices which have been found is polled.
 func (rb *RokuBridge) Run(ctx context.Context) {
 	refreshTimer := time.NewTicker(time.Minute * 5)
+	defer refreshTimer.Stop()
+	pollTimer := time.NewTicker(rb.pollInterval)
+	defer pollTimer.Stop()
+
 	for {
 		select {
 		case <-refreshTimer.C:
-			if err := rb.Refresh(ctx); err != nil {
+			if err := rb.svc.Refresh(ctx); err != nil {
 				rb.logger.Error("unable to refresh roku state",
 					zap.Error(err))
 				continue
 			}
+		case <-pollTimer.C:
+			rb.poll(ctx)
 		case <-ctx.Done():
 			rb.logger.Info("run context cancelled")
 			return
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/picatz/roku"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/api/command"
+	"github.com/rmrobinson/house/api/trait"
+	"github.com/rmrobinson/house/service/bridge"
+)
+
+// ecpStandIn answers the ECP queries of a Roku TV, and records the keys pressed on it by their paths.
+type ecpStandIn struct {
+	lock        sync.Mutex
+	powerMode   string
+	playerState string
+	position    int
+	keys        []string
+}
+
+func (s *ecpStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// The library joins its paths to the discovered location, which ends in a slash.
+	switch path := "/" + strings.TrimLeft(r.URL.Path, "/"); {
+	case path == "/query/device-info":
+		fmt.Fprintf(w, `<device-info><udn>29600009-8c02-10a6-80c5-c83ac1fc8bc4</udn><device-id>X00400ABCDEF</device-id>`+
+			`<vendor-name>TCL</vendor-name><model-name>65R635</model-name><user-device-name>Lounge TV</user-device-name>`+
+			`<is-tv>true</is-tv><power-mode>%s</power-mode></device-info>`, s.powerMode)
+	case path == "/query/apps":
+		fmt.Fprint(w, `<apps><app id="12" type="appl" version="5.1.1">Netflix</app><app id="tvinput.hdmi1" type="tvin" version="1.0.0">HDMI 1</app></apps>`)
+	case path == "/query/active-app":
+		fmt.Fprint(w, `<active-app><app id="12" type="appl" version="5.1.1">Netflix</app></active-app>`)
+	case path == "/query/media-player":
+		fmt.Fprintf(w, `<player error="false" state="%s"><plugin bandwidth="0 bps" id="12" name="Netflix"/>`+
+			`<position>%d ms</position><duration>2520000 ms</duration><is_live>false</is_live></player>`, s.playerState, s.position)
+	case r.Method == http.MethodPost && (strings.HasPrefix(path, "/keypress/") || strings.HasPrefix(path, "/keydown/") || strings.HasPrefix(path, "/keyup/")):
+		s.keys = append(s.keys, r.URL.EscapedPath())
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *ecpStandIn) set(powerMode string, playerState string, position int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.powerMode = powerMode
+	s.playerState = playerState
+	s.position = position
+}
+
+func TestPoll(t *testing.T) {
+	standIn := &ecpStandIn{powerMode: powerModeOn, playerState: "play", position: 60000}
+	srv := httptest.NewServer(standIn)
+	defer srv.Close()
+
+	logger := zaptest.NewLogger(t)
+	svc := bridge.NewService(logger)
+	rb := NewRokuBridge(logger, svc, time.Second, time.Hour)
+	svc.RegisterHandler(rb, rb.b)
+
+	ctx := context.Background()
+	_, err := rb.discover(ctx, roku.NewEndpoint(srv.URL+"/"))
+	require.NoError(t, err)
+
+	tv, err := svc.API().GetDevice(ctx, &api2.GetDeviceRequest{Id: "X00400ABCDEF"})
+	require.NoError(t, err)
+	assert.True(t, tv.GetTelevision().GetOnOff().GetState().IsOn)
+	media := tv.GetTelevision().GetMedia().GetState()
+	assert.Equal(t, trait.Media_DEVICE_STATE_ACTIVE, media.DeviceState)
+	assert.Equal(t, trait.Media_PS_PLAYING, media.PlaybackState)
+	assert.Equal(t, 60.0, media.PlaybackPositionS)
+	assert.Equal(t, 2520.0, media.PlaybackLengthS)
+
+	// An active TV is polled for its state between discoveries.
+	standIn.set(powerModeOn, "pause", 75000)
+	rb.poll(ctx)
+
+	tv, err = svc.API().GetDevice(ctx, &api2.GetDeviceRequest{Id: "X00400ABCDEF"})
+	require.NoError(t, err)
+	media = tv.GetTelevision().GetMedia().GetState()
+	assert.Equal(t, trait.Media_PS_PAUSED, media.PlaybackState)
+	assert.Equal(t, 75.0, media.PlaybackPositionS)
+
+	// Turning the TV off presses the power off key.
+	tv, err = svc.API().ExecuteCommand(ctx, &command.Command{
+		DeviceId: "X00400ABCDEF",
+		Details:  &command.Command_OnOff{OnOff: &command.OnOff{On: false}},
+	})
+	require.NoError(t, err)
+	assert.False(t, tv.GetTelevision().GetOnOff().GetState().IsOn)
+	assert.Equal(t, []string{"/keypress/PowerOff"}, standIn.keys)
+
+	// Once off and closed, the TV is only polled after the idle interval.
+	standIn.set(powerModeDisplayOff, "close", 0)
+	rb.poll(ctx)
+	standIn.set(powerModeOn, "close", 0)
+	rb.poll(ctx)
+
+	tv, err = svc.API().GetDevice(ctx, &api2.GetDeviceRequest{Id: "X00400ABCDEF"})
+	require.NoError(t, err)
+	assert.False(t, tv.GetTelevision().GetOnOff().GetState().IsOn)
+	assert.Equal(t, trait.Media_DEVICE_STATE_INACTIVE, tv.GetTelevision().GetMedia().GetState().DeviceState)
+}
+
+func TestRemote(t *testing.T) {
+	standIn := &ecpStandIn{powerMode: powerModeOn, playerState: "close"}
+	srv := httptest.NewServer(standIn)
+	defer srv.Close()
+
+	logger := zaptest.NewLogger(t)
+	svc := bridge.NewService(logger)
+	rb := NewRokuBridge(logger, svc, time.Second, time.Hour)
+	svc.RegisterHandler(rb, rb.b)
+
+	ctx := context.Background()
+	_, err := rb.discover(ctx, roku.NewEndpoint(srv.URL))
+	require.NoError(t, err)
+
+	cmds := []*command.Command{
+		{Details: &command.Command_RemoteKey{RemoteKey: &command.RemoteKey{Key: command.RemoteKey_KEY_DOWN, Repeat: 2}}},
+		{Details: &command.Command_RemoteKey{RemoteKey: &command.RemoteKey{Key: command.RemoteKey_KEY_SELECT}}},
+		{Details: &command.Command_TextInput{TextInput: &command.TextInput{Text: "a b"}}},
+		{Details: &command.Command_RemoteKey{RemoteKey: &command.RemoteKey{Key: command.RemoteKey_KEY_FAST_FORWARD, Action: command.RemoteKey_ACTION_DOWN}}},
+		{Details: &command.Command_RemoteKey{RemoteKey: &command.RemoteKey{Key: command.RemoteKey_KEY_FAST_FORWARD, Action: command.RemoteKey_ACTION_UP}}},
+	}
+	for _, cmd := range cmds {
+		cmd.DeviceId = "X00400ABCDEF"
+		_, err := svc.API().ExecuteCommand(ctx, cmd)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{
+		"/keypress/Down",
+		"/keypress/Down",
+		"/keypress/Select",
+		"/keypress/Lit_a",
+		"/keypress/Lit_%20",
+		"/keypress/Lit_b",
+		"/keydown/Fwd",
+		"/keyup/Fwd",
+	}, standIn.keys)
+
+	_, err = svc.API().ExecuteCommand(ctx, &command.Command{
+		DeviceId: "X00400ABCDEF",
+		Details:  &command.Command_RemoteKey{RemoteKey: &command.RemoteKey{Key: command.RemoteKey_KEY_UNSPECIFIED}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
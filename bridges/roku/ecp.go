@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/picatz/roku"
+
+	"github.com/rmrobinson/house/api/command"
+)
+
+const (
+	// powerModeOn is the power mode reported by a TV which is displaying a picture.
+	powerModeOn = "PowerOn"
+	// powerModeDisplayOff is the power mode reported by a TV which has been turned off but can still be woken.
+	powerModeDisplayOff = "DisplayOff"
+
+	powerOnKey  = "PowerOn"
+	powerOffKey = "PowerOff"
+
+	// maxKeyRepeat limits how many times a key can be pressed by a single command.
+	maxKeyRepeat = 50
+
+	// ecpTimeout limits how long each ECP request may take, so an unresponsive Roku doesn't hold up the bridge.
+	ecpTimeout = 5 * time.Second
+)
+
+// remoteKeys maps the keys of the RemoteKey command to their ECP names.
+var remoteKeys = map[command.RemoteKey_Key]string{
+	command.RemoteKey_KEY_HOME:           roku.HomeKey,
+	command.RemoteKey_KEY_BACK:           roku.BackKey,
+	command.RemoteKey_KEY_UP:             roku.UpKey,
+	command.RemoteKey_KEY_DOWN:           roku.DownKey,
+	command.RemoteKey_KEY_LEFT:           roku.LeftKey,
+	command.RemoteKey_KEY_RIGHT:          roku.RightKey,
+	command.RemoteKey_KEY_SELECT:         roku.SelectKey,
+	command.RemoteKey_KEY_INSTANT_REPLAY: roku.InstantReplayKey,
+	command.RemoteKey_KEY_INFO:           roku.InfoKey,
+	command.RemoteKey_KEY_PLAY:           roku.PlayKey,
+	command.RemoteKey_KEY_REWIND:         roku.RevKey,
+	command.RemoteKey_KEY_FAST_FORWARD:   roku.FwdKey,
+	command.RemoteKey_KEY_BACKSPACE:      roku.BackspaceKey,
+	command.RemoteKey_KEY_SEARCH:         roku.SearchKey,
+	command.RemoteKey_KEY_ENTER:          roku.EnterKey,
+	command.RemoteKey_KEY_VOLUME_UP:      roku.VolumeUpKey,
+	command.RemoteKey_KEY_VOLUME_DOWN:    roku.VolumeDownKey,
+	command.RemoteKey_KEY_VOLUME_MUTE:    roku.VolumeMuteKey,
+	command.RemoteKey_KEY_CHANNEL_UP:     roku.ChannelUpKey,
+	command.RemoteKey_KEY_CHANNEL_DOWN:   roku.ChannelDownKey,
+}
+
+// mediaPlayer is the state of the media player of a Roku, as returned by the ECP media-player query.
+type mediaPlayer struct {
+	// State is one of close, open, startup, play, pause, buffer, stop or finished.
+	State  string `xml:"state,attr"`
+	Error  bool   `xml:"error,attr"`
+	Plugin struct {
+		ID   string `xml:"id,attr"`
+		Name string `xml:"name,attr"`
+	} `xml:"plugin"`
+	// Position and Duration are reported in milliseconds, i.e. "12345 ms".
+	Position string `xml:"position"`
+	Duration string `xml:"duration"`
+	IsLive   bool   `xml:"is_live"`
+}
+
+// position returns the current position of the media.
+func (mp *mediaPlayer) position() time.Duration {
+	return parseMillis(mp.Position)
+}
+
+// length returns the length of the media; it is zero for live streams.
+func (mp *mediaPlayer) length() time.Duration {
+	return parseMillis(mp.Duration)
+}
+
+func parseMillis(value string) time.Duration {
+	ms, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "ms")), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// ecpURL returns the URL of the supplied ECP path on the endpoint.
+// The library doesn't accept a context, so these requests are made directly to keep them part of the calling trace.
+func ecpURL(endpoint *roku.Endpoint, path string) string {
+	return strings.TrimSuffix(endpoint.String(), "/") + path
+}
+
+// queryMediaPlayer retrieves the state of the media player of the endpoint.
+func queryMediaPlayer(ctx context.Context, endpoint *roku.Endpoint) (*mediaPlayer, error) {
+	ctx, cancel := context.WithTimeout(ctx, ecpTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ecpURL(endpoint, "/query/media-player"), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	player := &mediaPlayer{}
+	if err := xml.NewDecoder(resp.Body).Decode(player); err != nil {
+		return nil, err
+	}
+	return player, nil
+}
+
+// keypress simulates pressing and releasing the specified key on the remote.
+func keypress(ctx context.Context, endpoint *roku.Endpoint, key string) error {
+	return ecpPost(ctx, endpoint, "/keypress/"+key)
+}
+
+// keyDown simulates holding down the specified key until keyUp is called.
+// The library's KeyDown can't be used as it releases the key instead.
+func keyDown(ctx context.Context, endpoint *roku.Endpoint, key string) error {
+	return ecpPost(ctx, endpoint, "/keydown/"+key)
+}
+
+func keyUp(ctx context.Context, endpoint *roku.Endpoint, key string) error {
+	return ecpPost(ctx, endpoint, "/keyup/"+key)
+}
+
+// typeText enters the supplied text into the field with focus, one character at a time.
+func typeText(ctx context.Context, endpoint *roku.Endpoint, text string) error {
+	for _, r := range text {
+		if err := keypress(ctx, endpoint, roku.LiteralKey(url.PathEscape(string(r)))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ecpPost(ctx context.Context, endpoint *roku.Endpoint, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, ecpTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ecpURL(endpoint, path), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
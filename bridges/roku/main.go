@@ -2,6 +2,11 @@ package main
 
 import (
 	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -9,6 +14,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/rmrobinson/house/service/bridge"
+	"github.com/rmrobinson/house/service/tracing"
 )
 
 func main() {
@@ -21,6 +27,9 @@ func main() {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("$HOME/.config/house")
 	viper.AddConfigPath(".")
+	viper.SetDefault("pairing.file", "$HOME/.config/house/roku-pairing.json")
+	viper.SetDefault("roku.poll_interval", 5*time.Second)
+	viper.SetDefault("roku.idle_poll_interval", time.Minute)
 
 	if err := viper.ReadInConfig(); err != nil {
 		logger.Fatal("unable to read config", zap.Error(err))
@@ -40,10 +49,17 @@ func main() {
 		}
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The roku library makes its requests using the default client; these can't be part of the calling trace as the
+	// library doesn't accept a context, but they are still recorded.
+	http.DefaultClient.Transport = tracing.Transport(http.DefaultTransport)
+
 	svc := bridge.NewService(logger)
 
-	rb := NewRokuBridge(logger, svc)
-	if err := rb.Refresh(context.Background()); err != nil {
+	rb := NewRokuBridge(logger, svc, viper.GetDuration("roku.poll_interval"), viper.GetDuration("roku.idle_poll_interval"))
+	if err := rb.Refresh(ctx); err != nil {
 		logger.Fatal("unable to refresh bridge", zap.Error(err))
 	}
 
@@ -51,11 +67,19 @@ func main() {
 	svc.RegisterHandler(rb, rb.b)
 
 	// Check for updates periodically
-	runCtx, runCtxCancel := context.WithCancel(context.Background())
-	defer runCtxCancel()
+	go rb.Run(ctx)
 
-	go rb.Run(runCtx)
+	var serverConfig bridge.ServerConfig
+	if err := viper.Unmarshal(&serverConfig); err != nil {
+		logger.Fatal("unable to parse 'tls' and 'auth' keys from config", zap.Error(err))
+	}
+	serverOpts, err := bridge.ServerOptions(logger, svc, serverConfig)
+	if err != nil {
+		logger.Fatal("unable to configure server", zap.Error(err))
+	}
 
-	s := bridge.NewServer(logger, svc)
-	s.Serve()
+	s := bridge.NewServer(logger, svc, serverOpts...)
+	if err := s.Serve(ctx); err != nil {
+		logger.Fatal("unable to serve", zap.Error(err))
+	}
 }
@@ -42,9 +42,6 @@ func NewChargerBridge(logger *zap.Logger, svc *bridge.Service, charger *Charger)
 				},
 			},
 		},
-		State: &api2.Bridge_State{
-			IsPaired: true,
-		},
 	}
 
 	cb := &ChargerBridge{
@@ -71,15 +68,13 @@ func (cb *ChargerBridge) SetBridgeConfig(ctx context.Context, config bridge.Conf
 
 	viper.Set("bridge.name", config.Name)
 	viper.Set("bridge.description", config.Description)
-	viper.WriteConfig()
-
-	return nil
+	return viper.WriteConfig()
 }
 
 // Refresh is present to conform to the bridge.Handler interface. In this implementation it queries
 // the charger API and returns the current state of the charger.
 func (cb *ChargerBridge) Refresh(ctx context.Context) error {
-	chargerState, err := cb.charger.State()
+	chargerState, err := cb.charger.State(ctx)
 	if err != nil {
 		cb.logger.Error("unable to get charger state",
 			zap.Error(err))
@@ -90,20 +85,17 @@ func (cb *ChargerBridge) Refresh(ctx context.Context) error {
 	return nil
 }
 
-// Run begins the process of polling the charger API and reporting back the state.
-func (cb *ChargerBridge) Run() {
+// Run begins the process of polling the charger API and reporting back the state, until the context is cancelled.
+func (cb *ChargerBridge) Run(ctx context.Context) {
 	refreshTimer := time.NewTicker(time.Minute * 5)
+	defer refreshTimer.Stop()
+
 	for {
 		select {
 		case <-refreshTimer.C:
-			chargerState, err := cb.charger.State()
-			if err != nil {
-				cb.logger.Error("unable to get charger state",
-					zap.Error(err))
-				continue
-			}
-
-			cb.svc.UpdateDevice(chargerState.toDevice())
+			cb.svc.Refresh(ctx)
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -158,8 +159,8 @@ func NewCharger(logger *zap.Logger, chargerIP string, client *http.Client) *Char
 	}
 }
 
-func (c *Charger) getDataFromAPI(path string, apiPayload interface{}) error {
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s%s", c.ipAddr, path), nil)
+func (c *Charger) getDataFromAPI(ctx context.Context, path string, apiPayload interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s%s", c.ipAddr, path), nil)
 	if err != nil {
 		c.logger.Error("unable to create http request for vitals",
 			zap.Error(err))
@@ -202,20 +203,20 @@ func (c *Charger) getDataFromAPI(path string, apiPayload interface{}) error {
 }
 
 // State queries the charger and returns its current state.
-func (c *Charger) State() (*ChargerState, error) {
+func (c *Charger) State(ctx context.Context) (*ChargerState, error) {
 	vitals := &vitalAPIResponse{}
 	lifetime := &lifetimeAPIResponse{}
 	version := &versionAPIResponse{}
 
-	if err := c.getDataFromAPI("/api/1/vitals", vitals); err != nil {
+	if err := c.getDataFromAPI(ctx, "/api/1/vitals", vitals); err != nil {
 		c.logger.Error("unable to query vitals api", zap.Error(err))
 		return nil, err
 	}
-	if err := c.getDataFromAPI("/api/1/lifetime", lifetime); err != nil {
+	if err := c.getDataFromAPI(ctx, "/api/1/lifetime", lifetime); err != nil {
 		c.logger.Error("unable to query lifetime api", zap.Error(err))
 		return nil, err
 	}
-	if err := c.getDataFromAPI("/api/1/version", version); err != nil {
+	if err := c.getDataFromAPI(ctx, "/api/1/version", version); err != nil {
 		c.logger.Error("unable to query version api", zap.Error(err))
 		return nil, err
 	}
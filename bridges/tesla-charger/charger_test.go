@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -28,7 +29,7 @@ func TestRefreshCachedValuesWhileCharging(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 	charger := NewCharger(logger, strings.TrimPrefix(srv.URL, "http://"), &http.Client{})
 
-	state, err := charger.State()
+	state, err := charger.State(context.Background())
 	if err != nil {
 		t.Errorf("unable to refresh cached values; got err %s\n", err.Error())
 	} else if state.vitals == nil || state.lifetime == nil || state.version == nil {
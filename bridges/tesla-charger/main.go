@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"go.uber.org/zap"
 
@@ -9,6 +13,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/rmrobinson/house/service/bridge"
+	"github.com/rmrobinson/house/service/tracing"
 )
 
 func main() {
@@ -21,6 +26,7 @@ func main() {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("$HOME/.config/house")
 	viper.AddConfigPath(".")
+	viper.SetDefault("pairing.file", "$HOME/.config/house/tesla-charger-pairing.json")
 
 	if err := viper.ReadInConfig(); err != nil {
 		logger.Fatal("unable to read config", zap.Error(err))
@@ -40,6 +46,9 @@ func main() {
 		}
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	svc := bridge.NewService(logger)
 
 	chargerIP := viper.GetString("charger.ip")
@@ -47,10 +56,10 @@ func main() {
 		logger.Fatal("charger.ip must be set in the config")
 	}
 
-	charger := NewCharger(logger, chargerIP, &http.Client{})
+	charger := NewCharger(logger, chargerIP, &http.Client{Transport: tracing.Transport(nil)})
 	cb := NewChargerBridge(logger, svc, charger)
 
-	state, err := charger.State()
+	state, err := charger.State(ctx)
 	if err != nil {
 		logger.Fatal("unable to get state from charger", zap.Error(err))
 	}
@@ -60,8 +69,19 @@ func main() {
 	svc.UpdateDevice(state.toDevice())
 
 	// Check for updates periodically
-	go cb.Run()
+	go cb.Run(ctx)
+
+	var serverConfig bridge.ServerConfig
+	if err := viper.Unmarshal(&serverConfig); err != nil {
+		logger.Fatal("unable to parse 'tls' and 'auth' keys from config", zap.Error(err))
+	}
+	serverOpts, err := bridge.ServerOptions(logger, svc, serverConfig)
+	if err != nil {
+		logger.Fatal("unable to configure server", zap.Error(err))
+	}
 
-	s := bridge.NewServer(logger, svc)
-	s.Serve()
+	s := bridge.NewServer(logger, svc, serverOpts...)
+	if err := s.Serve(ctx); err != nil {
+		logger.Fatal("unable to serve", zap.Error(err))
+	}
 }
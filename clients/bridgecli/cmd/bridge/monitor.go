@@ -37,7 +37,5 @@ var monitorCmd = &cobra.Command{
 
 			spew.Dump(msg)
 		}
-
-		return nil
 	},
 }
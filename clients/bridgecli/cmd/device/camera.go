@@ -0,0 +1,62 @@
+package device
+
+import (
+	"github.com/davecgh/go-spew/spew"
+	"github.com/rmrobinson/house/api/command"
+	"github.com/spf13/cobra"
+)
+
+var (
+	detectionOn bool
+	recordingOn bool
+)
+
+func init() {
+	detectionCmd.Flags().BoolVar(&detectionOn, "on", false, "whether the camera should detect objects")
+	detectionCmd.MarkFlagRequired("on")
+	deviceCmd.AddCommand(detectionCmd)
+
+	recordingCmd.Flags().BoolVar(&recordingOn, "on", false, "whether the camera should record")
+	recordingCmd.MarkFlagRequired("on")
+	deviceCmd.AddCommand(recordingCmd)
+}
+
+var detectionCmd = &cobra.Command{
+	Use:   "detection",
+	Short: "Turn object detection by a camera on or off",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req := &command.Command{
+			DeviceId: id,
+			Details:  &command.Command_CameraDetection{CameraDetection: &command.CameraDetection{On: detectionOn}},
+		}
+
+		resp, err := client.ExecuteCommand(cmd.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		spew.Dump(resp)
+
+		return nil
+	},
+}
+
+var recordingCmd = &cobra.Command{
+	Use:   "recording",
+	Short: "Turn recording by a camera on or off",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req := &command.Command{
+			DeviceId: id,
+			Details:  &command.Command_CameraRecording{CameraRecording: &command.CameraRecording{On: recordingOn}},
+		}
+
+		resp, err := client.ExecuteCommand(cmd.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		spew.Dump(resp)
+
+		return nil
+	},
+}
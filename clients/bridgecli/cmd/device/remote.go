@@ -0,0 +1,67 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/rmrobinson/house/api/command"
+	"github.com/spf13/cobra"
+)
+
+var (
+	keyAction string
+	keyRepeat int32
+	text      string
+)
+
+func init() {
+	remoteCmd.Flags().StringVar(&keyAction, "action", "press", "whether to press the key, or hold it down or release it; one of press, down or up")
+	remoteCmd.Flags().Int32Var(&keyRepeat, "repeat", 1, "how many times to press the key")
+	remoteCmd.Flags().StringVar(&text, "text", "", "text to enter into the field with focus instead of pressing a key")
+	deviceCmd.AddCommand(remoteCmd)
+}
+
+var remoteCmd = &cobra.Command{
+	Use:   "remote [key]",
+	Short: "Press a key of the remote of a device, i.e. home, back, up, select or instant-replay, or enter text",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req := &command.Command{
+			DeviceId: id,
+		}
+
+		if len(text) > 0 {
+			if len(args) > 0 {
+				return errors.New("either a key or text may be supplied, not both")
+			}
+			req.Details = &command.Command_TextInput{TextInput: &command.TextInput{Text: text}}
+		} else if len(args) > 0 {
+			key, found := command.RemoteKey_Key_value["KEY_"+strings.ToUpper(strings.ReplaceAll(args[0], "-", "_"))]
+			if !found {
+				return fmt.Errorf("unknown key %s", args[0])
+			}
+			action, found := command.RemoteKey_Action_value["ACTION_"+strings.ToUpper(keyAction)]
+			if !found {
+				return fmt.Errorf("unknown action %s; expected press, down or up", keyAction)
+			}
+			req.Details = &command.Command_RemoteKey{RemoteKey: &command.RemoteKey{
+				Key:    command.RemoteKey_Key(key),
+				Action: command.RemoteKey_Action(action),
+				Repeat: keyRepeat,
+			}}
+		} else {
+			return errors.New("a key or text must be supplied")
+		}
+
+		resp, err := client.ExecuteCommand(cmd.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		spew.Dump(resp)
+
+		return nil
+	},
+}
@@ -7,13 +7,16 @@ import (
 	api2 "github.com/rmrobinson/house/api"
 	"github.com/rmrobinson/house/clients/bridgecli/cmd/bridge"
 	"github.com/rmrobinson/house/clients/bridgecli/cmd/device"
+	"github.com/rmrobinson/house/service/auth"
+	"github.com/rmrobinson/house/service/certs"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 var (
 	bridgeAddr   string
+	token        string
+	tlsConfig    certs.Config
 	bridgeConn   *grpc.ClientConn
 	bridgeClient api2.BridgeServiceClient
 
@@ -37,6 +40,11 @@ func init() {
 	cobra.OnFinalize(closeClient)
 
 	rootCmd.PersistentFlags().StringVar(&bridgeAddr, "addr", "", "bridge API address to connect to")
+	rootCmd.PersistentFlags().StringVar(&tlsConfig.CAFile, "ca", "", "CA used to verify the bridge certificate; TLS is used if set")
+	rootCmd.PersistentFlags().StringVar(&tlsConfig.CertFile, "cert", "", "client certificate to present if the bridge requires one")
+	rootCmd.PersistentFlags().StringVar(&tlsConfig.KeyFile, "key", "", "private key of the client certificate")
+	rootCmd.PersistentFlags().StringVar(&tlsConfig.ServerName, "server-name", "", "name to verify the bridge certificate against, if different from the address")
+	rootCmd.PersistentFlags().StringVar(&token, "token", "", "token to identify with instead of a client certificate; requires --ca")
 	rootCmd.MarkPersistentFlagRequired("addr")
 
 	device.Init(rootCmd)
@@ -48,8 +56,17 @@ func initClient() {
 		return
 	}
 
+	creds, err := certs.DialOption(tlsConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	var opts []grpc.DialOption
-	opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	opts = append(opts, creds)
+	if len(token) > 0 {
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.TokenCredentials(token)))
+	}
 	conn, err := grpc.Dial(bridgeAddr, opts...)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
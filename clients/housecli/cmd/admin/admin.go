@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"io"
+	"os"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/clients/housecli/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	client api2.HouseAdminServiceClient
+
+	file string
+)
+
+func Init(cmd *cobra.Command) {
+	backupCmd.Flags().StringVar(&file, "file", "", "file to write the snapshot to")
+	backupCmd.MarkFlagRequired("file")
+
+	cmd.AddCommand(backupCmd)
+}
+
+func Setup(c api2.HouseAdminServiceClient) {
+	client = c
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Save a snapshot of the house database; restore it with 'housed restore'",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stream, err := client.Backup(cmd.Context(), &api2.BackupRequest{})
+		if err != nil {
+			return err
+		}
+
+		// Write to a temporary file first so a failed backup doesn't leave a partial snapshot behind.
+		tmpPath := file + ".partial"
+		f, err := os.Create(tmpPath)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmpPath)
+
+		for {
+			chunk, err := stream.Recv()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				f.Close()
+				return err
+			}
+
+			if _, err := f.Write(chunk.Data); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(tmpPath, file); err != nil {
+			return err
+		}
+
+		output.Done("snapshot written to " + file)
+		return nil
+	},
+}
@@ -0,0 +1,117 @@
+package bridge
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/clients/housecli/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	client api2.HouseBridgeServiceClient
+
+	code  string
+	force bool
+
+	bridgeCmd = &cobra.Command{
+		Use:   "bridge",
+		Short: "Pair bridges with the house, and link the devices they report",
+		Long:  ``,
+	}
+)
+
+func Init(cmd *cobra.Command) {
+	pairCmd.Flags().StringVar(&code, "code", "", "code shown by the bridge; pairing is started and the code prompted for if unset")
+	unpairCmd.Flags().BoolVar(&force, "force", false, "forget the bridge even if it can't be reached to unpair it")
+
+	linksCmd.Flags().StringVar(&linkStatus, "status", "", "only list links with this status; one of suggested, confirmed or rejected")
+
+	bridgeCmd.AddCommand(listCmd, pairCmd, unpairCmd, devicesCmd, linksCmd, linkCmd, rejectCmd)
+	cmd.AddCommand(bridgeCmd)
+}
+
+func Setup(c api2.HouseBridgeServiceClient) {
+	client = c
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the paired bridges",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.ListBridges(cmd.Context(), &api2.ListBridgesRequest{})
+		if err != nil {
+			return err
+		}
+
+		table := output.Table{Header: bridgeHeader}
+		for _, bridge := range resp.Bridges {
+			table.Rows = append(table.Rows, bridgeRow(bridge))
+		}
+		return output.Print(resp, table)
+	},
+}
+
+var pairCmd = &cobra.Command{
+	Use:   "pair <address>",
+	Short: "Pair the bridge at the supplied address, or pair it again to replace its credentials",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pairingCode := code
+		if len(pairingCode) < 1 {
+			resp, err := client.StartBridgePairing(cmd.Context(), &api2.StartBridgePairingRequest{Address: args[0]})
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "Enter the code shown by %s (%s) within %s: ", resp.Bridge.GetConfig().GetName(), resp.Bridge.GetId(), resp.ExpiresIn.AsDuration())
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil {
+				return fmt.Errorf("unable to read code: %w", err)
+			}
+			pairingCode = strings.TrimSpace(line)
+		}
+
+		resp, err := client.PairBridge(cmd.Context(), &api2.PairBridgeRequest{
+			Address: args[0],
+			Code:    pairingCode,
+		})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{Header: bridgeHeader, Rows: [][]string{bridgeRow(resp)}})
+	},
+}
+
+var unpairCmd = &cobra.Command{
+	Use:   "unpair <id>",
+	Short: "Unpair a bridge, revoking the credentials exchanged with it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := client.UnpairBridge(cmd.Context(), &api2.UnpairBridgeRequest{Id: args[0], Force: force}); err != nil {
+			return err
+		}
+		output.Done("bridge unpaired")
+		return nil
+	},
+}
+
+var bridgeHeader = []string{"ID", "NAME", "ADDRESS", "PAIRED"}
+
+func bridgeRow(bridge *api2.PairedBridge) []string {
+	pairedAt := ""
+	if bridge.PairedAt != nil {
+		pairedAt = bridge.PairedAt.AsTime().Local().Format(time.RFC3339)
+	}
+	return []string{
+		bridge.Id,
+		bridge.Name,
+		bridge.Address,
+		pairedAt,
+	}
+}
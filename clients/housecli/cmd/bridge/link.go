@@ -0,0 +1,102 @@
+package bridge
+
+import (
+	"fmt"
+	"strings"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/clients/housecli/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var linkStatus string
+
+var devicesCmd = &cobra.Command{
+	Use:   "devices",
+	Short: "List the devices of the paired bridges, combining the devices which have been linked",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.ListLogicalDevices(cmd.Context(), &api2.ListLogicalDevicesRequest{})
+		if err != nil {
+			return err
+		}
+
+		table := output.Table{Header: []string{"ID", "NAME", "BRIDGE", "HARDWARE ADDRESS", "IP ADDRESSES", "LINKED"}}
+		for _, d := range resp.Devices {
+			presence := d.NetworkPresence.GetState()
+			table.Rows = append(table.Rows, []string{
+				d.Device.GetId(),
+				d.Device.GetConfig().GetName(),
+				d.BridgeId,
+				presence.GetHardwareAddress(),
+				strings.Join(presence.GetIpAddresses(), ","),
+				strings.Join(d.LinkedDeviceIds, ","),
+			})
+		}
+		return output.Print(resp, table)
+	},
+}
+
+var linksCmd = &cobra.Command{
+	Use:   "links",
+	Short: "List the suggested, confirmed and rejected links between devices of different bridges",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req := &api2.ListDeviceLinksRequest{}
+		if len(linkStatus) > 0 {
+			value, found := api2.DeviceLink_Status_value["STATUS_"+strings.ToUpper(linkStatus)]
+			if !found {
+				return fmt.Errorf("unknown status %s; expected suggested, confirmed or rejected", linkStatus)
+			}
+			req.Status = api2.DeviceLink_Status(value)
+		}
+
+		resp, err := client.ListDeviceLinks(cmd.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		table := output.Table{Header: linkHeader}
+		for _, link := range resp.Links {
+			table.Rows = append(table.Rows, linkRow(link))
+		}
+		return output.Print(resp, table)
+	},
+}
+
+var linkCmd = &cobra.Command{
+	Use:   "link <device-id> <network-device-id>",
+	Short: "Confirm that a device and a connected device are the same device",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.ConfirmDeviceLink(cmd.Context(), &api2.ConfirmDeviceLinkRequest{DeviceId: args[0], NetworkDeviceId: args[1]})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{Header: linkHeader, Rows: [][]string{linkRow(resp)}})
+	},
+}
+
+var rejectCmd = &cobra.Command{
+	Use:   "reject <device-id> <network-device-id>",
+	Short: "Stop suggesting a link between the devices, undoing it if it was confirmed",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.RejectDeviceLink(cmd.Context(), &api2.RejectDeviceLinkRequest{DeviceId: args[0], NetworkDeviceId: args[1]})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{Header: linkHeader, Rows: [][]string{linkRow(resp)}})
+	},
+}
+
+var linkHeader = []string{"DEVICE", "NETWORK DEVICE", "STATUS", "MATCHED ON"}
+
+func linkRow(link *api2.DeviceLink) []string {
+	return []string{
+		link.DeviceId,
+		link.NetworkDeviceId,
+		strings.ToLower(strings.TrimPrefix(link.Status.String(), "STATUS_")),
+		strings.Join(link.MatchedOn, ","),
+	}
+}
@@ -0,0 +1,167 @@
+package building
+
+import (
+	"fmt"
+	"strconv"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/clients/housecli/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	client api2.HouseServiceClient
+
+	name      string
+	timezone  string
+	latitude  float64
+	longitude float64
+
+	buildingCmd = &cobra.Command{
+		Use:   "building",
+		Short: "Manage the buildings of the house",
+		Long:  ``,
+	}
+)
+
+func Init(cmd *cobra.Command) {
+	for _, c := range []*cobra.Command{createCmd, updateCmd} {
+		c.Flags().StringVar(&name, "name", "", "name of the building")
+		c.Flags().StringVar(&timezone, "tz", "", "IANA timezone of the building, i.e. America/Toronto")
+		c.Flags().Float64Var(&latitude, "lat", 0, "latitude of the building")
+		c.Flags().Float64Var(&longitude, "lon", 0, "longitude of the building")
+	}
+	createCmd.MarkFlagRequired("name")
+
+	buildingCmd.AddCommand(listCmd, getCmd, createCmd, updateCmd, deleteCmd)
+	cmd.AddCommand(buildingCmd)
+}
+
+func Setup(c api2.HouseServiceClient) {
+	client = c
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the buildings",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.ListBuildings(cmd.Context(), &api2.ListBuildingsRequest{})
+		if err != nil {
+			return err
+		}
+
+		table := output.Table{Header: buildingHeader}
+		for _, building := range resp.Buildings {
+			table.Rows = append(table.Rows, buildingRow(building))
+		}
+		return output.Print(resp, table)
+	},
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get <id>",
+	Short: "Get a building along with its floors and rooms",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.GetBuilding(cmd.Context(), &api2.GetBuildingRequest{Id: args[0]})
+		if err != nil {
+			return err
+		}
+
+		table := output.Table{Header: []string{"FLOOR", "LEVEL", "ROOM ID", "ROOM", "TYPE", "DEVICES"}}
+		for _, floor := range resp.Floors {
+			if len(floor.Rooms) < 1 {
+				table.Rows = append(table.Rows, []string{floor.Config.Name, strconv.Itoa(int(floor.Config.Level)), "", "", "", ""})
+			}
+			for _, room := range floor.Rooms {
+				table.Rows = append(table.Rows, append([]string{floor.Config.Name, strconv.Itoa(int(floor.Config.Level))}, roomColumns(room)...))
+			}
+		}
+		for _, room := range resp.Rooms {
+			table.Rows = append(table.Rows, append([]string{"", ""}, roomColumns(room)...))
+		}
+		return output.Print(resp, table)
+	},
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a building",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.CreateBuilding(cmd.Context(), &api2.CreateBuildingRequest{
+			Config: &api2.Building_Config{
+				Name:      name,
+				Timezone:  timezone,
+				Latitude:  latitude,
+				Longitude: longitude,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{Header: buildingHeader, Rows: [][]string{buildingRow(resp)}})
+	},
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update <id>",
+	Short: "Update a building; only the supplied flags are changed",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		current, err := client.GetBuilding(cmd.Context(), &api2.GetBuildingRequest{Id: args[0]})
+		if err != nil {
+			return err
+		}
+
+		config := current.Config
+		if cmd.Flags().Changed("name") {
+			config.Name = name
+		}
+		if cmd.Flags().Changed("tz") {
+			config.Timezone = timezone
+		}
+		if cmd.Flags().Changed("lat") {
+			config.Latitude = latitude
+		}
+		if cmd.Flags().Changed("lon") {
+			config.Longitude = longitude
+		}
+
+		resp, err := client.UpdateBuilding(cmd.Context(), &api2.UpdateBuildingRequest{Id: args[0], Config: config})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{Header: buildingHeader, Rows: [][]string{buildingRow(resp)}})
+	},
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete <id>",
+	Short: "Delete a building along with its floors and rooms",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := client.DeleteBuilding(cmd.Context(), &api2.DeleteBuildingRequest{Id: args[0]}); err != nil {
+			return err
+		}
+		output.Done("building deleted")
+		return nil
+	},
+}
+
+var buildingHeader = []string{"ID", "NAME", "TIMEZONE", "LATITUDE", "LONGITUDE"}
+
+func buildingRow(building *api2.Building) []string {
+	return []string{
+		building.Id,
+		building.Config.Name,
+		building.Config.Timezone,
+		fmt.Sprint(building.Config.Latitude),
+		fmt.Sprint(building.Config.Longitude),
+	}
+}
+
+func roomColumns(room *api2.Room) []string {
+	return []string{room.Id, room.Config.Name, output.RoomType(room.Config.Type), strconv.Itoa(len(room.Devices))}
+}
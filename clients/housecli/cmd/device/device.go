@@ -0,0 +1,130 @@
+package device
+
+import (
+	"strings"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/clients/housecli/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	client api2.HouseServiceClient
+
+	roomID  string
+	zoneID  string
+	tag     string
+	alias   string
+	tags    []string
+	aliases []string
+
+	deviceCmd = &cobra.Command{
+		Use:   "device",
+		Short: "Manage where devices are located and how they are tagged",
+		Long:  ``,
+	}
+)
+
+func Init(cmd *cobra.Command) {
+	linkCmd.Flags().StringVar(&roomID, "room", "", "room to link the device to")
+	linkCmd.MarkFlagRequired("room")
+
+	searchCmd.Flags().StringVar(&tag, "tag", "", "only show devices with this tag")
+	searchCmd.Flags().StringVar(&alias, "alias", "", "only show the device with this alias")
+	searchCmd.Flags().StringVar(&roomID, "room", "", "only show devices linked to this room")
+	searchCmd.Flags().StringVar(&zoneID, "zone", "", "only show devices linked to a room in this zone")
+
+	metadataCmd.Flags().StringSliceVar(&tags, "tag", nil, "tag of the device; may be repeated")
+	metadataCmd.Flags().StringSliceVar(&aliases, "alias", nil, "alias of the device; may be repeated")
+
+	deviceCmd.AddCommand(linkCmd, unlinkCmd, searchCmd, metadataCmd)
+	cmd.AddCommand(deviceCmd)
+}
+
+func Setup(c api2.HouseServiceClient) {
+	client = c
+}
+
+var linkCmd = &cobra.Command{
+	Use:   "link <device id>",
+	Short: "Link a device to a room",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.LinkDevice(cmd.Context(), &api2.LinkDeviceRequest{
+			DeviceId: args[0],
+			RoomId:   roomID,
+		})
+		if err != nil {
+			return err
+		}
+
+		table := output.Table{Header: []string{"ROOM ID", "ROOM", "DEVICE ID"}}
+		for _, device := range resp.Devices {
+			table.Rows = append(table.Rows, []string{resp.Id, resp.Config.Name, device.Id})
+		}
+		return output.Print(resp, table)
+	},
+}
+
+var unlinkCmd = &cobra.Command{
+	Use:   "unlink <device id>",
+	Short: "Unlink a device from its room",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := client.UnlinkDevice(cmd.Context(), &api2.UnlinkDeviceRequest{Id: args[0]}); err != nil {
+			return err
+		}
+		output.Done("device unlinked")
+		return nil
+	},
+}
+
+var searchCmd = &cobra.Command{
+	Use:   "search",
+	Short: "Find devices by tag, alias, room or zone",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.SearchDevices(cmd.Context(), &api2.SearchDevicesRequest{
+			Tag:    tag,
+			Alias:  alias,
+			RoomId: roomID,
+			ZoneId: zoneID,
+		})
+		if err != nil {
+			return err
+		}
+
+		table := output.Table{Header: []string{"DEVICE ID", "ROOM ID", "TAGS", "ALIASES"}}
+		for _, result := range resp.Results {
+			table.Rows = append(table.Rows, []string{
+				result.Device.Id,
+				result.RoomId,
+				strings.Join(result.Metadata.Tags, ","),
+				strings.Join(result.Metadata.Aliases, ","),
+			})
+		}
+		return output.Print(resp, table)
+	},
+}
+
+var metadataCmd = &cobra.Command{
+	Use:   "metadata <device id>",
+	Short: "Set the tags and aliases of a device, replacing the existing ones",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.UpdateDeviceMetadata(cmd.Context(), &api2.UpdateDeviceMetadataRequest{
+			DeviceId: args[0],
+			Metadata: &api2.DeviceMetadata{
+				Tags:    tags,
+				Aliases: aliases,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{
+			Header: []string{"DEVICE ID", "TAGS", "ALIASES"},
+			Rows:   [][]string{{args[0], strings.Join(resp.Tags, ","), strings.Join(resp.Aliases, ",")}},
+		})
+	},
+}
@@ -0,0 +1,98 @@
+package floor
+
+import (
+	"strconv"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/clients/housecli/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	client api2.HouseServiceClient
+
+	buildingID string
+	name       string
+	level      int32
+
+	floorCmd = &cobra.Command{
+		Use:   "floor",
+		Short: "Manage the floors of a building",
+		Long:  ``,
+	}
+)
+
+func Init(cmd *cobra.Command) {
+	createCmd.Flags().StringVar(&buildingID, "building", "", "building the floor is in")
+	createCmd.MarkFlagRequired("building")
+	for _, c := range []*cobra.Command{createCmd, updateCmd} {
+		c.Flags().StringVar(&name, "name", "", "name of the floor")
+		c.Flags().Int32Var(&level, "level", 0, "position of the floor in the building; 0 is the ground floor")
+		c.MarkFlagRequired("name")
+	}
+
+	floorCmd.AddCommand(createCmd, updateCmd, deleteCmd)
+	cmd.AddCommand(floorCmd)
+}
+
+func Setup(c api2.HouseServiceClient) {
+	client = c
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a floor",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.CreateFloor(cmd.Context(), &api2.CreateFloorRequest{
+			BuildingId: buildingID,
+			Config: &api2.Floor_Config{
+				Name:  name,
+				Level: level,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return printFloor(resp)
+	},
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update <id>",
+	Short: "Update a floor",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.UpdateFloor(cmd.Context(), &api2.UpdateFloorRequest{
+			Id: args[0],
+			Config: &api2.Floor_Config{
+				Name:  name,
+				Level: level,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return printFloor(resp)
+	},
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete <id>",
+	Short: "Delete a floor; its rooms are kept but no longer assigned to a floor",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := client.DeleteFloor(cmd.Context(), &api2.DeleteFloorRequest{Id: args[0]}); err != nil {
+			return err
+		}
+		output.Done("floor deleted")
+		return nil
+	},
+}
+
+func printFloor(floor *api2.Floor) error {
+	return output.Print(floor, output.Table{
+		Header: []string{"ID", "NAME", "LEVEL"},
+		Rows:   [][]string{{floor.Id, floor.Config.Name, strconv.Itoa(int(floor.Config.Level))}},
+	})
+}
@@ -0,0 +1,107 @@
+package layout
+
+import (
+	"fmt"
+	"os"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/clients/housecli/cmd/output"
+	houseLayout "github.com/rmrobinson/house/service/house/layout"
+	"github.com/spf13/cobra"
+)
+
+var (
+	client api2.HouseServiceClient
+
+	file   string
+	dryRun bool
+
+	layoutCmd = &cobra.Command{
+		Use:   "layout",
+		Short: "Export or apply the layout of the house as a YAML or JSON file",
+		Long:  ``,
+	}
+)
+
+func Init(cmd *cobra.Command) {
+	exportCmd.Flags().StringVar(&file, "file", "", "file to write the layout to; written to stdout if empty")
+	applyCmd.Flags().StringVar(&file, "file", "", "layout file to apply")
+	applyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show the changes without saving them")
+	applyCmd.MarkFlagRequired("file")
+
+	layoutCmd.AddCommand(exportCmd, applyCmd)
+	cmd.AddCommand(layoutCmd)
+}
+
+func Setup(c api2.HouseServiceClient) {
+	client = c
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the layout; YAML unless the file ends in .json",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.ExportLayout(cmd.Context(), &api2.ExportLayoutRequest{})
+		if err != nil {
+			return err
+		}
+
+		data, err := houseLayout.Marshal(resp, houseLayout.FormatFromPath(file))
+		if err != nil {
+			return err
+		}
+		if len(file) < 1 {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		return os.WriteFile(file, data, 0644)
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Change the house to match the layout file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		layout, err := houseLayout.Unmarshal(data)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", file, err)
+		}
+
+		resp, err := client.ApplyLayout(cmd.Context(), &api2.ApplyLayoutRequest{
+			Layout: layout,
+			DryRun: dryRun,
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(resp.Changes) < 1 && output.Format == output.FormatTable {
+			output.Done("no changes")
+			return nil
+		}
+
+		symbols := map[api2.ApplyLayoutResponse_Change_Action]string{
+			api2.ApplyLayoutResponse_Change_ACTION_CREATE: "+",
+			api2.ApplyLayoutResponse_Change_ACTION_UPDATE: "~",
+			api2.ApplyLayoutResponse_Change_ACTION_DELETE: "-",
+		}
+		table := output.Table{Header: []string{"", "KIND", "PATH"}}
+		for _, change := range resp.Changes {
+			table.Rows = append(table.Rows, []string{symbols[change.Action], change.Kind, change.Path})
+		}
+		if err := output.Print(resp, table); err != nil {
+			return err
+		}
+
+		if dryRun {
+			output.Done("dry run; no changes saved")
+		}
+		return nil
+	},
+}
@@ -0,0 +1,82 @@
+// Package output prints the responses of the house API as either a table or JSON.
+package output
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	api2 "github.com/rmrobinson/house/api"
+)
+
+// The supported output formats.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+)
+
+// Format is the output format selected by the user.
+var Format = FormatTable
+
+var jsonOpts = protojson.MarshalOptions{
+	Multiline:     true,
+	UseProtoNames: true,
+}
+
+// Table describes how to show a response as a table.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// Print writes the response to stdout in the selected format.
+// The table is used when printing a table, otherwise the message is written as JSON.
+func Print(msg proto.Message, table Table) error {
+	switch Format {
+	case FormatJSON:
+		data, err := jsonOpts.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	case FormatTable:
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(table.Header, "\t"))
+		for _, row := range table.Rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format %s", Format)
+}
+
+// Done reports that a command without a response succeeded.
+func Done(msg string) {
+	if Format == FormatTable {
+		fmt.Println(msg)
+	}
+}
+
+// RoomType returns the short name of the room type, i.e. living_room.
+func RoomType(roomType api2.RoomType) string {
+	return strings.ToLower(strings.TrimPrefix(roomType.String(), "ROOM_TYPE_"))
+}
+
+// ParseRoomType converts a room type written as either its short name or its full API name.
+func ParseRoomType(value string) (api2.RoomType, error) {
+	value = strings.ToUpper(value)
+	if !strings.HasPrefix(value, "ROOM_TYPE_") {
+		value = "ROOM_TYPE_" + value
+	}
+
+	roomType, ok := api2.RoomType_value[value]
+	if !ok {
+		return api2.RoomType_ROOM_TYPE_UNSPECIFIED, fmt.Errorf("unknown room type %s", value)
+	}
+	return api2.RoomType(roomType), nil
+}
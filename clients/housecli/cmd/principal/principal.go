@@ -0,0 +1,214 @@
+package principal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/clients/housecli/cmd/output"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	client api2.HouseAuthServiceClient
+
+	subject     string
+	role        string
+	description string
+	roomIDs     []string
+	deviceTypes []string
+	expires     string
+	ttl         time.Duration
+
+	principalCmd = &cobra.Command{
+		Use:   "principal",
+		Short: "Manage which clients may use the house and its bridges",
+		Long:  ``,
+	}
+)
+
+func Init(cmd *cobra.Command) {
+	for _, c := range []*cobra.Command{createCmd, updateCmd, tokenCmd} {
+		c.Flags().StringVar(&role, "role", "", "role of the principal; one of read_only, control, admin or bridge")
+		c.Flags().StringVar(&description, "description", "", "description of who the principal is")
+		c.Flags().StringSliceVar(&roomIDs, "room", nil, "ID of a room whose devices may be controlled; may be repeated")
+		c.Flags().StringSliceVar(&deviceTypes, "device-type", nil, "type of device which may be controlled, i.e. light; may be repeated")
+		c.MarkFlagRequired("role")
+	}
+	for _, c := range []*cobra.Command{createCmd, updateCmd} {
+		c.Flags().StringVar(&expires, "expires", "", "time the principal expires at, in RFC 3339 format")
+	}
+	createCmd.Flags().StringVar(&subject, "subject", "", "subject of the client certificate identifying the principal")
+	createCmd.MarkFlagRequired("subject")
+	tokenCmd.Flags().DurationVar(&ttl, "ttl", 0, "how long the token is valid for; never expires if unset")
+
+	principalCmd.AddCommand(listCmd, getCmd, createCmd, updateCmd, deleteCmd, tokenCmd)
+	cmd.AddCommand(principalCmd)
+}
+
+func Setup(c api2.HouseAuthServiceClient) {
+	client = c
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the principals",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.ListPrincipals(cmd.Context(), &api2.ListPrincipalsRequest{})
+		if err != nil {
+			return err
+		}
+
+		table := output.Table{Header: principalHeader}
+		for _, principal := range resp.Principals {
+			table.Rows = append(table.Rows, principalRow(principal))
+		}
+		return output.Print(resp, table)
+	},
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get <id>",
+	Short: "Get a principal",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.GetPrincipal(cmd.Context(), &api2.GetPrincipalRequest{Id: args[0]})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{Header: principalHeader, Rows: [][]string{principalRow(resp)}})
+	},
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Grant access to the client certificate with the supplied subject",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := principalConfig()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.CreatePrincipal(cmd.Context(), &api2.CreatePrincipalRequest{
+			Subject: subject,
+			Config:  config,
+		})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{Header: principalHeader, Rows: [][]string{principalRow(resp)}})
+	},
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update <id>",
+	Short: "Update a principal; the supplied settings replace the existing ones",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := principalConfig()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.UpdatePrincipal(cmd.Context(), &api2.UpdatePrincipalRequest{
+			Id:     args[0],
+			Config: config,
+		})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{Header: principalHeader, Rows: [][]string{principalRow(resp)}})
+	},
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete <id>",
+	Short: "Delete a principal, revoking its access",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := client.DeletePrincipal(cmd.Context(), &api2.DeletePrincipalRequest{Id: args[0]}); err != nil {
+			return err
+		}
+		output.Done("principal deleted")
+		return nil
+	},
+}
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Create a token, such as a time-limited token for a guest; the token is only shown once",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := principalConfig()
+		if err != nil {
+			return err
+		}
+
+		req := &api2.CreateTokenRequest{
+			Config: config,
+		}
+		if ttl > 0 {
+			req.Ttl = durationpb.New(ttl)
+		}
+
+		resp, err := client.CreateToken(cmd.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		table := output.Table{
+			Header: append([]string{"TOKEN"}, principalHeader...),
+			Rows:   [][]string{append([]string{resp.Token}, principalRow(resp.Principal)...)},
+		}
+		return output.Print(resp, table)
+	},
+}
+
+func principalConfig() (*api2.Principal_Config, error) {
+	value := strings.ToUpper(role)
+	if !strings.HasPrefix(value, "ROLE_") {
+		value = "ROLE_" + value
+	}
+	apiRole, ok := api2.Principal_Role_value[value]
+	if !ok {
+		return nil, fmt.Errorf("unknown role %s", role)
+	}
+
+	config := &api2.Principal_Config{
+		Description: description,
+		Role:        api2.Principal_Role(apiRole),
+		RoomIds:     roomIDs,
+		DeviceTypes: deviceTypes,
+	}
+	if len(expires) > 0 {
+		expiresAt, err := time.Parse(time.RFC3339, expires)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiry time: %w", err)
+		}
+		config.ExpiresAt = timestamppb.New(expiresAt)
+	}
+	return config, nil
+}
+
+var principalHeader = []string{"ID", "SUBJECT", "ROLE", "ROOMS", "DEVICE TYPES", "EXPIRES", "DESCRIPTION"}
+
+func principalRow(principal *api2.Principal) []string {
+	expiresAt := ""
+	if principal.Config.ExpiresAt != nil {
+		expiresAt = principal.Config.ExpiresAt.AsTime().Local().Format(time.RFC3339)
+	}
+	return []string{
+		principal.Id,
+		principal.Subject,
+		strings.ToLower(strings.TrimPrefix(principal.Config.Role.String(), "ROLE_")),
+		strings.Join(principal.Config.RoomIds, ","),
+		strings.Join(principal.Config.DeviceTypes, ","),
+		expiresAt,
+		principal.Config.Description,
+	}
+}
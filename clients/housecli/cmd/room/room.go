@@ -0,0 +1,157 @@
+package room
+
+import (
+	"strconv"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/clients/housecli/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	client api2.HouseServiceClient
+
+	buildingID string
+	floorID    string
+	zoneID     string
+	name       string
+	roomType   string
+
+	roomCmd = &cobra.Command{
+		Use:   "room",
+		Short: "Manage the rooms of a building",
+		Long:  ``,
+	}
+)
+
+func Init(cmd *cobra.Command) {
+	listCmd.Flags().StringVar(&buildingID, "building", "", "only list the rooms in this building")
+	listCmd.Flags().StringVar(&floorID, "floor", "", "only list the rooms on this floor")
+	listCmd.Flags().StringVar(&zoneID, "zone", "", "only list the rooms in this zone")
+
+	createCmd.Flags().StringVar(&buildingID, "building", "", "building the room is in")
+	createCmd.MarkFlagRequired("building")
+	for _, c := range []*cobra.Command{createCmd, updateCmd} {
+		c.Flags().StringVar(&name, "name", "", "name of the room")
+		c.Flags().StringVar(&roomType, "type", "unspecified", "type of the room, i.e. kitchen or living_room")
+		c.Flags().StringVar(&floorID, "floor", "", "floor the room is on")
+	}
+	createCmd.MarkFlagRequired("name")
+
+	roomCmd.AddCommand(listCmd, createCmd, updateCmd, deleteCmd)
+	cmd.AddCommand(roomCmd)
+}
+
+func Setup(c api2.HouseServiceClient) {
+	client = c
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List rooms by building, floor or zone",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.ListRooms(cmd.Context(), &api2.ListRoomsRequest{
+			BuildingId: buildingID,
+			FloorId:    floorID,
+			ZoneId:     zoneID,
+		})
+		if err != nil {
+			return err
+		}
+
+		table := output.Table{Header: roomHeader}
+		for _, room := range resp.Rooms {
+			table.Rows = append(table.Rows, roomRow(room))
+		}
+		return output.Print(resp, table)
+	},
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a room",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := roomConfig()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.CreateRoom(cmd.Context(), &api2.CreateRoomRequest{
+			BuildingId: buildingID,
+			Config:     config,
+		})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{Header: roomHeader, Rows: [][]string{roomRow(resp)}})
+	},
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update <id>",
+	Short: "Update a room; only the supplied flags are changed",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		current, err := client.GetRoom(cmd.Context(), &api2.GetRoomRequest{Id: args[0]})
+		if err != nil {
+			return err
+		}
+
+		config := current.Config
+		if cmd.Flags().Changed("name") {
+			config.Name = name
+		}
+		if cmd.Flags().Changed("type") {
+			t, err := output.ParseRoomType(roomType)
+			if err != nil {
+				return err
+			}
+			config.Type = t
+		}
+		if cmd.Flags().Changed("floor") {
+			config.FloorId = floorID
+		}
+
+		resp, err := client.UpdateRoom(cmd.Context(), &api2.UpdateRoomRequest{
+			Id:     args[0],
+			Config: config,
+		})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{Header: roomHeader, Rows: [][]string{roomRow(resp)}})
+	},
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete <id>",
+	Short: "Delete a room and unlink its devices",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := client.DeleteRoom(cmd.Context(), &api2.DeleteRoomRequest{Id: args[0]}); err != nil {
+			return err
+		}
+		output.Done("room deleted")
+		return nil
+	},
+}
+
+func roomConfig() (*api2.Room_Config, error) {
+	t, err := output.ParseRoomType(roomType)
+	if err != nil {
+		return nil, err
+	}
+	return &api2.Room_Config{
+		Name:    name,
+		Type:    t,
+		FloorId: floorID,
+	}, nil
+}
+
+var roomHeader = []string{"ID", "NAME", "TYPE", "FLOOR", "DEVICES"}
+
+func roomRow(room *api2.Room) []string {
+	return []string{room.Id, room.Config.Name, output.RoomType(room.Config.Type), room.Config.FloorId, strconv.Itoa(len(room.Devices))}
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/clients/housecli/cmd/admin"
+	"github.com/rmrobinson/house/clients/housecli/cmd/bridge"
+	"github.com/rmrobinson/house/clients/housecli/cmd/building"
+	"github.com/rmrobinson/house/clients/housecli/cmd/device"
+	"github.com/rmrobinson/house/clients/housecli/cmd/floor"
+	"github.com/rmrobinson/house/clients/housecli/cmd/layout"
+	"github.com/rmrobinson/house/clients/housecli/cmd/output"
+	"github.com/rmrobinson/house/clients/housecli/cmd/principal"
+	"github.com/rmrobinson/house/clients/housecli/cmd/room"
+	"github.com/rmrobinson/house/clients/housecli/cmd/zone"
+	"github.com/rmrobinson/house/service/auth"
+	"github.com/rmrobinson/house/service/certs"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+var (
+	houseAddr    string
+	token        string
+	tlsConfig    certs.Config
+	houseConn    *grpc.ClientConn
+	houseClient  api2.HouseServiceClient
+	adminClient  api2.HouseAdminServiceClient
+	authClient   api2.HouseAuthServiceClient
+	bridgeClient api2.HouseBridgeServiceClient
+
+	rootCmd = &cobra.Command{
+		Use:   "housecli",
+		Short: "Allows for management of the house layout",
+		Long:  ``,
+		// Errors are almost always returned by the house, so the usage doesn't help explain them.
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+)
+
+// Execute is the entry point into the command hierarchy
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	cobra.OnInitialize(initClient)
+	cobra.OnFinalize(closeClient)
+
+	rootCmd.PersistentFlags().StringVar(&houseAddr, "addr", "localhost:1337", "house API address to connect to")
+	rootCmd.PersistentFlags().StringVar(&tlsConfig.CAFile, "ca", "", "CA used to verify the house certificate; TLS is used if set")
+	rootCmd.PersistentFlags().StringVar(&tlsConfig.CertFile, "cert", "", "client certificate to present if the house requires one")
+	rootCmd.PersistentFlags().StringVar(&tlsConfig.KeyFile, "key", "", "private key of the client certificate")
+	rootCmd.PersistentFlags().StringVar(&tlsConfig.ServerName, "server-name", "", "name to verify the house certificate against, if different from the address")
+	rootCmd.PersistentFlags().StringVar(&token, "token", "", "token to identify with instead of a client certificate; requires --ca")
+	rootCmd.PersistentFlags().StringVarP(&output.Format, "output", "o", output.FormatTable, "output format; either table or json")
+
+	building.Init(rootCmd)
+	floor.Init(rootCmd)
+	room.Init(rootCmd)
+	zone.Init(rootCmd)
+	device.Init(rootCmd)
+	layout.Init(rootCmd)
+	admin.Init(rootCmd)
+	principal.Init(rootCmd)
+	bridge.Init(rootCmd)
+}
+
+func initClient() {
+	if len(houseAddr) < 1 {
+		return
+	}
+
+	creds, err := certs.DialOption(tlsConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var opts []grpc.DialOption
+	opts = append(opts, creds)
+	if len(token) > 0 {
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.TokenCredentials(token)))
+	}
+	conn, err := grpc.Dial(houseAddr, opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	houseConn = conn
+	houseClient = api2.NewHouseServiceClient(houseConn)
+	adminClient = api2.NewHouseAdminServiceClient(houseConn)
+	authClient = api2.NewHouseAuthServiceClient(houseConn)
+	bridgeClient = api2.NewHouseBridgeServiceClient(houseConn)
+
+	building.Setup(houseClient)
+	floor.Setup(houseClient)
+	room.Setup(houseClient)
+	zone.Setup(houseClient)
+	device.Setup(houseClient)
+	layout.Setup(houseClient)
+	admin.Setup(adminClient)
+	principal.Setup(authClient)
+	bridge.Setup(bridgeClient)
+}
+
+func closeClient() {
+	if houseConn != nil {
+		houseConn.Close()
+	}
+}
@@ -0,0 +1,129 @@
+package zone
+
+import (
+	"strings"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/clients/housecli/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	client api2.HouseServiceClient
+
+	name    string
+	roomIDs []string
+
+	zoneCmd = &cobra.Command{
+		Use:   "zone",
+		Short: "Manage named groups of rooms",
+		Long:  ``,
+	}
+)
+
+func Init(cmd *cobra.Command) {
+	for _, c := range []*cobra.Command{createCmd, updateCmd} {
+		c.Flags().StringVar(&name, "name", "", "name of the zone")
+		c.Flags().StringSliceVar(&roomIDs, "room", nil, "ID of a room in the zone; may be repeated")
+		c.MarkFlagRequired("name")
+	}
+
+	zoneCmd.AddCommand(listCmd, getCmd, createCmd, updateCmd, deleteCmd)
+	cmd.AddCommand(zoneCmd)
+}
+
+func Setup(c api2.HouseServiceClient) {
+	client = c
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the zones",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.ListZones(cmd.Context(), &api2.ListZonesRequest{})
+		if err != nil {
+			return err
+		}
+
+		table := output.Table{Header: zoneHeader}
+		for _, zone := range resp.Zones {
+			table.Rows = append(table.Rows, zoneRow(zone))
+		}
+		return output.Print(resp, table)
+	},
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get <id>",
+	Short: "Get a zone along with its rooms",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.GetZone(cmd.Context(), &api2.GetZoneRequest{Id: args[0]})
+		if err != nil {
+			return err
+		}
+
+		table := output.Table{Header: []string{"ROOM ID", "ROOM", "TYPE"}}
+		for _, room := range resp.Rooms {
+			table.Rows = append(table.Rows, []string{room.Id, room.Config.Name, output.RoomType(room.Config.Type)})
+		}
+		return output.Print(resp, table)
+	},
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a zone",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.CreateZone(cmd.Context(), &api2.CreateZoneRequest{
+			Config: &api2.Zone_Config{
+				Name:    name,
+				RoomIds: roomIDs,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{Header: zoneHeader, Rows: [][]string{zoneRow(resp)}})
+	},
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update <id>",
+	Short: "Update a zone; the supplied rooms replace the existing ones",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := client.UpdateZone(cmd.Context(), &api2.UpdateZoneRequest{
+			Id: args[0],
+			Config: &api2.Zone_Config{
+				Name:    name,
+				RoomIds: roomIDs,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return output.Print(resp, output.Table{Header: zoneHeader, Rows: [][]string{zoneRow(resp)}})
+	},
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete <id>",
+	Short: "Delete a zone; its rooms are kept",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := client.DeleteZone(cmd.Context(), &api2.DeleteZoneRequest{Id: args[0]}); err != nil {
+			return err
+		}
+		output.Done("zone deleted")
+		return nil
+	},
+}
+
+var zoneHeader = []string{"ID", "NAME", "ROOMS"}
+
+func zoneRow(zone *api2.Zone) []string {
+	return []string{zone.Id, zone.Config.Name, strings.Join(zone.Config.RoomIds, ",")}
+}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"github.com/rmrobinson/house/clients/housecli/cmd"
+)
+
+func main() {
+	cmd.Execute()
+}
@@ -0,0 +1,149 @@
+// Package auth authorizes the callers of the house and bridge gRPC servers.
+// Callers are identified by the subject of their verified client certificate or by a bearer token;
+// the Authorizer supplied by each server decides what an identity may do.
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authorizationKey = "authorization"
+	bearerPrefix     = "Bearer "
+)
+
+// These errors should be returned by Authorizer implementations.
+var (
+	// ErrUnauthenticated is returned when the caller needs to be identified but isn't.
+	ErrUnauthenticated = status.Error(codes.Unauthenticated, "client certificate or token required")
+	// ErrPermissionDenied is returned when the caller isn't permitted to make the request.
+	ErrPermissionDenied = status.Error(codes.PermissionDenied, "permission denied")
+)
+
+// publicMethods are available to every caller without being authorized, so servers can be monitored without credentials.
+var publicMethods = map[string]bool{
+	healthpb.Health_Check_FullMethodName: true,
+	healthpb.Health_Watch_FullMethodName: true,
+}
+
+// Access describes what a request does, and so what a caller needs to be permitted to make it.
+type Access int
+
+const (
+	AccessUnspecified Access = iota
+	// AccessRead requests only retrieve state.
+	AccessRead
+	// AccessControl requests change the state of a device.
+	AccessControl
+	// AccessAdmin requests change the configuration of the house or a bridge.
+	AccessAdmin
+)
+
+// Identity is presented by a caller. If a token is supplied it is used in preference to the certificate subject.
+type Identity struct {
+	// Subject is the common name of the verified client certificate.
+	Subject string
+	// Token is the bearer token supplied in the request metadata.
+	Token string
+}
+
+// Empty returns true if the caller didn't present any identity.
+func (i Identity) Empty() bool {
+	return len(i.Subject) < 1 && len(i.Token) < 1
+}
+
+// IdentityFromContext retrieves the identity presented by the caller of the request in the supplied context.
+// Client certificates are only used if they were verified during the TLS handshake.
+func IdentityFromContext(ctx context.Context) Identity {
+	var id Identity
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md.Get(authorizationKey) {
+			if strings.HasPrefix(value, bearerPrefix) {
+				id.Token = strings.TrimPrefix(value, bearerPrefix)
+				break
+			}
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if chains := tlsInfo.State.VerifiedChains; len(chains) > 0 && len(chains[0]) > 0 {
+				id.Subject = chains[0][0].Subject.CommonName
+			}
+		}
+	}
+	return id
+}
+
+// Authorizer decides whether a caller may make a request.
+type Authorizer interface {
+	// Authorize returns a gRPC status error if the identity may not call the method with the supplied request.
+	// The identity is empty if the caller didn't present one, and the request is nil for streaming methods.
+	Authorize(ctx context.Context, id Identity, method string, req any) error
+}
+
+// ServerOptions returns the options needed for a gRPC server to check every request with the supplied authorizer.
+func ServerOptions(a Authorizer) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(a)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(a)),
+	}
+}
+
+// UnaryServerInterceptor rejects unary requests which the authorizer doesn't permit.
+// Health checks are always permitted.
+func UnaryServerInterceptor(a Authorizer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if publicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		id := IdentityFromContext(ctx)
+		if err := a.Authorize(ctx, id, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streaming requests which the authorizer doesn't permit.
+// Health checks are always permitted.
+func StreamServerInterceptor(a Authorizer) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if publicMethods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+
+		id := IdentityFromContext(ss.Context())
+		if err := a.Authorize(ss.Context(), id, info.FullMethod, nil); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// TokenCredentials returns credentials which supply the token as a bearer token with every request.
+// Tokens are only sent over connections secured by TLS.
+func TokenCredentials(token string) credentials.PerRPCCredentials {
+	return tokenCredentials(token)
+}
+
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{authorizationKey: bearerPrefix + string(t)}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/rmrobinson/house/api/device"
+)
+
+type fakeAuthorizer struct {
+	id     Identity
+	method string
+}
+
+func (f *fakeAuthorizer) Authorize(ctx context.Context, id Identity, method string, req any) error {
+	f.id = id
+	f.method = method
+	if id.Empty() {
+		return ErrUnauthenticated
+	} else if id.Token != "guest" {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	authorizer := &fakeAuthorizer{}
+	interceptor := UnaryServerInterceptor(authorizer)
+	info := &grpc.UnaryServerInfo{FullMethod: "/faltung.house.api.BridgeService/ExecuteCommand"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+
+	_, err := interceptor(context.Background(), nil, info, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer other"))
+	_, err = interceptor(ctx, nil, info, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer guest"))
+	resp, err := interceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+	assert.Equal(t, Identity{Token: "guest"}, authorizer.id)
+	assert.Equal(t, info.FullMethod, authorizer.method)
+
+	// Health checks don't need an identity.
+	resp, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+}
+
+func TestDeviceType(t *testing.T) {
+	assert.Equal(t, "light", DeviceType(&device.Device{Details: &device.Device_Light{Light: &device.Light{}}}))
+	assert.Empty(t, DeviceType(&device.Device{}))
+	assert.True(t, IsDeviceType("media_player"))
+	assert.False(t, IsDeviceType("toaster"))
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/rmrobinson/house/api/device"
+)
+
+// deviceDetails is the oneof whose field names are used as the device types in policies.
+var deviceDetails = (&device.Device{}).ProtoReflect().Descriptor().Oneofs().ByName("details")
+
+// DeviceType returns the type of the supplied device, named after the details it contains, i.e. "light".
+// An empty string is returned if the device doesn't have any details set.
+func DeviceType(d *device.Device) string {
+	field := d.ProtoReflect().WhichOneof(deviceDetails)
+	if field == nil {
+		return ""
+	}
+	return string(field.Name())
+}
+
+// IsDeviceType returns true if the supplied name is a known type of device.
+func IsDeviceType(name string) bool {
+	return deviceDetails.Fields().ByName(protoreflect.Name(name)) != nil
+}
@@ -2,11 +2,14 @@ package bridge
 
 import (
 	"context"
+	"net"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
 
 	api2 "github.com/rmrobinson/house/api"
 	"github.com/rmrobinson/house/api/command"
@@ -17,6 +20,8 @@ import (
 var (
 	// ErrBridgeNotReady is returned if the bridge is in the process of initializing and isn't ready to process requests
 	ErrBridgeNotReady = status.Error(codes.Unavailable, "bridge not ready")
+	// ErrBridgeShuttingDown is returned to update streams when the bridge stops, so watchers know to reconnect.
+	ErrBridgeShuttingDown = status.Error(codes.Unavailable, "bridge is shutting down")
 	// ErrDeviceNotFound is returned when a specified device ID is requested but isn't registered.
 	ErrDeviceNotFound = status.Error(codes.NotFound, "device id not found")
 	// ErrCommandNotSupported is returned when a command is targeted to a device which doesn't support the specified command type.
@@ -91,6 +96,14 @@ func (a *API) ExecuteCommand(ctx context.Context, req *command.Command) (*device
 			logger.Debug("processing onoff command")
 			return a.svc.processCommand(ctx, req)
 		}
+	} else if d.GetCamera() != nil {
+		if d.GetCamera().GetDetection().GetAttributes().GetCanControl() && req.GetCameraDetection() != nil {
+			logger.Debug("processing camera detection command")
+			return a.svc.processCommand(ctx, req)
+		} else if d.GetCamera().GetRecording().GetAttributes().GetCanControl() && req.GetCameraRecording() != nil {
+			logger.Debug("processing camera recording command")
+			return a.svc.processCommand(ctx, req)
+		}
 	} else if d.GetClock() != nil {
 		if req.GetOnOff() != nil {
 			logger.Debug("processing onoff command")
@@ -110,7 +123,23 @@ func (a *API) ExecuteCommand(ctx context.Context, req *command.Command) (*device
 			logger.Debug("processing brightness command")
 			return a.svc.processCommand(ctx, req)
 		}
+	} else if d.GetNetworkPort() != nil {
+		if d.GetNetworkPort().GetOnOff().GetAttributes().GetCanControl() && req.GetOnOff() != nil {
+			logger.Debug("processing onoff command")
+			return a.svc.processCommand(ctx, req)
+		}
 	} else if d.GetSensor() != nil {
+	} else if d.GetTelevision() != nil {
+		if d.GetTelevision().GetOnOff().GetAttributes().GetCanControl() && req.GetOnOff() != nil {
+			logger.Debug("processing onoff command")
+			return a.svc.processCommand(ctx, req)
+		} else if d.GetTelevision().GetRemote().GetAttributes().GetCanControl() && req.GetRemoteKey() != nil {
+			logger.Debug("processing remote key command")
+			return a.svc.processCommand(ctx, req)
+		} else if d.GetTelevision().GetRemote().GetAttributes().GetSupportsTextInput() && req.GetTextInput() != nil {
+			logger.Debug("processing text input command")
+			return a.svc.processCommand(ctx, req)
+		}
 	} else if d.GetThermostat() != nil {
 		if req.GetOnOff() != nil {
 			logger.Debug("processing onoff command")
@@ -156,6 +185,9 @@ func (a *API) StreamUpdates(req *api2.StreamUpdatesRequest, stream api2.BridgeSe
 
 	for {
 		select {
+		case <-a.svc.done:
+			logger.Info("closing stream as the bridge is shutting down")
+			return ErrBridgeShuttingDown
 		case <-stream.Context().Done():
 			// TODO: log that the channel is closed
 			err := stream.Context().Err()
@@ -179,3 +211,140 @@ func (a *API) StreamUpdates(req *api2.StreamUpdatesRequest, stream api2.BridgeSe
 		}
 	}
 }
+
+func (a *API) GetCameraSnapshot(ctx context.Context, req *api2.GetCameraSnapshotRequest) (*api2.Image, error) {
+	if a.svc.bridge == nil {
+		return nil, ErrBridgeNotReady
+	} else if req.Height < 0 || req.Quality < 0 || req.Quality > 100 {
+		return nil, status.Error(codes.InvalidArgument, "height must be positive and quality must be between 1 and 100")
+	}
+
+	media, err := a.svc.cameraMedia(req.DeviceId)
+	if err != nil {
+		return nil, err
+	}
+	image, err := media.GetCameraSnapshot(ctx, req)
+	if err != nil {
+		return nil, a.svc.handlerError(err)
+	}
+	return image, nil
+}
+
+func (a *API) ListCameraEvents(ctx context.Context, req *api2.ListCameraEventsRequest) (*api2.ListCameraEventsResponse, error) {
+	if a.svc.bridge == nil {
+		return nil, ErrBridgeNotReady
+	} else if req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must be positive")
+	}
+
+	media, err := a.svc.cameraMedia(req.DeviceId)
+	if err != nil {
+		return nil, err
+	}
+	events, err := media.ListCameraEvents(ctx, req)
+	if err != nil {
+		return nil, a.svc.handlerError(err)
+	}
+	return &api2.ListCameraEventsResponse{
+		Events: events,
+	}, nil
+}
+
+func (a *API) GetEventClip(req *api2.GetEventClipRequest, stream api2.BridgeService_GetEventClipServer) error {
+	if a.svc.bridge == nil {
+		return ErrBridgeNotReady
+	} else if len(req.EventId) < 1 {
+		return status.Error(codes.InvalidArgument, "event id must be supplied")
+	}
+
+	media, err := a.svc.cameraMedia(req.DeviceId)
+	if err != nil {
+		return err
+	}
+	contentType, clip, err := media.GetEventClip(stream.Context(), req)
+	if err != nil {
+		return a.svc.handlerError(err)
+	}
+	defer clip.Close()
+
+	if err := sendClip(contentType, clip, stream); err != nil {
+		a.logger.Info("unable to send clip", zap.String("event_id", req.EventId), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (a *API) StartPairing(ctx context.Context, req *api2.StartPairingRequest) (*api2.StartPairingResponse, error) {
+	if a.svc.bridge == nil {
+		return nil, ErrBridgeNotReady
+	} else if a.svc.pairing == nil {
+		return nil, ErrPairingNotEnabled
+	}
+
+	ttl, err := a.svc.pairing.start(peerHost(ctx))
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		a.logger.Error("unable to generate pairing code", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to generate pairing code")
+	}
+	return &api2.StartPairingResponse{
+		ExpiresIn: durationpb.New(ttl),
+	}, nil
+}
+
+func (a *API) Pair(ctx context.Context, req *api2.PairRequest) (*api2.PairResponse, error) {
+	if a.svc.bridge == nil {
+		return nil, ErrBridgeNotReady
+	} else if a.svc.pairing == nil {
+		return nil, ErrPairingNotEnabled
+	} else if len(req.HouseToken) < 1 {
+		return nil, status.Error(codes.InvalidArgument, "house token must be supplied")
+	}
+
+	token, err := a.svc.pairing.pair(peerHost(ctx), req.Code, req.HouseToken)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		a.logger.Error("unable to save pairing", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to save pairing")
+	}
+
+	a.logger.Info("paired with house")
+	a.svc.publishPairing()
+	return &api2.PairResponse{
+		Token:  token,
+		Bridge: a.svc.getBridge(),
+	}, nil
+}
+
+func (a *API) Unpair(ctx context.Context, req *api2.UnpairRequest) (*emptypb.Empty, error) {
+	if a.svc.bridge == nil {
+		return nil, ErrBridgeNotReady
+	} else if a.svc.pairing == nil {
+		return nil, ErrPairingNotEnabled
+	}
+
+	if err := a.svc.pairing.unpair(); err != nil {
+		a.logger.Error("unable to remove pairing", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to remove pairing")
+	}
+
+	a.logger.Info("unpaired from house")
+	a.svc.publishPairing()
+	return &emptypb.Empty{}, nil
+}
+
+// peerHost returns the host the request was made from, which pairing lockouts are kept for.
+func peerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
@@ -0,0 +1,153 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionalphapb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/service/auth"
+	"github.com/rmrobinson/house/service/certs"
+)
+
+// ErrAuthRequiresTLS is returned when authorization is configured without TLS, as tokens can't be sent securely.
+var ErrAuthRequiresTLS = errors.New("authorization requires tls to be configured")
+
+// bridgeMethodAccess lists the access needed to call each method of the bridge. Methods which aren't listed require
+// admin access.
+var bridgeMethodAccess = map[string]auth.Access{
+	api2.BridgeService_GetBridge_FullMethodName:      auth.AccessRead,
+	api2.BridgeService_ListDevices_FullMethodName:    auth.AccessRead,
+	api2.BridgeService_GetDevice_FullMethodName:      auth.AccessRead,
+	api2.BridgeService_StreamUpdates_FullMethodName:  auth.AccessRead,
+	api2.BridgeService_ExecuteCommand_FullMethodName: auth.AccessControl,
+
+	api2.BridgeService_GetCameraSnapshot_FullMethodName: auth.AccessRead,
+	api2.BridgeService_ListCameraEvents_FullMethodName:  auth.AccessRead,
+	api2.BridgeService_GetEventClip_FullMethodName:      auth.AccessRead,
+
+	reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName:      auth.AccessRead,
+	reflectionalphapb.ServerReflection_ServerReflectionInfo_FullMethodName: auth.AccessRead,
+}
+
+var accessAuthToAPI = map[auth.Access]api2.CheckAccessRequest_Access{
+	auth.AccessRead:    api2.CheckAccessRequest_ACCESS_READ,
+	auth.AccessControl: api2.CheckAccessRequest_ACCESS_CONTROL,
+	auth.AccessAdmin:   api2.CheckAccessRequest_ACCESS_ADMIN,
+}
+
+// AuthConfig contains the settings used to check callers with the house.
+type AuthConfig struct {
+	// House is the address of the house whose principals are used to authorize callers.
+	// Authorization is disabled if it is empty.
+	House string `mapstructure:"house"`
+	// TLS contains the certificates used to connect to the house. Once paired the bridge identifies itself with the
+	// token issued by the house; until then a client certificate granted the bridge role by the house may be used.
+	TLS certs.Config `mapstructure:"tls"`
+}
+
+// errNotHouse is returned when a caller other than the paired house attempts to pair or unpair the bridge.
+var errNotHouse = status.Error(codes.PermissionDenied, "only the paired house may pair or unpair the bridge")
+
+// bridgeAuthorizer only permits an unpaired bridge to be retrieved and paired. Once paired, the house may make any
+// request; other callers are checked with the house if one is configured, otherwise they may only read.
+type bridgeAuthorizer struct {
+	logger *zap.Logger
+
+	svc *Service
+	// house is nil if callers aren't checked with the house.
+	house api2.HouseAuthServiceClient
+}
+
+func (a *bridgeAuthorizer) Authorize(ctx context.Context, id auth.Identity, method string, req any) error {
+	paired := a.svc.pairing.isPaired()
+	switch method {
+	case api2.BridgeService_GetBridge_FullMethodName:
+		return nil
+	case api2.BridgeService_StartPairing_FullMethodName, api2.BridgeService_Pair_FullMethodName:
+		if !paired {
+			return nil
+		}
+	}
+
+	if !paired {
+		return ErrNotPaired
+	} else if len(id.Token) > 0 && a.svc.pairing.isHouse(id.Token) {
+		return nil
+	}
+
+	switch method {
+	case api2.BridgeService_StartPairing_FullMethodName, api2.BridgeService_Pair_FullMethodName, api2.BridgeService_Unpair_FullMethodName:
+		return errNotHouse
+	}
+
+	access, ok := bridgeMethodAccess[method]
+	if !ok {
+		access = auth.AccessAdmin
+	}
+
+	if a.house == nil {
+		if access == auth.AccessRead {
+			return nil
+		}
+		return auth.ErrPermissionDenied
+	} else if id.Empty() {
+		return auth.ErrUnauthenticated
+	}
+	return a.checkWithHouse(ctx, id, method, access, req)
+}
+
+// checkWithHouse asks the house whether the caller may have the requested access.
+func (a *bridgeAuthorizer) checkWithHouse(ctx context.Context, id auth.Identity, method string, access auth.Access, req any) error {
+	checkReq := &api2.CheckAccessRequest{
+		Access: accessAuthToAPI[access],
+	}
+	if len(id.Token) > 0 {
+		checkReq.Identity = &api2.CheckAccessRequest_Token{Token: id.Token}
+	} else {
+		checkReq.Identity = &api2.CheckAccessRequest_Subject{Subject: id.Subject}
+	}
+	// Requests targeting a device are checked against the scope of the caller.
+	if deviceReq, ok := req.(interface{ GetDeviceId() string }); ok {
+		checkReq.DeviceId = deviceReq.GetDeviceId()
+		if d := a.svc.getDevice(checkReq.DeviceId); d != nil {
+			checkReq.DeviceType = auth.DeviceType(d)
+		}
+	}
+
+	resp, err := a.house.CheckAccess(ctx, checkReq)
+	if err != nil {
+		a.logger.Error("unable to check access with the house", zap.String("method", method), zap.Error(err))
+		return status.Error(codes.Unavailable, "unable to check access")
+	}
+
+	switch resp.Result {
+	case api2.CheckAccessResponse_RESULT_ALLOWED:
+		return nil
+	case api2.CheckAccessResponse_RESULT_UNAUTHENTICATED:
+		return status.Error(codes.Unauthenticated, "token is invalid or has expired")
+	}
+	return auth.ErrPermissionDenied
+}
+
+// houseCredentials presents the token issued by the house during pairing when calling the house.
+type houseCredentials struct {
+	pairing *pairing
+}
+
+func (c houseCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token := c.pairing.houseToken()
+	if len(token) < 1 {
+		return nil, nil
+	}
+	return auth.TokenCredentials(token).GetRequestMetadata(ctx, uri...)
+}
+
+func (c houseCredentials) RequireTransportSecurity() bool {
+	return true
+}
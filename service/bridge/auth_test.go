@@ -0,0 +1,164 @@
+package bridge
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/api/command"
+	"github.com/rmrobinson/house/api/device"
+	"github.com/rmrobinson/house/service/auth"
+)
+
+// fakeHouse answers access checks with a fixed result, recording the last check.
+type fakeHouse struct {
+	api2.HouseAuthServiceClient
+
+	result api2.CheckAccessResponse_Result
+	err    error
+	req    *api2.CheckAccessRequest
+}
+
+func (h *fakeHouse) CheckAccess(ctx context.Context, req *api2.CheckAccessRequest, opts ...grpc.CallOption) (*api2.CheckAccessResponse, error) {
+	h.req = req
+	if h.err != nil {
+		return nil, h.err
+	}
+	return &api2.CheckAccessResponse{Result: h.result}, nil
+}
+
+// newTestAuthorizer creates an authorizer for an unpaired bridge with a single light.
+func newTestAuthorizer(t *testing.T) *bridgeAuthorizer {
+	logger := zaptest.NewLogger(t)
+	svc := NewService(logger)
+	svc.devices["lamp"] = &device.Device{Id: "lamp", Details: &device.Device_Light{Light: &device.Light{}}}
+
+	var err error
+	svc.pairing, err = loadPairing(logger, filepath.Join(t.TempDir(), "pairing.json"), func(string) {})
+	require.NoError(t, err)
+
+	return &bridgeAuthorizer{
+		logger: logger,
+		svc:    svc,
+	}
+}
+
+// pairTestAuthorizer pairs the bridge, returning the token issued to the house.
+func pairTestAuthorizer(t *testing.T, a *bridgeAuthorizer) string {
+	var code string
+	a.svc.pairing.show = func(c string) {
+		if len(c) > 0 {
+			code = c
+		}
+	}
+	_, err := a.svc.pairing.start("192.168.1.10")
+	require.NoError(t, err)
+	token, err := a.svc.pairing.pair("192.168.1.10", code, "house")
+	require.NoError(t, err)
+	return token
+}
+
+func TestBridgeAuthorizerUnpaired(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAuthorizer(t)
+
+	tests := []struct {
+		method string
+		code   codes.Code
+	}{
+		{api2.BridgeService_GetBridge_FullMethodName, codes.OK},
+		{api2.BridgeService_StartPairing_FullMethodName, codes.OK},
+		{api2.BridgeService_Pair_FullMethodName, codes.OK},
+		{api2.BridgeService_Unpair_FullMethodName, codes.FailedPrecondition},
+		{api2.BridgeService_ListDevices_FullMethodName, codes.FailedPrecondition},
+		{api2.BridgeService_ExecuteCommand_FullMethodName, codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.method), func(t *testing.T) {
+			err := a.Authorize(ctx, auth.Identity{}, tt.method, nil)
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func TestBridgeAuthorizerPaired(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAuthorizer(t)
+	houseToken := pairTestAuthorizer(t, a)
+
+	house := auth.Identity{Token: houseToken}
+	other := auth.Identity{Token: "other"}
+
+	tests := []struct {
+		name   string
+		id     auth.Identity
+		method string
+		code   codes.Code
+	}{
+		{"house may unpair", house, api2.BridgeService_Unpair_FullMethodName, codes.OK},
+		{"house may re-pair", house, api2.BridgeService_StartPairing_FullMethodName, codes.OK},
+		{"house may control", house, api2.BridgeService_ExecuteCommand_FullMethodName, codes.OK},
+		{"house may change config", house, api2.BridgeService_UpdateDeviceConfig_FullMethodName, codes.OK},
+		{"anyone may get the bridge", auth.Identity{}, api2.BridgeService_GetBridge_FullMethodName, codes.OK},
+		{"others may read", other, api2.BridgeService_ListDevices_FullMethodName, codes.OK},
+		{"others may not start pairing", other, api2.BridgeService_StartPairing_FullMethodName, codes.PermissionDenied},
+		{"others may not pair", auth.Identity{}, api2.BridgeService_Pair_FullMethodName, codes.PermissionDenied},
+		{"others may not unpair", other, api2.BridgeService_Unpair_FullMethodName, codes.PermissionDenied},
+		{"others may not control", other, api2.BridgeService_ExecuteCommand_FullMethodName, codes.PermissionDenied},
+		{"others may not change config", other, api2.BridgeService_UpdateDeviceConfig_FullMethodName, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.Authorize(ctx, tt.id, tt.method, nil)
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func TestBridgeAuthorizerChecksWithHouse(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAuthorizer(t)
+	houseToken := pairTestAuthorizer(t, a)
+	house := &fakeHouse{result: api2.CheckAccessResponse_RESULT_ALLOWED}
+	a.house = house
+
+	cmd := &command.Command{DeviceId: "lamp"}
+
+	// The paired house isn't checked with itself.
+	require.NoError(t, a.Authorize(ctx, auth.Identity{Token: houseToken}, api2.BridgeService_ExecuteCommand_FullMethodName, cmd))
+	assert.Nil(t, house.req)
+
+	err := a.Authorize(ctx, auth.Identity{}, api2.BridgeService_ListDevices_FullMethodName, nil)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	require.NoError(t, a.Authorize(ctx, auth.Identity{Token: "user", Subject: "ignored"}, api2.BridgeService_ExecuteCommand_FullMethodName, cmd))
+	assert.Equal(t, "user", house.req.GetToken())
+	assert.Equal(t, api2.CheckAccessRequest_ACCESS_CONTROL, house.req.Access)
+	assert.Equal(t, "lamp", house.req.DeviceId)
+	assert.Equal(t, "light", house.req.DeviceType)
+
+	require.NoError(t, a.Authorize(ctx, auth.Identity{Subject: "bridgecli"}, api2.BridgeService_UpdateDeviceConfig_FullMethodName, nil))
+	assert.Equal(t, "bridgecli", house.req.GetSubject())
+	assert.Equal(t, api2.CheckAccessRequest_ACCESS_ADMIN, house.req.Access)
+	assert.Empty(t, house.req.DeviceId)
+
+	house.result = api2.CheckAccessResponse_RESULT_DENIED
+	err = a.Authorize(ctx, auth.Identity{Token: "user"}, api2.BridgeService_ExecuteCommand_FullMethodName, cmd)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	house.result = api2.CheckAccessResponse_RESULT_UNAUTHENTICATED
+	err = a.Authorize(ctx, auth.Identity{Token: "user"}, api2.BridgeService_ListDevices_FullMethodName, nil)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	house.err = status.Error(codes.Unavailable, "house is down")
+	err = a.Authorize(ctx, auth.Identity{Token: "user"}, api2.BridgeService_ListDevices_FullMethodName, nil)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
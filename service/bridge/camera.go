@@ -0,0 +1,81 @@
+package bridge
+
+import (
+	"context"
+	"io"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+)
+
+// clipChunkSize is the amount of a clip sent in each message of the stream.
+const clipChunkSize = 64 * 1024
+
+// These errors are returned by the camera API.
+var (
+	// ErrNotCamera is returned when camera media is requested from a device which isn't a camera.
+	ErrNotCamera = status.Error(codes.InvalidArgument, "the device is not a camera")
+	// ErrCameraMediaNotSupported is returned when camera media is requested from a bridge which can't provide it.
+	ErrCameraMediaNotSupported = status.Error(codes.Unimplemented, "the bridge does not provide camera media")
+	// ErrEventNotFound is returned when the specified event doesn't exist, or wasn't recorded by the specified camera.
+	ErrEventNotFound = status.Error(codes.NotFound, "event id not found")
+)
+
+// CameraMedia may be implemented by a Handler whose devices include cameras, to serve their snapshots and the events
+// they have recorded. The requested device is checked to be a camera of the bridge before the handler is called.
+type CameraMedia interface {
+	GetCameraSnapshot(ctx context.Context, req *api2.GetCameraSnapshotRequest) (*api2.Image, error)
+	ListCameraEvents(ctx context.Context, req *api2.ListCameraEventsRequest) ([]*api2.CameraEvent, error)
+	// GetEventClip returns the content type and contents of the clip. The caller closes the contents once sent.
+	GetEventClip(ctx context.Context, req *api2.GetEventClipRequest) (string, io.ReadCloser, error)
+}
+
+// cameraMedia returns the handler if it is able to serve the media of the specified camera.
+func (s *Service) cameraMedia(deviceID string) (CameraMedia, error) {
+	d := s.getDevice(deviceID)
+	if d == nil {
+		return nil, ErrDeviceNotFound
+	} else if d.GetCamera() == nil {
+		return nil, ErrNotCamera
+	}
+
+	media, ok := s.handler.(CameraMedia)
+	if !ok {
+		return nil, ErrCameraMediaNotSupported
+	}
+	return media, nil
+}
+
+// handlerError ensures the supplied error returned by the handler is a gRPC status.
+func (s *Service) handlerError(err error) error {
+	if _, ok := status.FromError(err); !ok {
+		s.logger.Info("received a non-gRPC status error from the handler. rewriting to internal", zap.Error(err))
+		return status.Error(codes.Internal, err.Error())
+	}
+	return err
+}
+
+// sendClip streams the supplied clip in chunks, with the content type set on the first.
+func sendClip(contentType string, clip io.Reader, stream api2.BridgeService_GetEventClipServer) error {
+	buf := make([]byte, clipChunkSize)
+	chunk := &api2.EventClipChunk{ContentType: contentType}
+	for {
+		n, err := io.ReadFull(clip, buf)
+		// The first chunk is always sent so the content type is known, even if the clip is empty.
+		if n > 0 || len(chunk.ContentType) > 0 {
+			chunk.Data = buf[:n]
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+			chunk = &api2.EventClipChunk{}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		} else if err != nil {
+			return status.Error(codes.Unavailable, "unable to read clip")
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package bridge
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/rmrobinson/house/service/metrics"
+)
+
+var (
+	updateSinks = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "bridge",
+		Name:      "update_sinks",
+		Help:      "Number of sinks receiving updates, one for each StreamUpdates client.",
+	})
+	droppedUpdates = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "bridge",
+		Name:      "dropped_updates_total",
+		Help:      "Number of updates which weren't delivered to a sink as it wasn't keeping up.",
+	})
+	refreshDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "bridge",
+		Name:      "refresh_duration_seconds",
+		Help:      "Time taken by the handler to refresh the state of the bridge.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	refreshFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "bridge",
+		Name:      "refresh_failures_total",
+		Help:      "Number of times the handler failed to refresh the state of the bridge.",
+	})
+	devices = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "bridge",
+		Name:      "devices",
+		Help:      "Number of devices managed by the bridge.",
+	}, []string{"bridge_id"})
+	commands = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "bridge",
+		Name:      "commands_total",
+		Help:      "Number of commands processed by the handler, by device type and status code.",
+	}, []string{"device_type", "code"})
+)
@@ -0,0 +1,327 @@
+package bridge
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// pairingCodeTTL is how long a pairing code remains valid once it has been generated.
+	pairingCodeTTL = 5 * time.Minute
+	// maxPairingAttempts is how many incorrect codes are accepted before the code is discarded.
+	maxPairingAttempts = 3
+	// pairingBackoff is how long a caller is locked out of pairing after entering an incorrect code. It doubles with
+	// every further incorrect code from that caller, up to maxPairingBackoff, until the bridge is paired. As the code
+	// is only 4 digits, so it fits on the displays of bridges, this is what keeps it from being guessed. Lockouts are
+	// kept per caller so that guessing from one host doesn't keep the house from pairing.
+	pairingBackoff    = time.Second
+	maxPairingBackoff = time.Hour
+)
+
+// These errors are returned by the pairing API.
+var (
+	// ErrNotPaired is returned for requests made to a bridge which hasn't been paired with a house.
+	ErrNotPaired = status.Error(codes.FailedPrecondition, "bridge is not paired")
+	// ErrPairingNotEnabled is returned if pairing is requested from a bridge served without TLS.
+	ErrPairingNotEnabled = status.Error(codes.FailedPrecondition, "pairing requires the bridge to be served with tls")
+	// ErrInvalidPairingCode is returned if the supplied pairing code doesn't match, or has expired.
+	ErrInvalidPairingCode = status.Error(codes.InvalidArgument, "pairing code is invalid or has expired")
+	// ErrPairingLockedOut is returned while the caller is locked out of pairing after an incorrect code.
+	ErrPairingLockedOut = status.Error(codes.ResourceExhausted, "too many incorrect pairing codes; try again later")
+	// ErrPairingFileRequired is returned when TLS is configured without a file to persist the pairing to.
+	ErrPairingFileRequired = errors.New("pairing.file must be set when tls is configured")
+)
+
+// PairingDisplay may be implemented by a Handler which is able to show the pairing code, such as on a display
+// attached to the bridge. The code is always logged.
+type PairingDisplay interface {
+	// ShowPairingCode displays the supplied code. It is called with an empty code once the code is no longer valid.
+	ShowPairingCode(code string)
+}
+
+// PairingConfig contains the settings used to pair the bridge with a house, read from the 'pairing' key of the bridge config.
+type PairingConfig struct {
+	// File is where the credentials exchanged with the house are persisted. Environment variables are expanded.
+	File string `mapstructure:"file"`
+}
+
+// pairingState is persisted once the bridge has been paired.
+type pairingState struct {
+	// HouseToken is presented by the bridge when calling the house.
+	HouseToken string `json:"house_token"`
+	// TokenHash is the hash of the token presented by the house when calling the bridge.
+	TokenHash string    `json:"token_hash"`
+	PairedAt  time.Time `json:"paired_at"`
+}
+
+// pairingLockout tracks the incorrect codes entered by a caller. Lockouts are persisted alongside the pairing file,
+// keyed by the host of the caller, so restarting the bridge doesn't reset the backoff.
+type pairingLockout struct {
+	// Failures is how many incorrect codes the caller has entered since the bridge was last paired.
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// pairing tracks whether the bridge is paired, and the code of any pairing in progress.
+type pairing struct {
+	logger *zap.Logger
+	path   string
+	show   func(code string)
+
+	lock     sync.Mutex
+	state    *pairingState
+	lockouts map[string]pairingLockout
+	code     string
+	attempts int
+	timer    *time.Timer
+}
+
+// loadPairing reads the pairing persisted at the specified path. The bridge is unpaired if the file doesn't exist.
+func loadPairing(logger *zap.Logger, path string, show func(code string)) (*pairing, error) {
+	p := &pairing{
+		logger:   logger,
+		path:     path,
+		show:     show,
+		lockouts: map[string]pairingLockout{},
+	}
+
+	if err := readJSON(p.lockoutPath(), &p.lockouts); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if p.lockouts == nil {
+		p.lockouts = map[string]pairingLockout{}
+	}
+
+	state := &pairingState{}
+	if err := readJSON(path, state); errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	} else if err != nil {
+		return nil, err
+	}
+	p.state = state
+	return p, nil
+}
+
+func (p *pairing) isPaired() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.state != nil
+}
+
+// isHouse returns true if the supplied token was issued to the paired house.
+func (p *pairing) isHouse(token string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.state == nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(p.state.TokenHash)) == 1
+}
+
+// houseToken returns the token to present to the paired house, or an empty string if the bridge isn't paired.
+func (p *pairing) houseToken() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.state == nil {
+		return ""
+	}
+	return p.state.HouseToken
+}
+
+// start generates a new pairing code for the caller on the specified host, replacing any code which was previously
+// generated.
+func (p *pairing) start(host string) (time.Duration, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(10000))
+	if err != nil {
+		return 0, err
+	}
+	code := fmt.Sprintf("%04d", n.Int64())
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.lockedOut(host) {
+		return 0, ErrPairingLockedOut
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.code = code
+	p.attempts = 0
+	p.timer = time.AfterFunc(pairingCodeTTL, func() {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		if p.code == code {
+			p.logger.Info("pairing code expired")
+			p.clearCode()
+		}
+	})
+
+	p.logger.Info("pairing started; enter the code to complete it", zap.String("code", code), zap.Duration("expires_in", pairingCodeTTL))
+	p.show(code)
+	return pairingCodeTTL, nil
+}
+
+// pair checks the code supplied by the caller on the specified host and, if it matches, persists the house token along
+// with a new token for the house. The new token is returned so it can be supplied to the house.
+func (p *pairing) pair(host string, code string, houseToken string) (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.lockedOut(host) {
+		return "", ErrPairingLockedOut
+	} else if len(p.code) < 1 {
+		return "", ErrInvalidPairingCode
+	} else if subtle.ConstantTimeCompare([]byte(code), []byte(p.code)) != 1 {
+		p.recordFailure(host)
+		p.attempts++
+		if p.attempts >= maxPairingAttempts {
+			p.logger.Info("too many incorrect pairing codes; pairing must be started again")
+			p.clearCode()
+		}
+		return "", ErrInvalidPairingCode
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	state := &pairingState{
+		HouseToken: houseToken,
+		TokenHash:  hashToken(token),
+		PairedAt:   time.Now(),
+	}
+	if err := writeJSON(p.path, state); err != nil {
+		return "", err
+	}
+
+	p.state = state
+	p.clearCode()
+	p.lockouts = map[string]pairingLockout{}
+	if err := os.Remove(p.lockoutPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		p.logger.Error("unable to remove pairing lockout file", zap.Error(err))
+	}
+	return token, nil
+}
+
+// unpair forgets the credentials of the paired house.
+func (p *pairing) unpair() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := os.Remove(p.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	p.state = nil
+	return nil
+}
+
+// clearCode discards the current pairing code. The lock must be held.
+func (p *pairing) clearCode() {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.code = ""
+	p.attempts = 0
+	p.show("")
+}
+
+// lockedOut returns true if the caller on the host is locked out after an incorrect code. The lock must be held.
+func (p *pairing) lockedOut(host string) bool {
+	return time.Now().Before(p.lockouts[host].LockedUntil)
+}
+
+// recordFailure locks the caller on the host out of pairing after an incorrect code, for twice as long as after
+// their previous one. Callers who haven't been locked out for the longest backoff are forgotten, so the lockouts
+// don't grow without bound. The lockouts are persisted; if that fails they still apply until the bridge restarts.
+// The lock must be held.
+func (p *pairing) recordFailure(host string) {
+	now := time.Now()
+	for h, lockout := range p.lockouts {
+		if now.Sub(lockout.LockedUntil) > maxPairingBackoff {
+			delete(p.lockouts, h)
+		}
+	}
+
+	lockout := p.lockouts[host]
+	lockout.Failures++
+	backoff := maxPairingBackoff
+	if lockout.Failures <= 32 {
+		backoff = min(pairingBackoff<<(lockout.Failures-1), maxPairingBackoff)
+	}
+	lockout.LockedUntil = now.Add(backoff)
+	p.lockouts[host] = lockout
+
+	p.logger.Info("incorrect pairing code; caller is locked out of pairing",
+		zap.String("host", host), zap.Int("failures", lockout.Failures), zap.Duration("backoff", backoff))
+	if err := writeJSON(p.lockoutPath(), p.lockouts); err != nil {
+		p.logger.Error("unable to save pairing lockouts", zap.Error(err))
+	}
+}
+
+// lockoutPath is where the lockout is persisted.
+func (p *pairing) lockoutPath() string {
+	return p.path + ".lockout"
+}
+
+// readJSON parses the contents of the specified file into v.
+func readJSON(path string, v any) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(contents, v); err != nil {
+		return fmt.Errorf("unable to parse pairing file %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSON writes v to the specified file. The file is replaced atomically as it may hold the only copy of the
+// credentials.
+func writeJSON(path string, v any) error {
+	contents, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, contents, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
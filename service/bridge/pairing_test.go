@@ -0,0 +1,229 @@
+package bridge
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+)
+
+// houseHost is the host the house pairs from in the tests.
+const houseHost = "192.168.1.10"
+
+// unlock ends the lockout of the host, as if the backoff had passed.
+func (p *pairing) unlock(host string) {
+	lockout := p.lockouts[host]
+	lockout.LockedUntil = time.Now()
+	p.lockouts[host] = lockout
+}
+
+func TestPairing(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	path := filepath.Join(t.TempDir(), "pairing.json")
+
+	var shown string
+	p, err := loadPairing(logger, path, func(code string) { shown = code })
+	require.NoError(t, err)
+	assert.False(t, p.isPaired())
+
+	_, err = p.pair(houseHost, "", "house")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = p.start(houseHost)
+	require.NoError(t, err)
+	require.Len(t, shown, 4)
+	code := shown
+
+	token, err := p.pair(houseHost, code, "house")
+	require.NoError(t, err)
+	assert.True(t, p.isPaired())
+	assert.True(t, p.isHouse(token))
+	assert.False(t, p.isHouse("other"))
+	assert.Equal(t, "house", p.houseToken())
+	assert.Empty(t, shown)
+
+	// The code can only be used once.
+	_, err = p.pair(houseHost, code, "house")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	p, err = loadPairing(logger, path, func(code string) { shown = code })
+	require.NoError(t, err)
+	assert.True(t, p.isHouse(token))
+
+	require.NoError(t, p.unpair())
+	assert.False(t, p.isPaired())
+	assert.False(t, p.isHouse(token))
+	assert.Empty(t, p.houseToken())
+}
+
+func TestPairingAttempts(t *testing.T) {
+	var shown string
+	p, err := loadPairing(zaptest.NewLogger(t), filepath.Join(t.TempDir(), "pairing.json"), func(code string) { shown = code })
+	require.NoError(t, err)
+
+	_, err = p.start(houseHost)
+	require.NoError(t, err)
+	code := shown
+
+	wrong := "0000"
+	if code == wrong {
+		wrong = "1111"
+	}
+	for i := 0; i < maxPairingAttempts; i++ {
+		_, err = p.pair(houseHost, wrong, "house")
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		// Every incorrect code locks the caller out of pairing for a while, even with the correct code.
+		if i < maxPairingAttempts-1 {
+			_, err = p.pair(houseHost, code, "house")
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		}
+		p.unlock(houseHost)
+	}
+
+	// Too many incorrect attempts discard the code.
+	assert.Empty(t, shown)
+	_, err = p.pair(houseHost, code, "house")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.False(t, p.isPaired())
+}
+
+func TestPairingLockout(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	path := filepath.Join(t.TempDir(), "pairing.json")
+
+	var shown string
+	p, err := loadPairing(logger, path, func(code string) { shown = code })
+	require.NoError(t, err)
+
+	_, err = p.start(houseHost)
+	require.NoError(t, err)
+	wrong := "0000"
+	if shown == wrong {
+		wrong = "1111"
+	}
+	_, err = p.pair(houseHost, wrong, "house")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.WithinDuration(t, time.Now().Add(pairingBackoff), p.lockouts[houseHost].LockedUntil, pairingBackoff)
+
+	// The lockout survives a restart, and prevents pairing from being started again.
+	p, err = loadPairing(logger, path, func(code string) { shown = code })
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.lockouts[houseHost].Failures)
+	_, err = p.start(houseHost)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Each further incorrect code doubles the backoff, even once a new code has been started.
+	p.unlock(houseHost)
+	_, err = p.start(houseHost)
+	require.NoError(t, err)
+	code := shown
+	wrong = "0000"
+	if code == wrong {
+		wrong = "1111"
+	}
+	_, err = p.pair(houseHost, wrong, "house")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, 2, p.lockouts[houseHost].Failures)
+	assert.WithinDuration(t, time.Now().Add(2*pairingBackoff), p.lockouts[houseHost].LockedUntil, pairingBackoff)
+
+	p.lockouts[houseHost] = pairingLockout{Failures: 100, LockedUntil: time.Now()}
+	_, err = p.pair(houseHost, wrong, "house")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.WithinDuration(t, time.Now().Add(maxPairingBackoff), p.lockouts[houseHost].LockedUntil, time.Second)
+
+	// Pairing resets the lockout.
+	p.unlock(houseHost)
+	_, err = p.pair(houseHost, code, "house")
+	require.NoError(t, err)
+	assert.Empty(t, p.lockouts)
+	_, err = os.Stat(p.lockoutPath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPairingLockoutPerHost(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	path := filepath.Join(t.TempDir(), "pairing.json")
+
+	var shown string
+	p, err := loadPairing(logger, path, func(code string) { shown = code })
+	require.NoError(t, err)
+
+	// Guessing from another host locks that host out, but leaves the house able to pair.
+	_, err = p.start("192.168.1.66")
+	require.NoError(t, err)
+	wrong := "0000"
+	if shown == wrong {
+		wrong = "1111"
+	}
+	_, err = p.pair("192.168.1.66", wrong, "house")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = p.start("192.168.1.66")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Lockouts which ended longer ago than the longest backoff are forgotten.
+	p.lockouts["192.168.1.99"] = pairingLockout{Failures: 5, LockedUntil: time.Now().Add(-2 * maxPairingBackoff)}
+
+	_, err = p.start(houseHost)
+	require.NoError(t, err)
+	code := shown
+	_, err = p.pair(houseHost, wrong, "house")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.NotContains(t, p.lockouts, "192.168.1.99")
+
+	// The lockouts of each host survive a restart.
+	p, err = loadPairing(logger, path, func(code string) { shown = code })
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.lockouts["192.168.1.66"].Failures)
+	assert.Equal(t, 1, p.lockouts[houseHost].Failures)
+	_, err = p.pair(houseHost, code, "house")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestPairingAPILockout(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	svc := NewService(logger)
+	svc.RegisterHandler(&testHandler{}, &api2.Bridge{Id: "bridge1"})
+
+	var shown string
+	var err error
+	svc.pairing, err = loadPairing(logger, filepath.Join(t.TempDir(), "pairing.json"), func(code string) { shown = code })
+	require.NoError(t, err)
+
+	fromHost := func(host string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(host), Port: 50000}})
+	}
+	guesser := fromHost("192.168.1.66")
+	house := fromHost(houseHost)
+
+	_, err = svc.API().StartPairing(guesser, &api2.StartPairingRequest{})
+	require.NoError(t, err)
+	wrong := "0000"
+	if shown == wrong {
+		wrong = "1111"
+	}
+	_, err = svc.API().Pair(guesser, &api2.PairRequest{Code: wrong, HouseToken: "house"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// The lockout is reported as such, so the caller knows to try again later.
+	_, err = svc.API().StartPairing(guesser, &api2.StartPairingRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// The house isn't locked out by the guesses of another host.
+	_, err = svc.API().StartPairing(house, &api2.StartPairingRequest{})
+	require.NoError(t, err)
+	resp, err := svc.API().Pair(house, &api2.PairRequest{Code: shown, HouseToken: "house"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+}
@@ -1,55 +1,210 @@
 package bridge
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
+	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
 	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/service/auth"
+	"github.com/rmrobinson/house/service/certs"
+	"github.com/rmrobinson/house/service/metrics"
+	"github.com/rmrobinson/house/service/tracing"
 )
 
+// ServerConfig contains the settings shared by every bridge server, read from the 'tls', 'auth', 'pairing', 'metrics'
+// and 'tracing' keys of the bridge config.
+type ServerConfig struct {
+	TLS     certs.Config   `mapstructure:"tls"`
+	Auth    AuthConfig     `mapstructure:"auth"`
+	Pairing PairingConfig  `mapstructure:"pairing"`
+	Metrics metrics.Config `mapstructure:"metrics"`
+	Tracing tracing.Config `mapstructure:"tracing"`
+}
+
+// ServerOptions returns the options needed to serve the bridge using the supplied config.
+// When TLS is configured the bridge must be paired with a house before it can be used, and authorization may also
+// be configured; without TLS neither are possible, as the credentials involved can't be exchanged securely.
+// The metrics settings are applied to the service, and used once the server is started. Traces are exported from
+// now on, named after the bridge executable, and flushed once the server stops.
+func ServerOptions(logger *zap.Logger, svc *Service, config ServerConfig) ([]grpc.ServerOption, error) {
+	svc.setMetrics(config.Metrics)
+
+	flushTraces, err := tracing.Setup(context.Background(), logger, filepath.Base(os.Args[0]), config.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	svc.flushTraces = flushTraces
+
+	config.TLS.ClientCertOptional = len(config.Auth.House) > 0
+	opts, err := certs.ServerOptions(config.TLS)
+	if err != nil {
+		return nil, err
+	} else if len(opts) < 1 {
+		if len(config.Auth.House) > 0 {
+			return nil, ErrAuthRequiresTLS
+		}
+		logger.Warn("tls isn't configured; the bridge can't be paired and will accept requests from anyone")
+		return nil, nil
+	} else if len(config.Pairing.File) < 1 {
+		return nil, ErrPairingFileRequired
+	}
+
+	svc.pairing, err = loadPairing(logger, os.ExpandEnv(config.Pairing.File), svc.showPairingCode)
+	if err != nil {
+		return nil, err
+	}
+	if !svc.pairing.isPaired() {
+		logger.Info("bridge isn't paired; pair it from the house to use it")
+	}
+
+	authorizer := &bridgeAuthorizer{
+		logger: logger,
+		svc:    svc,
+	}
+	if len(config.Auth.House) > 0 {
+		creds, err := certs.DialOption(config.Auth.TLS)
+		if err != nil {
+			return nil, err
+		}
+		dialOpts := []grpc.DialOption{creds, tracing.DialOption()}
+		if config.Auth.TLS != (certs.Config{}) {
+			dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(houseCredentials{svc.pairing}))
+		}
+		conn, err := grpc.Dial(config.Auth.House, dialOpts...)
+		if err != nil {
+			return nil, err
+		}
+		authorizer.house = api2.NewHouseAuthServiceClient(conn)
+	}
+	return append(opts, auth.ServerOptions(authorizer)...), nil
+}
+
+// shutdownTimeout is how long in-flight requests are given to complete once the server is stopping.
+const shutdownTimeout = 10 * time.Second
+
 // Server creates a new network server hosting the Bridge gRPC server.
 type Server struct {
 	logger     *zap.Logger
 	grpcServer *grpc.Server
+	health     *health.Server
 	svc        *Service
 }
 
 // NewServer creates a new server with an opinionated set of options set.
+// Additional options, such as those returned by ServerOptions, are applied to the gRPC server.
+// The standard gRPC health service reports whether the bridge is ready and refreshing successfully, and reflection
+// is registered so the server can be explored with tools like grpcurl. Every request is recorded in the metrics, and
+// traced if tracing has been set up.
 // Once ready it is necessary to call Serve() or ServeOnPort() to expose the service.
-func NewServer(logger *zap.Logger, svc *Service) *Server {
-	var opts []grpc.ServerOption
+func NewServer(logger *zap.Logger, svc *Service, opts ...grpc.ServerOption) *Server {
+	opts = append(append(tracing.ServerOptions(), metrics.ServerOptions()...), opts...)
 	grpcServer := grpc.NewServer(opts...)
+	healthServer := health.NewServer()
 
 	api2.RegisterBridgeServiceServer(grpcServer, svc.API())
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	reflection.Register(grpcServer)
+
+	svc.watchHealth(func(serving bool) {
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		if serving {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+		healthServer.SetServingStatus("", status)
+		healthServer.SetServingStatus(api2.BridgeService_ServiceDesc.ServiceName, status)
+	})
 
 	return &Server{
 		logger:     logger,
 		grpcServer: grpcServer,
+		health:     healthServer,
 		svc:        svc,
 	}
 }
 
-// Serve runs the network listener on a random port.
-func (s *Server) Serve() error {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", 0))
+// Serve runs the network listener on a random port until the context is cancelled.
+func (s *Server) Serve(ctx context.Context) error {
+	return s.ServeOnPort(ctx, 0)
+}
+
+// ServeOnPort runs the network listener on the specified port until the context is cancelled.
+// The server then stops gracefully: update streams are ended and in-flight requests are given time to complete
+// before this returns. If a metrics address is configured the metrics are served alongside.
+func (s *Server) ServeOnPort(ctx context.Context, port int) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
-		s.logger.Fatal("failed to listen", zap.Error(err))
+		return err
 	}
+	return s.serve(ctx, lis)
+}
+
+// serve accepts requests on the supplied listener until the context is cancelled, or serving fails.
+// The server is stopped either way before this returns.
+func (s *Server) serve(ctx context.Context, lis net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if len(s.svc.metrics.Address) > 0 {
+		go func() {
+			if err := metrics.Serve(ctx, s.logger, s.svc.metrics.Address); err != nil {
+				s.logger.Error("unable to serve metrics", zap.Error(err))
+			}
+		}()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		s.stop()
+		close(stopped)
+	}()
 
 	s.logger.Info("accepting requests", zap.String("address", lis.Addr().String()))
-	return s.grpcServer.Serve(lis)
+	if err := s.grpcServer.Serve(lis); err != nil {
+		s.logger.Error("unable to serve requests", zap.Error(err))
+		cancel()
+		<-stopped
+		return err
+	}
+
+	<-stopped
+	return nil
 }
 
-// ServeOnPort runs the network listener on the specified port.
-func (s *Server) ServeOnPort(port int) error {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
-	if err != nil {
-		s.logger.Fatal("failed to listen", zap.Error(err))
+func (s *Server) stop() {
+	s.logger.Info("shutting down")
+
+	s.health.Shutdown()
+	s.svc.shutdown()
+
+	drained := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(shutdownTimeout):
+		s.logger.Warn("requests didn't complete in time; stopping")
+		s.grpcServer.Stop()
 	}
 
-	s.logger.Info("accepting requests", zap.String("address", lis.Addr().String()))
-	return s.grpcServer.Serve(lis)
+	if s.svc.flushTraces != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := s.svc.flushTraces(ctx); err != nil {
+			s.logger.Error("unable to flush traces", zap.Error(err))
+		}
+	}
 }
@@ -0,0 +1,188 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/api/command"
+	"github.com/rmrobinson/house/api/device"
+)
+
+// testHandler is a handler whose refreshes fail with a settable error.
+type testHandler struct {
+	lock       sync.Mutex
+	refreshErr error
+}
+
+func (h *testHandler) SetBridgeConfig(ctx context.Context, config Config) error {
+	return nil
+}
+
+func (h *testHandler) ProcessCommand(ctx context.Context, cmd *command.Command) (*device.Device, error) {
+	return nil, ErrUnsupportedCommand
+}
+
+func (h *testHandler) Refresh(ctx context.Context) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.refreshErr
+}
+
+func (h *testHandler) setRefreshErr(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.refreshErr = err
+}
+
+// startTestServer serves the service on a local port until the test ends, returning a connection to it and a channel
+// receiving the result of serving.
+func startTestServer(t *testing.T, ctx context.Context, svc *Service) (*grpc.ClientConn, <-chan error) {
+	srv := NewServer(zaptest.NewLogger(t), svc)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.serve(ctx, lis)
+	}()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn, served
+}
+
+// recvHealth waits for the next status sent on the health stream.
+func recvHealth(t *testing.T, stream healthpb.Health_WatchClient) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	return resp.Status
+}
+
+func TestServerHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := NewService(zaptest.NewLogger(t))
+	conn, _ := startTestServer(t, ctx, svc)
+	client := healthpb.NewHealthClient(conn)
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: api2.BridgeService_ServiceDesc.ServiceName})
+	require.NoError(t, err)
+
+	// The bridge isn't serving until the handler is registered.
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, recvHealth(t, stream))
+
+	handler := &testHandler{}
+	svc.RegisterHandler(handler, &api2.Bridge{Id: "bridge1"})
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, recvHealth(t, stream))
+
+	handler.setRefreshErr(errors.New("device unreachable"))
+	require.Error(t, svc.Refresh(ctx))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, recvHealth(t, stream))
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	handler.setRefreshErr(nil)
+	require.NoError(t, svc.Refresh(ctx))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, recvHealth(t, stream))
+}
+
+func TestServerHealthDuringBridgeUpdates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := NewService(zaptest.NewLogger(t))
+	svc.RegisterHandler(&testHandler{}, &api2.Bridge{Id: "bridge1"})
+	conn, _ := startTestServer(t, ctx, svc)
+	client := healthpb.NewHealthClient(conn)
+
+	// Health is checked while the bridge changes, as happens when a bridge reports its state; run with -race.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			svc.UpdateBridge(&api2.Bridge{Id: "bridge1", Config: &api2.Bridge_Config{Name: fmt.Sprintf("bridge %d", i)}})
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+		require.NoError(t, svc.Refresh(ctx))
+	}
+	<-done
+}
+
+func TestServerShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := NewService(zaptest.NewLogger(t))
+	svc.RegisterHandler(&testHandler{}, &api2.Bridge{Id: "bridge1"})
+	conn, served := startTestServer(t, ctx, svc)
+
+	streamCtx, streamCancel := context.WithCancel(context.Background())
+	defer streamCancel()
+	updates, err := api2.NewBridgeServiceClient(conn).StreamUpdates(streamCtx, &api2.StreamUpdatesRequest{})
+	require.NoError(t, err)
+	update, err := updates.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, api2.Update_INITIAL, update.Action)
+
+	health, err := healthpb.NewHealthClient(conn).Watch(streamCtx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, recvHealth(t, health))
+
+	cancel()
+
+	// Update streams are ended, and health watchers are told the bridge is no longer serving.
+	_, err = updates.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, recvHealth(t, health))
+
+	// Health watches are left to the caller to end; once they have, the server stops without waiting out the timeout.
+	streamCancel()
+	select {
+	case err := <-served:
+		assert.NoError(t, err)
+	case <-time.After(shutdownTimeout / 2):
+		t.Fatal("server didn't stop")
+	}
+}
+
+func TestServerServeFails(t *testing.T) {
+	svc := NewService(zaptest.NewLogger(t))
+	srv := NewServer(zaptest.NewLogger(t), svc)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	lis.Close()
+
+	// The error is returned, and the server is stopped rather than left waiting for the context to be cancelled.
+	require.Error(t, srv.serve(context.Background(), lis))
+	select {
+	case <-svc.done:
+	default:
+		t.Fatal("service wasn't shut down")
+	}
+}
@@ -3,7 +3,12 @@ package bridge
 import (
 	"context"
 	"sync"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -12,8 +17,13 @@ import (
 	api2 "github.com/rmrobinson/house/api"
 	"github.com/rmrobinson/house/api/command"
 	"github.com/rmrobinson/house/api/device"
+	"github.com/rmrobinson/house/service/auth"
+	"github.com/rmrobinson/house/service/metrics"
 )
 
+// tracer records the time spent in the handler, so it can be told apart from the time spent serving the request.
+var tracer = otel.Tracer("github.com/rmrobinson/house/service/bridge")
+
 // Config contains the settable parts of the bridge configuration
 type Config struct {
 	Name        string
@@ -41,6 +51,24 @@ type Service struct {
 	devicesLock sync.Mutex
 
 	updates *Source
+
+	// pairing is nil if the bridge can't be paired, which is the case when it is served without TLS.
+	pairing *pairing
+
+	metrics metrics.Config
+	// flushTraces is called once the bridge has stopped, to export any remaining spans.
+	flushTraces func(context.Context) error
+
+	healthLock sync.Mutex
+	// registered is set once the handler has been registered. It is kept apart from the bridge so the health of the
+	// bridge can be checked while the bridge is updated.
+	registered     bool
+	refreshErr     error
+	onHealthChange func(serving bool)
+
+	// done is closed once the bridge starts shutting down.
+	done     chan struct{}
+	doneOnce sync.Once
 }
 
 // NewService creates a new device service
@@ -49,6 +77,7 @@ func NewService(logger *zap.Logger) *Service {
 		logger:  logger,
 		devices: make(map[string]*device.Device),
 		updates: NewSource(logger),
+		done:    make(chan struct{}),
 	}
 	svc.api = newAPI(logger, svc)
 
@@ -71,15 +100,52 @@ func (s *Service) RegisterHandler(h Handler, b *api2.Bridge) {
 	s.handler = h
 	s.bridge = b
 
+	s.devicesLock.Lock()
+	s.recordDeviceCount()
+	s.devicesLock.Unlock()
+
+	s.healthLock.Lock()
+	s.registered = true
+	s.healthLock.Unlock()
+
 	s.updates.SendMessage(&api2.Update{
 		Action: api2.Update_ADDED,
 		Update: &api2.Update_BridgeUpdate{
 			BridgeUpdate: &api2.BridgeUpdate{
 				BridgeId: s.bridge.GetId(),
-				Bridge:   proto.Clone(s.bridge).(*api2.Bridge),
+				Bridge:   s.getBridge(),
 			},
 		},
 	})
+
+	s.healthChanged()
+}
+
+// Refresh asks the handler to refresh the state of the bridge and its devices.
+// Bridges should refresh through this rather than calling their handler directly, as the result is reported as the
+// health of the bridge until the next refresh.
+func (s *Service) Refresh(ctx context.Context) error {
+	if s.handler == nil {
+		return ErrBridgeNotReady
+	}
+
+	ctx, span := tracer.Start(ctx, "Handler.Refresh")
+	defer span.End()
+
+	start := time.Now()
+	err := s.handler.Refresh(ctx)
+	refreshDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		refreshFailures.Inc()
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+
+	s.healthLock.Lock()
+	s.refreshErr = err
+	s.healthLock.Unlock()
+
+	s.healthChanged()
+	return err
 }
 
 // UpdateBridge takes the supplied bridge info and updates it within the service.
@@ -102,7 +168,7 @@ func (s *Service) UpdateBridge(b *api2.Bridge) {
 		Update: &api2.Update_BridgeUpdate{
 			BridgeUpdate: &api2.BridgeUpdate{
 				BridgeId: s.bridge.GetId(),
-				Bridge:   proto.Clone(s.bridge).(*api2.Bridge),
+				Bridge:   s.getBridge(),
 			},
 		},
 	})
@@ -138,6 +204,10 @@ func (s *Service) UpdateDevice(d *device.Device) {
 		)
 		s.devices[d.Id] = dClone
 		action = api2.Update_ADDED
+		s.recordDeviceCount()
+	}
+	if s.metrics.Traits {
+		metrics.RecordDevice(dClone)
 	}
 
 	s.updates.SendMessage(&api2.Update{
@@ -164,6 +234,10 @@ func (s *Service) RemoveDevice(id string) {
 
 	if _, found := s.devices[id]; found {
 		delete(s.devices, id)
+		s.recordDeviceCount()
+	}
+	if s.metrics.Traits {
+		metrics.ForgetDevice(id)
 	}
 
 	s.updates.SendMessage(&api2.Update{
@@ -177,8 +251,88 @@ func (s *Service) RemoveDevice(id string) {
 	})
 }
 
+// setMetrics applies the supplied metrics config, recording the traits of the existing devices if they are exported.
+func (s *Service) setMetrics(config metrics.Config) {
+	s.devicesLock.Lock()
+	defer s.devicesLock.Unlock()
+
+	s.metrics = config
+	if config.Traits {
+		for _, d := range s.devices {
+			metrics.RecordDevice(d)
+		}
+	}
+}
+
+// recordDeviceCount updates the number of devices reported for the bridge. The devices lock must be held.
+func (s *Service) recordDeviceCount() {
+	if s.bridge != nil {
+		devices.WithLabelValues(s.bridge.GetId()).Set(float64(len(s.devices)))
+	}
+}
+
+// isServing returns true if the handler has been registered and its last refresh succeeded.
+func (s *Service) isServing() bool {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+
+	return s.registered && s.refreshErr == nil
+}
+
+// watchHealth calls the supplied function with the current health of the bridge, and again whenever it may have changed.
+func (s *Service) watchHealth(fn func(serving bool)) {
+	s.healthLock.Lock()
+	s.onHealthChange = fn
+	s.healthLock.Unlock()
+
+	s.healthChanged()
+}
+
+func (s *Service) healthChanged() {
+	s.healthLock.Lock()
+	fn := s.onHealthChange
+	s.healthLock.Unlock()
+
+	if fn != nil {
+		fn(s.isServing())
+	}
+}
+
+// shutdown ends any update streams, as the bridge is about to stop.
+func (s *Service) shutdown() {
+	s.doneOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// publishPairing notifies watchers that the bridge has been paired or unpaired.
+func (s *Service) publishPairing() {
+	s.updates.SendMessage(&api2.Update{
+		Action: api2.Update_CHANGED,
+		Update: &api2.Update_BridgeUpdate{
+			BridgeUpdate: &api2.BridgeUpdate{
+				BridgeId: s.bridge.GetId(),
+				Bridge:   s.getBridge(),
+			},
+		},
+	})
+}
+
+// showPairingCode displays the pairing code if the handler is able to.
+func (s *Service) showPairingCode(code string) {
+	if display, ok := s.handler.(PairingDisplay); ok {
+		display.ShowPairingCode(code)
+	}
+}
+
+// getBridge returns a copy of the bridge, with its pairing state filled in.
 func (s *Service) getBridge() *api2.Bridge {
-	return proto.Clone(s.bridge).(*api2.Bridge)
+	b := proto.Clone(s.bridge).(*api2.Bridge)
+	if b.State == nil {
+		b.State = &api2.Bridge_State{}
+	}
+	b.State.IsPaired = s.pairing != nil && s.pairing.isPaired()
+	return b
 }
 
 func (s *Service) getDevices() []*device.Device {
@@ -204,16 +358,24 @@ func (s *Service) getDevice(id string) *device.Device {
 }
 
 func (s *Service) processCommand(ctx context.Context, cmd *command.Command) (*device.Device, error) {
+	deviceType := auth.DeviceType(s.getDevice(cmd.DeviceId))
+
+	ctx, span := tracer.Start(ctx, "Handler.ProcessCommand", trace.WithAttributes(
+		attribute.String("device_id", cmd.DeviceId),
+		attribute.String("device_type", deviceType),
+	))
 	retDevice, err := s.handler.ProcessCommand(ctx, cmd)
+	if err != nil {
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
 	// In case of error, forward the error on
 	if err != nil {
-		if _, ok := status.FromError(err); !ok {
-			s.logger.Info("received a non-gRPC status error when processing command. rewriting to unknown",
-				zap.Error(err))
-			return nil, status.Error(codes.Internal, err.Error())
-		}
+		err = s.handlerError(err)
+		commands.WithLabelValues(deviceType, status.Code(err).String()).Inc()
 		return nil, err
 	}
+	commands.WithLabelValues(deviceType, codes.OK.String()).Inc()
 
 	// Check if the new device state is different from what we have internally - and if it is update & publish this change.
 	existingDevice := s.getDevice(cmd.DeviceId)
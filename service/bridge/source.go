@@ -36,6 +36,8 @@ func (s *Source) NewSink() *Sink {
 	s.sinks[sink.id] = sink
 	s.sinksLock.Unlock()
 
+	updateSinks.Inc()
+
 	s.logger.Debug("added watcher",
 		zap.String("channel_id", sink.id))
 	return sink
@@ -54,6 +56,7 @@ func (s *Source) SendMessage(msg proto.Message) {
 			s.logger.Debug("channel blocked",
 				zap.String("channel_id", sink.id),
 			)
+			droppedUpdates.Inc()
 		}
 	}
 
@@ -64,4 +67,6 @@ func (s *Source) removeSink(sink *Sink) {
 	s.sinksLock.Lock()
 	delete(s.sinks, sink.id)
 	s.sinksLock.Unlock()
+
+	updateSinks.Dec()
 }
@@ -0,0 +1,87 @@
+package certs
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func writeCert(t *testing.T, dir string, name string, certPEM []byte, keyPEM []byte) Config {
+	config := Config{
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+	require.NoError(t, os.WriteFile(config.CertFile, certPEM, 0644))
+	require.NoError(t, os.WriteFile(config.KeyFile, keyPEM, 0600))
+	return config
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	ca, err := NewCA("test CA")
+	require.NoError(t, err)
+	caKeyPEM, err := ca.KeyPEM()
+	require.NoError(t, err)
+	ca, err = LoadCA(ca.CertPEM(), caKeyPEM)
+	require.NoError(t, err)
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, ca.CertPEM(), 0644))
+
+	certPEM, keyPEM, err := ca.Issue("bridge", []string{"127.0.0.1"}, ServerUsage)
+	require.NoError(t, err)
+	serverConfig := writeCert(t, dir, "bridge", certPEM, keyPEM)
+	serverConfig.CAFile = caFile
+
+	certPEM, keyPEM, err = ca.Issue("bridgecli", nil, ClientUsage)
+	require.NoError(t, err)
+	clientConfig := writeCert(t, dir, "bridgecli", certPEM, keyPEM)
+	clientConfig.CAFile = caFile
+
+	opts, err := ServerOptions(serverConfig)
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer(opts...)
+	grpc_health_v1.RegisterHealthServer(grpcServer, health.NewServer())
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	check := func(config Config) error {
+		creds, err := DialOption(config)
+		require.NoError(t, err)
+		conn, err := grpc.Dial(lis.Addr().String(), creds)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		return err
+	}
+
+	t.Run("client certificate", func(t *testing.T) {
+		require.NoError(t, check(clientConfig))
+	})
+	t.Run("no client certificate", func(t *testing.T) {
+		require.Error(t, check(Config{CAFile: caFile}))
+	})
+	t.Run("insecure", func(t *testing.T) {
+		require.Error(t, check(Config{}))
+	})
+}
+
+func TestServerOptionsDisabled(t *testing.T) {
+	opts, err := ServerOptions(Config{})
+	require.NoError(t, err)
+	require.Empty(t, opts)
+
+	_, err = ServerOptions(Config{CAFile: "ca.crt"})
+	require.Error(t, err)
+}
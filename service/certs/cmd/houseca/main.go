@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/rmrobinson/house/service/certs"
+)
+
+const (
+	caCertName = "ca.crt"
+	caKeyName  = "ca.key"
+)
+
+// houseca is a small certificate authority for securing the house without any external PKI.
+//
+//	houseca init -dir ~/.config/house/ca
+//	houseca issue -dir ~/.config/house/ca -name roku -hosts roku.local,192.168.1.20
+//	houseca issue -dir ~/.config/house/ca -name housecli -client
+func main() {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: houseca <init|issue> [flags]")
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "init":
+		initCA(logger, os.Args[2:])
+	case "issue":
+		issue(logger, os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %s; expected init or issue\n", os.Args[1])
+		os.Exit(2)
+	}
+}
+
+// initCA creates a new certificate authority in the specified directory.
+// An existing authority is never overwritten, as every certificate it issued would need to be replaced.
+func initCA(logger *zap.Logger, args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	dir := fs.String("dir", ".", "Directory to write the CA certificate and key to")
+	name := fs.String("name", "house CA", "Common name of the CA certificate")
+	fs.Parse(args)
+
+	if _, err := os.Stat(filepath.Join(*dir, caKeyName)); err == nil {
+		logger.Fatal("a CA already exists in the directory", zap.String("dir", *dir))
+	}
+	if err := os.MkdirAll(*dir, 0700); err != nil {
+		logger.Fatal("unable to create directory", zap.String("dir", *dir), zap.Error(err))
+	}
+
+	ca, err := certs.NewCA(*name)
+	if err != nil {
+		logger.Fatal("unable to create CA", zap.Error(err))
+	}
+	keyPEM, err := ca.KeyPEM()
+	if err != nil {
+		logger.Fatal("unable to encode CA key", zap.Error(err))
+	}
+
+	writeFile(logger, filepath.Join(*dir, caKeyName), keyPEM, 0600)
+	writeFile(logger, filepath.Join(*dir, caCertName), ca.CertPEM(), 0644)
+}
+
+// issue creates a certificate signed by the CA in the specified directory.
+func issue(logger *zap.Logger, args []string) {
+	fs := flag.NewFlagSet("issue", flag.ExitOnError)
+	dir := fs.String("dir", ".", "Directory containing the CA certificate and key")
+	name := fs.String("name", "", "Common name of the certificate, also used to name the files written")
+	hosts := fs.String("hosts", "", "Comma separated DNS names and IP addresses the server is reachable at")
+	client := fs.Bool("client", false, "Issue a client certificate instead of a server certificate")
+	outDir := fs.String("out", "", "Directory to write the certificate and key to; defaults to the CA directory")
+	fs.Parse(args)
+
+	if len(*name) < 1 {
+		logger.Fatal("name must be set")
+	}
+	if len(*outDir) < 1 {
+		outDir = dir
+	}
+
+	certPEM, err := os.ReadFile(filepath.Join(*dir, caCertName))
+	if err != nil {
+		logger.Fatal("unable to read CA certificate", zap.Error(err))
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(*dir, caKeyName))
+	if err != nil {
+		logger.Fatal("unable to read CA key", zap.Error(err))
+	}
+	ca, err := certs.LoadCA(certPEM, keyPEM)
+	if err != nil {
+		logger.Fatal("unable to load CA", zap.Error(err))
+	}
+
+	usage := certs.ServerUsage
+	if *client {
+		usage = certs.ClientUsage
+	}
+	var hostList []string
+	for _, host := range strings.Split(*hosts, ",") {
+		if host = strings.TrimSpace(host); len(host) > 0 {
+			hostList = append(hostList, host)
+		}
+	}
+	if usage == certs.ServerUsage && len(hostList) < 1 {
+		logger.Fatal("server certificates require at least one host")
+	}
+
+	issuedCert, issuedKey, err := ca.Issue(*name, hostList, usage)
+	if err != nil {
+		logger.Fatal("unable to issue certificate", zap.Error(err))
+	}
+
+	if err := os.MkdirAll(*outDir, 0700); err != nil {
+		logger.Fatal("unable to create directory", zap.String("dir", *outDir), zap.Error(err))
+	}
+	writeFile(logger, filepath.Join(*outDir, *name+".key"), issuedKey, 0600)
+	writeFile(logger, filepath.Join(*outDir, *name+".crt"), issuedCert, 0644)
+}
+
+func writeFile(logger *zap.Logger, path string, data []byte, perm os.FileMode) {
+	if err := os.WriteFile(path, data, perm); err != nil {
+		logger.Fatal("unable to write file", zap.String("path", path), zap.Error(err))
+	}
+	logger.Info("wrote file", zap.String("path", path))
+}
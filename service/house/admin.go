@@ -0,0 +1,124 @@
+package house
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/service/house/db"
+)
+
+const (
+	backupChunkSize    = 64 * 1024
+	backupFilePrefix   = "house-"
+	backupFileSuffix   = ".db"
+	backupFileTimeSpec = "20060102T150405Z"
+)
+
+// AdminService implements the operations used to maintain the house service.
+type AdminService struct {
+	logger *zap.Logger
+
+	db db.Store
+}
+
+// NewAdminService creates a new admin service using the supplied database.
+func NewAdminService(logger *zap.Logger, db db.Store) *AdminService {
+	return &AdminService{
+		logger: logger,
+		db:     db,
+	}
+}
+
+func (s *AdminService) Backup(req *api2.BackupRequest, stream api2.HouseAdminService_BackupServer) error {
+	snapshot, err := os.CreateTemp("", "house-backup-*.db")
+	if err != nil {
+		s.logger.Error("unable to create snapshot file", zap.Error(err))
+		return status.Error(codes.Internal, "unable to create snapshot")
+	}
+	snapshot.Close()
+	defer os.Remove(snapshot.Name())
+
+	if err := s.db.Backup(stream.Context(), snapshot.Name()); errors.Is(err, db.ErrNotSupported) {
+		return status.Error(codes.Unimplemented, "backups aren't supported by this database; use its own tools instead")
+	} else if err != nil {
+		return status.Error(codes.Internal, "unable to create snapshot")
+	}
+
+	f, err := os.Open(snapshot.Name())
+	if err != nil {
+		s.logger.Error("unable to open snapshot file", zap.String("path", snapshot.Name()), zap.Error(err))
+		return status.Error(codes.Internal, "unable to read snapshot")
+	}
+	defer f.Close()
+
+	buf := make([]byte, backupChunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&api2.BackupChunk{Data: buf[:n]}); err != nil {
+				s.logger.Info("unable to send snapshot chunk", zap.Error(err))
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			s.logger.Error("unable to read snapshot file", zap.String("path", snapshot.Name()), zap.Error(err))
+			return status.Error(codes.Internal, "unable to read snapshot")
+		}
+	}
+}
+
+// RunScheduledBackups writes a snapshot into the specified directory every interval until the context is cancelled.
+// Only the newest keep snapshots are retained.
+func (s *AdminService) RunScheduledBackups(ctx context.Context, dir string, interval time.Duration, keep int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			path := filepath.Join(dir, fmt.Sprintf("%s%s%s", backupFilePrefix, now.UTC().Format(backupFileTimeSpec), backupFileSuffix))
+			if err := s.db.Backup(ctx, path); err != nil {
+				os.Remove(path)
+				continue
+			}
+			s.logger.Info("scheduled backup written", zap.String("path", path))
+
+			if err := rotateBackups(dir, keep); err != nil {
+				s.logger.Error("unable to rotate backups", zap.String("dir", dir), zap.Error(err))
+			}
+		}
+	}
+}
+
+// rotateBackups removes all but the newest keep snapshots in the directory.
+// The file names sort by the time they were taken so the oldest are removed first.
+func rotateBackups(dir string, keep int) error {
+	paths, err := filepath.Glob(filepath.Join(dir, backupFilePrefix+"*"+backupFileSuffix))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	for len(paths) > keep {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+package house
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionalphapb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/service/auth"
+	"github.com/rmrobinson/house/service/house/db"
+)
+
+// houseMethodAccess lists the access needed to call each method of the house. Methods which aren't listed,
+// including CheckAccess which is handled separately, require admin access.
+var houseMethodAccess = map[string]auth.Access{
+	api2.HouseService_ListBuildings_FullMethodName: auth.AccessRead,
+	api2.HouseService_GetBuilding_FullMethodName:   auth.AccessRead,
+	api2.HouseService_SearchDevices_FullMethodName: auth.AccessRead,
+	api2.HouseService_ListRooms_FullMethodName:     auth.AccessRead,
+	api2.HouseService_GetRoom_FullMethodName:       auth.AccessRead,
+	api2.HouseService_ListZones_FullMethodName:     auth.AccessRead,
+	api2.HouseService_GetZone_FullMethodName:       auth.AccessRead,
+	api2.HouseService_ExportLayout_FullMethodName:  auth.AccessRead,
+
+	api2.HouseBridgeService_ListLogicalDevices_FullMethodName: auth.AccessRead,
+	api2.HouseBridgeService_ListDeviceLinks_FullMethodName:    auth.AccessRead,
+
+	reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName:      auth.AccessRead,
+	reflectionalphapb.ServerReflection_ServerReflectionInfo_FullMethodName: auth.AccessRead,
+}
+
+// errInvalidToken is returned when a token isn't known or has expired.
+var errInvalidToken = status.Error(codes.Unauthenticated, "token is invalid or has expired")
+
+var roleAPIToDB = map[api2.Principal_Role]db.Role{
+	api2.Principal_ROLE_READ_ONLY: db.RoleReadOnly,
+	api2.Principal_ROLE_CONTROL:   db.RoleControl,
+	api2.Principal_ROLE_ADMIN:     db.RoleAdmin,
+	api2.Principal_ROLE_BRIDGE:    db.RoleBridge,
+}
+
+var accessAPIToAuth = map[api2.CheckAccessRequest_Access]auth.Access{
+	api2.CheckAccessRequest_ACCESS_READ:    auth.AccessRead,
+	api2.CheckAccessRequest_ACCESS_CONTROL: auth.AccessControl,
+	api2.CheckAccessRequest_ACCESS_ADMIN:   auth.AccessAdmin,
+}
+
+// Policy checks callers against the principals stored in the house database.
+// It is used to authorize calls made to the house, and the calls to bridges which are checked with CheckAccess.
+type Policy struct {
+	logger *zap.Logger
+
+	db db.Store
+	// adminSubject is granted admin access regardless of the stored principals, so the policy can be bootstrapped.
+	adminSubject string
+}
+
+// NewPolicy creates a new policy. If adminSubject is set, the client certificate with that subject is always
+// granted admin access.
+func NewPolicy(logger *zap.Logger, db db.Store, adminSubject string) *Policy {
+	return &Policy{
+		logger:       logger,
+		db:           db,
+		adminSubject: adminSubject,
+	}
+}
+
+// Authorize checks the caller of a house method; it satisfies the auth.Authorizer interface.
+func (p *Policy) Authorize(ctx context.Context, id auth.Identity, method string, req any) error {
+	if id.Empty() {
+		return auth.ErrUnauthenticated
+	}
+
+	principal, err := p.principal(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if method == api2.HouseAuthService_CheckAccess_FullMethodName {
+		if principal.Role != db.RoleBridge && principal.Role != db.RoleAdmin {
+			return auth.ErrPermissionDenied
+		}
+		return nil
+	}
+
+	access, ok := houseMethodAccess[method]
+	if !ok {
+		access = auth.AccessAdmin
+	}
+	return p.check(ctx, principal, access, "", "")
+}
+
+// principal retrieves the principal identified by the supplied identity.
+// Unknown or expired tokens are rejected as unauthenticated; certificates, which have already been verified, are
+// denied permission instead.
+func (p *Policy) principal(ctx context.Context, id auth.Identity) (*db.Principal, error) {
+	var principal *db.Principal
+	var err error
+	if len(id.Token) > 0 {
+		principal, err = p.db.GetPrincipalByTokenHash(ctx, hashToken(id.Token))
+	} else if len(p.adminSubject) > 0 && id.Subject == p.adminSubject {
+		return &db.Principal{Subject: id.Subject, Role: db.RoleAdmin}, nil
+	} else if len(id.Subject) > 0 {
+		principal, err = p.db.GetPrincipalBySubject(ctx, id.Subject)
+	}
+
+	if err != nil {
+		p.logger.Error("unable to get principal", zap.String("subject", id.Subject), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get principal")
+	} else if principal == nil || (!principal.ExpiresAt.IsZero() && time.Now().After(principal.ExpiresAt)) {
+		if len(id.Token) > 0 {
+			return nil, errInvalidToken
+		}
+		return nil, auth.ErrPermissionDenied
+	}
+	return principal, nil
+}
+
+// check returns ErrPermissionDenied unless the principal has the requested access.
+// Principals with the control role are also limited by their rooms and device types when controlling a device.
+func (p *Policy) check(ctx context.Context, principal *db.Principal, access auth.Access, deviceID string, deviceType string) error {
+	switch access {
+	case auth.AccessRead:
+		if principal.Role == db.RoleUnspecified {
+			return auth.ErrPermissionDenied
+		}
+		return nil
+	case auth.AccessAdmin:
+		if principal.Role != db.RoleAdmin {
+			return auth.ErrPermissionDenied
+		}
+		return nil
+	case auth.AccessControl:
+		if principal.Role == db.RoleAdmin {
+			return nil
+		} else if principal.Role != db.RoleControl {
+			return auth.ErrPermissionDenied
+		}
+	default:
+		return auth.ErrPermissionDenied
+	}
+
+	if len(principal.DeviceTypes) > 0 && !slices.Contains(principal.DeviceTypes, deviceType) {
+		return auth.ErrPermissionDenied
+	}
+	if len(principal.RoomIDs) > 0 {
+		if len(deviceID) < 1 {
+			return auth.ErrPermissionDenied
+		}
+
+		device, err := p.db.GetDevice(ctx, deviceID)
+		if err != nil {
+			p.logger.Error("unable to get device", zap.String("device_id", deviceID), zap.Error(err))
+			return status.Error(codes.Internal, "unable to get device")
+		} else if device == nil || !slices.Contains(principal.RoomIDs, device.RoomID) {
+			return auth.ErrPermissionDenied
+		}
+	}
+	return nil
+}
+
+// AuthService manages the principals of the house, and checks the access of callers on behalf of bridges.
+type AuthService struct {
+	logger *zap.Logger
+
+	db     db.Store
+	policy *Policy
+}
+
+// NewAuthService creates a new auth service which uses the supplied policy to answer CheckAccess.
+func NewAuthService(logger *zap.Logger, db db.Store, policy *Policy) *AuthService {
+	return &AuthService{
+		logger: logger,
+		db:     db,
+		policy: policy,
+	}
+}
+
+func (s *AuthService) ListPrincipals(ctx context.Context, req *api2.ListPrincipalsRequest) (*api2.ListPrincipalsResponse, error) {
+	principals, err := s.db.GetPrincipals(ctx)
+	if err != nil {
+		s.logger.Error("unable to get principals", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get principals")
+	}
+
+	ret := &api2.ListPrincipalsResponse{}
+	for _, principal := range principals {
+		ret.Principals = append(ret.Principals, principalDBToAPI(principal))
+	}
+	return ret, nil
+}
+
+func (s *AuthService) GetPrincipal(ctx context.Context, req *api2.GetPrincipalRequest) (*api2.Principal, error) {
+	principal, err := s.db.GetPrincipal(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("unable to get principal", zap.String("principal_id", req.Id), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get principal")
+	} else if principal == nil {
+		return nil, status.Error(codes.NotFound, "principal doesn't exist")
+	}
+	return principalDBToAPI(*principal), nil
+}
+
+func (s *AuthService) CreatePrincipal(ctx context.Context, req *api2.CreatePrincipalRequest) (*api2.Principal, error) {
+	if len(req.Subject) < 1 {
+		return nil, status.Error(codes.InvalidArgument, "subject is required")
+	}
+	principal, err := s.principalConfigToDB(ctx, req.Config)
+	if err != nil {
+		return nil, err
+	}
+	principal.Subject = req.Subject
+
+	principal, err = s.db.CreatePrincipal(ctx, principal)
+	if err != nil {
+		s.logger.Error("unable to create principal", zap.String("subject", req.Subject), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to create principal")
+	}
+	return principalDBToAPI(*principal), nil
+}
+
+func (s *AuthService) UpdatePrincipal(ctx context.Context, req *api2.UpdatePrincipalRequest) (*api2.Principal, error) {
+	principal, err := s.principalConfigToDB(ctx, req.Config)
+	if err != nil {
+		return nil, err
+	}
+	principal.ID = req.Id
+
+	principal, err = s.db.UpdatePrincipal(ctx, principal)
+	if err != nil {
+		s.logger.Error("unable to update principal", zap.String("principal_id", req.Id), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to update principal")
+	}
+	return principalDBToAPI(*principal), nil
+}
+
+func (s *AuthService) DeletePrincipal(ctx context.Context, req *api2.DeletePrincipalRequest) (*emptypb.Empty, error) {
+	if err := s.db.DeletePrincipal(ctx, req.Id); err != nil {
+		s.logger.Error("unable to delete principal", zap.String("principal_id", req.Id), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to delete principal")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthService) CreateToken(ctx context.Context, req *api2.CreateTokenRequest) (*api2.CreateTokenResponse, error) {
+	principal, err := s.principalConfigToDB(ctx, req.Config)
+	if err != nil {
+		return nil, err
+	}
+	if req.Ttl != nil {
+		if err := req.Ttl.CheckValid(); err != nil || req.Ttl.AsDuration() <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "ttl must be positive")
+		}
+		principal.ExpiresAt = time.Now().Add(req.Ttl.AsDuration())
+	}
+
+	token, err := newToken()
+	if err != nil {
+		s.logger.Error("unable to generate token", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to generate token")
+	}
+	principal.TokenHash = hashToken(token)
+
+	principal, err = s.db.CreatePrincipal(ctx, principal)
+	if err != nil {
+		s.logger.Error("unable to create token principal", zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to create token")
+	}
+	return &api2.CreateTokenResponse{
+		Principal: principalDBToAPI(*principal),
+		Token:     token,
+	}, nil
+}
+
+func (s *AuthService) CheckAccess(ctx context.Context, req *api2.CheckAccessRequest) (*api2.CheckAccessResponse, error) {
+	access, ok := accessAPIToAuth[req.Access]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "access is required")
+	}
+
+	principal, err := s.policy.principal(ctx, auth.Identity{Subject: req.GetSubject(), Token: req.GetToken()})
+	if err == nil {
+		err = s.policy.check(ctx, principal, access, req.DeviceId, req.DeviceType)
+	}
+
+	switch status.Code(err) {
+	case codes.OK:
+		return &api2.CheckAccessResponse{Result: api2.CheckAccessResponse_RESULT_ALLOWED, PrincipalId: principal.ID}, nil
+	case codes.PermissionDenied:
+		return &api2.CheckAccessResponse{Result: api2.CheckAccessResponse_RESULT_DENIED}, nil
+	case codes.Unauthenticated:
+		return &api2.CheckAccessResponse{Result: api2.CheckAccessResponse_RESULT_UNAUTHENTICATED}, nil
+	}
+	return nil, err
+}
+
+// principalConfigToDB validates the supplied config and converts it, dropping any duplicated rooms or device types.
+func (s *AuthService) principalConfigToDB(ctx context.Context, config *api2.Principal_Config) (*db.Principal, error) {
+	if config == nil {
+		return nil, status.Error(codes.InvalidArgument, "config is required")
+	}
+	role, ok := roleAPIToDB[config.Role]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "role is required")
+	}
+
+	principal := &db.Principal{
+		Description: config.Description,
+		Role:        role,
+	}
+	if config.ExpiresAt != nil {
+		if err := config.ExpiresAt.CheckValid(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "expiry time is invalid")
+		}
+		principal.ExpiresAt = config.ExpiresAt.AsTime()
+	}
+
+	for _, roomID := range config.RoomIds {
+		if slices.Contains(principal.RoomIDs, roomID) {
+			continue
+		}
+		room, err := s.db.GetRoom(ctx, roomID)
+		if err != nil {
+			s.logger.Error("unable to get room", zap.String("room_id", roomID), zap.Error(err))
+			return nil, status.Error(codes.Internal, "unable to get room")
+		} else if room == nil {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("room %s doesn't exist", roomID))
+		}
+		principal.RoomIDs = append(principal.RoomIDs, roomID)
+	}
+	for _, deviceType := range config.DeviceTypes {
+		deviceType = strings.ToLower(strings.TrimSpace(deviceType))
+		if !auth.IsDeviceType(deviceType) {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("%s isn't a device type", deviceType))
+		} else if !slices.Contains(principal.DeviceTypes, deviceType) {
+			principal.DeviceTypes = append(principal.DeviceTypes, deviceType)
+		}
+	}
+	return principal, nil
+}
+
+func principalDBToAPI(principal db.Principal) *api2.Principal {
+	ret := &api2.Principal{
+		Id:      principal.ID,
+		Subject: principal.Subject,
+		Config: &api2.Principal_Config{
+			Description: principal.Description,
+			RoomIds:     principal.RoomIDs,
+			DeviceTypes: principal.DeviceTypes,
+		},
+	}
+	for apiRole, dbRole := range roleAPIToDB {
+		if dbRole == principal.Role {
+			ret.Config.Role = apiRole
+		}
+	}
+	if !principal.ExpiresAt.IsZero() {
+		ret.Config.ExpiresAt = timestamppb.New(principal.ExpiresAt)
+	}
+	return ret
+}
+
+// newToken generates a random token which is safe to use in request metadata.
+func newToken() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// hashToken returns the hash of the supplied token which is stored in place of the token itself.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,241 @@
+package house
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/service/auth"
+	"github.com/rmrobinson/house/service/house/db"
+	"github.com/rmrobinson/house/service/tracing"
+)
+
+// BridgeService pairs bridges with the house, and keeps the credentials exchanged with them.
+// During pairing the house issues the bridge a token for a principal with the bridge role, and the bridge issues the
+// house a token which it presents on every later call to the bridge.
+type BridgeService struct {
+	logger *zap.Logger
+
+	db db.Store
+	// dialOpt secures the connections made to bridges. Pairing requires TLS, so it should supply transport credentials.
+	dialOpt grpc.DialOption
+}
+
+// NewBridgeService creates a new bridge service which connects to bridges using the supplied dial option.
+func NewBridgeService(logger *zap.Logger, db db.Store, dialOpt grpc.DialOption) *BridgeService {
+	return &BridgeService{
+		logger:  logger,
+		db:      db,
+		dialOpt: dialOpt,
+	}
+}
+
+func (s *BridgeService) ListBridges(ctx context.Context, req *api2.ListBridgesRequest) (*api2.ListBridgesResponse, error) {
+	bridges, err := s.db.GetBridges(ctx)
+	if err != nil {
+		s.logger.Error("unable to get bridges", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get bridges")
+	}
+
+	ret := &api2.ListBridgesResponse{}
+	for _, bridge := range bridges {
+		ret.Bridges = append(ret.Bridges, pairedBridgeDBToAPI(bridge))
+	}
+	return ret, nil
+}
+
+func (s *BridgeService) StartBridgePairing(ctx context.Context, req *api2.StartBridgePairingRequest) (*api2.StartBridgePairingResponse, error) {
+	if len(req.Address) < 1 {
+		return nil, status.Error(codes.InvalidArgument, "address is required")
+	}
+
+	conn, err := s.dial(req.Address)
+	if err != nil {
+		s.logger.Error("unable to connect to bridge", zap.String("address", req.Address), zap.Error(err))
+		return nil, status.Error(codes.Unavailable, "unable to connect to bridge")
+	}
+	defer conn.Close()
+	client := api2.NewBridgeServiceClient(conn)
+
+	b, err := client.GetBridge(ctx, &api2.GetBridgeRequest{})
+	if err != nil {
+		s.logger.Error("unable to get bridge", zap.String("address", req.Address), zap.Error(err))
+		return nil, bridgeErrorToStatus(err, "unable to get bridge")
+	}
+	existing, err := s.getPairedBridge(ctx, b.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.StartPairing(ctx, &api2.StartPairingRequest{}, bridgeCallOptions(existing)...)
+	if err != nil {
+		s.logger.Error("unable to start pairing", zap.String("bridge_id", b.Id), zap.Error(err))
+		return nil, bridgeErrorToStatus(err, "unable to start pairing")
+	}
+
+	return &api2.StartBridgePairingResponse{
+		Bridge:    b,
+		ExpiresIn: resp.ExpiresIn,
+	}, nil
+}
+
+func (s *BridgeService) PairBridge(ctx context.Context, req *api2.PairBridgeRequest) (*api2.PairedBridge, error) {
+	if len(req.Address) < 1 {
+		return nil, status.Error(codes.InvalidArgument, "address is required")
+	} else if len(req.Code) < 1 {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	conn, err := s.dial(req.Address)
+	if err != nil {
+		s.logger.Error("unable to connect to bridge", zap.String("address", req.Address), zap.Error(err))
+		return nil, status.Error(codes.Unavailable, "unable to connect to bridge")
+	}
+	defer conn.Close()
+	client := api2.NewBridgeServiceClient(conn)
+
+	b, err := client.GetBridge(ctx, &api2.GetBridgeRequest{})
+	if err != nil {
+		s.logger.Error("unable to get bridge", zap.String("address", req.Address), zap.Error(err))
+		return nil, bridgeErrorToStatus(err, "unable to get bridge")
+	}
+	existing, err := s.getPairedBridge(ctx, b.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	houseToken, err := newToken()
+	if err != nil {
+		s.logger.Error("unable to generate token", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to generate token")
+	}
+	principal, err := s.db.CreatePrincipal(ctx, &db.Principal{
+		TokenHash:   hashToken(houseToken),
+		Description: fmt.Sprintf("bridge %s (%s)", b.GetConfig().GetName(), b.Id),
+		Role:        db.RoleBridge,
+	})
+	if err != nil {
+		s.logger.Error("unable to create bridge principal", zap.String("bridge_id", b.Id), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to create bridge principal")
+	}
+
+	resp, err := client.Pair(ctx, &api2.PairRequest{Code: req.Code, HouseToken: houseToken}, bridgeCallOptions(existing)...)
+	if err != nil {
+		s.logger.Info("unable to pair bridge", zap.String("bridge_id", b.Id), zap.Error(err))
+		s.deletePrincipal(ctx, principal.ID)
+		return nil, bridgeErrorToStatus(err, "unable to pair bridge")
+	}
+
+	bridge := &db.Bridge{
+		ID:          b.Id,
+		Address:     req.Address,
+		Name:        resp.Bridge.GetConfig().GetName(),
+		Token:       resp.Token,
+		PrincipalID: principal.ID,
+		PairedAt:    time.Now(),
+	}
+	if _, err := s.db.SaveBridge(ctx, bridge); err != nil {
+		s.logger.Error("bridge paired but unable to save it; the bridge must be unpaired locally", zap.String("bridge_id", b.Id), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to save bridge")
+	}
+	if existing != nil && len(existing.PrincipalID) > 0 {
+		s.deletePrincipal(ctx, existing.PrincipalID)
+	}
+
+	s.logger.Info("bridge paired", zap.String("bridge_id", b.Id), zap.String("address", req.Address))
+	return pairedBridgeDBToAPI(*bridge), nil
+}
+
+func (s *BridgeService) UnpairBridge(ctx context.Context, req *api2.UnpairBridgeRequest) (*emptypb.Empty, error) {
+	bridge, err := s.getPairedBridge(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	} else if bridge == nil {
+		return nil, status.Error(codes.NotFound, "bridge isn't paired")
+	}
+
+	conn, err := s.dial(bridge.Address)
+	if err == nil {
+		_, err = api2.NewBridgeServiceClient(conn).Unpair(ctx, &api2.UnpairRequest{}, bridgeCallOptions(bridge)...)
+		conn.Close()
+	}
+	if err != nil && !req.Force {
+		s.logger.Error("unable to unpair bridge", zap.String("bridge_id", bridge.ID), zap.Error(err))
+		return nil, bridgeErrorToStatus(err, "unable to unpair bridge")
+	} else if err != nil {
+		s.logger.Warn("forgetting bridge which couldn't be unpaired", zap.String("bridge_id", bridge.ID), zap.Error(err))
+	}
+
+	if err := s.db.DeleteBridge(ctx, bridge.ID); err != nil {
+		s.logger.Error("unable to delete bridge", zap.String("bridge_id", bridge.ID), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to delete bridge")
+	}
+	if len(bridge.PrincipalID) > 0 {
+		s.deletePrincipal(ctx, bridge.PrincipalID)
+	}
+
+	s.logger.Info("bridge unpaired", zap.String("bridge_id", bridge.ID))
+	return &emptypb.Empty{}, nil
+}
+
+// dial connects to the bridge at the supplied address, continuing the trace of the current request.
+func (s *BridgeService) dial(address string) (*grpc.ClientConn, error) {
+	return grpc.Dial(address, s.dialOpt, tracing.DialOption())
+}
+
+// getPairedBridge retrieves the specified bridge, returning nil if it hasn't been paired.
+func (s *BridgeService) getPairedBridge(ctx context.Context, bridgeID string) (*db.Bridge, error) {
+	bridge, err := s.db.GetBridge(ctx, bridgeID)
+	if err != nil {
+		s.logger.Error("unable to get bridge", zap.String("bridge_id", bridgeID), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get bridge")
+	}
+	return bridge, nil
+}
+
+// deletePrincipal removes a principal created for a bridge. Failures are logged, as the principal may already have
+// been removed by an administrator.
+func (s *BridgeService) deletePrincipal(ctx context.Context, principalID string) {
+	if err := s.db.DeletePrincipal(ctx, principalID); err != nil && !errors.Is(err, db.ErrNotFound) {
+		s.logger.Error("unable to delete bridge principal", zap.String("principal_id", principalID), zap.Error(err))
+	}
+}
+
+// bridgeCallOptions presents the token issued by the supplied bridge, if it has been paired.
+func bridgeCallOptions(bridge *db.Bridge) []grpc.CallOption {
+	if bridge == nil {
+		return nil
+	}
+	return []grpc.CallOption{grpc.PerRPCCredentials(auth.TokenCredentials(bridge.Token))}
+}
+
+// bridgeErrorToStatus passes on the errors from a bridge which the caller can act on, such as an incorrect pairing code.
+// Other errors are returned as unavailable.
+func bridgeErrorToStatus(err error, msg string) error {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.PermissionDenied, codes.Unauthenticated, codes.ResourceExhausted:
+		return err
+	}
+	return status.Error(codes.Unavailable, msg)
+}
+
+func pairedBridgeDBToAPI(bridge db.Bridge) *api2.PairedBridge {
+	ret := &api2.PairedBridge{
+		Id:      bridge.ID,
+		Address: bridge.Address,
+		Name:    bridge.Name,
+	}
+	if !bridge.PairedAt.IsZero() {
+		ret.PairedAt = timestamppb.New(bridge.PairedAt)
+	}
+	return ret
+}
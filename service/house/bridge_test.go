@@ -0,0 +1,232 @@
+package house
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/service/auth"
+	"github.com/rmrobinson/house/service/certs"
+	"github.com/rmrobinson/house/service/house/db"
+)
+
+// fakeBridge pairs like a bridge: once paired, it only accepts pairing and unpairing from the house it is paired with.
+type fakeBridge struct {
+	api2.UnimplementedBridgeServiceServer
+
+	lock sync.Mutex
+	// pairErr is returned instead of pairing, i.e. for an incorrect code.
+	pairErr error
+	// token is the token issued to the paired house; the bridge is unpaired if it is empty.
+	token      string
+	houseToken string
+	issued     int
+}
+
+func (b *fakeBridge) GetBridge(ctx context.Context, req *api2.GetBridgeRequest) (*api2.Bridge, error) {
+	return &api2.Bridge{Id: "bridge1", Config: &api2.Bridge_Config{Name: "Roku"}}, nil
+}
+
+func (b *fakeBridge) checkHouse(ctx context.Context) error {
+	if len(b.token) > 0 && auth.IdentityFromContext(ctx).Token != b.token {
+		return status.Error(codes.PermissionDenied, "only the paired house may pair or unpair the bridge")
+	}
+	return nil
+}
+
+func (b *fakeBridge) StartPairing(ctx context.Context, req *api2.StartPairingRequest) (*api2.StartPairingResponse, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if err := b.checkHouse(ctx); err != nil {
+		return nil, err
+	}
+	return &api2.StartPairingResponse{}, nil
+}
+
+func (b *fakeBridge) Pair(ctx context.Context, req *api2.PairRequest) (*api2.PairResponse, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if err := b.checkHouse(ctx); err != nil {
+		return nil, err
+	} else if b.pairErr != nil {
+		return nil, b.pairErr
+	}
+
+	b.issued++
+	b.token = fmt.Sprintf("bridge-token-%d", b.issued)
+	b.houseToken = req.HouseToken
+	return &api2.PairResponse{Token: b.token, Bridge: &api2.Bridge{Id: "bridge1", Config: &api2.Bridge_Config{Name: "Roku"}}}, nil
+}
+
+func (b *fakeBridge) Unpair(ctx context.Context, req *api2.UnpairRequest) (*emptypb.Empty, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if err := b.checkHouse(ctx); err != nil {
+		return nil, err
+	}
+	b.token = ""
+	b.houseToken = ""
+	return &emptypb.Empty{}, nil
+}
+
+// newTestBridgeService serves the fake bridge over TLS, as pairing requires, and returns a bridge service which
+// trusts it along with the address of the bridge.
+func newTestBridgeService(t *testing.T, bridge *fakeBridge) (*BridgeService, db.Store, *grpc.Server, string) {
+	ca, dialOpt := newTestCA(t)
+	grpcServer, addr := serveTestBridge(t, ca, bridge)
+
+	store := newTestDatabase(t)
+	return NewBridgeService(zaptest.NewLogger(t), store, dialOpt), store, grpcServer, addr
+}
+
+// newTestCA creates a CA for the bridges to be issued certificates by, returning a dial option which trusts it.
+func newTestCA(t *testing.T) (*certs.CA, grpc.DialOption) {
+	ca, err := certs.NewCA("test CA")
+	require.NoError(t, err)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, ca.CertPEM(), 0644))
+
+	dialOpt, err := certs.DialOption(certs.Config{CAFile: caFile})
+	require.NoError(t, err)
+	return ca, dialOpt
+}
+
+// serveTestBridge serves the bridge over TLS with a certificate issued by the CA until the test ends, returning the
+// server and its address.
+func serveTestBridge(t *testing.T, ca *certs.CA, bridge api2.BridgeServiceServer) (*grpc.Server, string) {
+	dir := t.TempDir()
+	certPEM, keyPEM, err := ca.Issue("bridge", []string{"127.0.0.1"}, certs.ServerUsage)
+	require.NoError(t, err)
+	serverConfig := certs.Config{CertFile: filepath.Join(dir, "bridge.crt"), KeyFile: filepath.Join(dir, "bridge.key")}
+	require.NoError(t, os.WriteFile(serverConfig.CertFile, certPEM, 0644))
+	require.NoError(t, os.WriteFile(serverConfig.KeyFile, keyPEM, 0600))
+
+	opts, err := certs.ServerOptions(serverConfig)
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer(opts...)
+	api2.RegisterBridgeServiceServer(grpcServer, bridge)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+	return grpcServer, lis.Addr().String()
+}
+
+func TestPairBridge(t *testing.T) {
+	ctx := context.Background()
+	bridge := &fakeBridge{pairErr: status.Error(codes.InvalidArgument, "pairing code is invalid or has expired")}
+	svc, store, _, addr := newTestBridgeService(t, bridge)
+
+	// The principal created for the bridge is removed if the bridge doesn't pair.
+	_, err := svc.PairBridge(ctx, &api2.PairBridgeRequest{Address: addr, Code: "0000"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	principals, err := store.GetPrincipals(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, principals)
+	paired, err := store.GetBridge(ctx, "bridge1")
+	require.NoError(t, err)
+	assert.Nil(t, paired)
+
+	bridge.pairErr = nil
+	res, err := svc.PairBridge(ctx, &api2.PairBridgeRequest{Address: addr, Code: "1234"})
+	require.NoError(t, err)
+	assert.Equal(t, "bridge1", res.Id)
+	assert.Equal(t, "Roku", res.Name)
+
+	paired, err = store.GetBridge(ctx, "bridge1")
+	require.NoError(t, err)
+	require.NotNil(t, paired)
+	assert.Equal(t, bridge.token, paired.Token)
+	principal, err := store.GetPrincipalByTokenHash(ctx, hashToken(bridge.houseToken))
+	require.NoError(t, err)
+	require.NotNil(t, principal)
+	assert.Equal(t, db.RoleBridge, principal.Role)
+	assert.Equal(t, principal.ID, paired.PrincipalID)
+
+	// Pairing again presents the existing token, and replaces the principal of the bridge.
+	_, err = svc.PairBridge(ctx, &api2.PairBridgeRequest{Address: addr, Code: "5678"})
+	require.NoError(t, err)
+	principals, err = store.GetPrincipals(ctx)
+	require.NoError(t, err)
+	require.Len(t, principals, 1)
+	assert.NotEqual(t, principal.ID, principals[0].ID)
+	paired, err = store.GetBridge(ctx, "bridge1")
+	require.NoError(t, err)
+	assert.Equal(t, bridge.token, paired.Token)
+	assert.Equal(t, principals[0].ID, paired.PrincipalID)
+}
+
+func TestPairBridgePairedElsewhere(t *testing.T) {
+	ctx := context.Background()
+	bridge := &fakeBridge{token: "other-house"}
+	svc, store, _, addr := newTestBridgeService(t, bridge)
+
+	_, err := svc.StartBridgePairing(ctx, &api2.StartBridgePairingRequest{Address: addr})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = svc.PairBridge(ctx, &api2.PairBridgeRequest{Address: addr, Code: "1234"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	principals, err := store.GetPrincipals(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, principals)
+	assert.Equal(t, "other-house", bridge.token)
+}
+
+func TestUnpairBridge(t *testing.T) {
+	ctx := context.Background()
+	bridge := &fakeBridge{}
+	svc, store, grpcServer, addr := newTestBridgeService(t, bridge)
+
+	_, err := svc.UnpairBridge(ctx, &api2.UnpairBridgeRequest{Id: "bridge1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = svc.PairBridge(ctx, &api2.PairBridgeRequest{Address: addr, Code: "1234"})
+	require.NoError(t, err)
+	_, err = svc.UnpairBridge(ctx, &api2.UnpairBridgeRequest{Id: "bridge1"})
+	require.NoError(t, err)
+	assert.Empty(t, bridge.token)
+
+	bridges, err := store.GetBridges(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, bridges)
+	principals, err := store.GetPrincipals(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, principals)
+
+	// A bridge which can't be reached is only forgotten if forced.
+	_, err = svc.PairBridge(ctx, &api2.PairBridgeRequest{Address: addr, Code: "1234"})
+	require.NoError(t, err)
+	grpcServer.Stop()
+
+	_, err = svc.UnpairBridge(ctx, &api2.UnpairBridgeRequest{Id: "bridge1"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	bridges, err = store.GetBridges(ctx)
+	require.NoError(t, err)
+	assert.Len(t, bridges, 1)
+
+	_, err = svc.UnpairBridge(ctx, &api2.UnpairBridgeRequest{Id: "bridge1", Force: true})
+	require.NoError(t, err)
+	bridges, err = store.GetBridges(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, bridges)
+	principals, err = store.GetPrincipals(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, principals)
+}
@@ -1 +1,164 @@
 package house
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/service/house/db"
+)
+
+// defaultTimezone is used for buildings which are created without a timezone.
+const defaultTimezone = "UTC"
+
+func (s *Service) ListBuildings(ctx context.Context, req *api2.ListBuildingsRequest) (*api2.ListBuildingsResponse, error) {
+	buildings, err := s.db.GetBuildings(ctx)
+	if err != nil {
+		s.logger.Error("unable to get buildings", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get buildings")
+	}
+
+	ret := &api2.ListBuildingsResponse{}
+	for _, building := range buildings {
+		ret.Buildings = append(ret.Buildings, buildingDBToAPI(building))
+	}
+	return ret, nil
+}
+
+func (s *Service) GetBuilding(ctx context.Context, req *api2.GetBuildingRequest) (*api2.Building, error) {
+	building, err := s.db.GetBuilding(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("unable to get building", zap.String("building_id", req.Id), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get building")
+	} else if building == nil {
+		return nil, status.Error(codes.NotFound, "building doesn't exist")
+	}
+
+	rooms, err := s.db.GetBuildingRooms(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("unable to get building rooms", zap.String("building_id", req.Id), zap.Error(err))
+	}
+
+	floors, err := s.db.GetBuildingFloors(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("unable to get building floors", zap.String("building_id", req.Id), zap.Error(err))
+	}
+
+	ret := buildingDBToAPI(*building)
+
+	floorsByID := map[string]*api2.Floor{}
+	for _, floor := range floors {
+		apiFloor := floorDBToAPI(floor)
+		floorsByID[floor.ID] = apiFloor
+		ret.Floors = append(ret.Floors, apiFloor)
+	}
+	for _, room := range rooms {
+		if floor, found := floorsByID[room.FloorID]; found {
+			floor.Rooms = append(floor.Rooms, roomDBToAPI(room))
+		} else {
+			ret.Rooms = append(ret.Rooms, roomDBToAPI(room))
+		}
+	}
+	return ret, nil
+}
+
+func (s *Service) CreateBuilding(ctx context.Context, req *api2.CreateBuildingRequest) (*api2.Building, error) {
+	if err := validateBuildingConfig(req.Config); err != nil {
+		return nil, err
+	}
+
+	building := buildingConfigToDB(req.Config)
+
+	res, err := s.db.CreateBuilding(ctx, building)
+	if err != nil {
+		s.logger.Error("unable to create building", zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to create building")
+	}
+
+	return buildingDBToAPI(*res), nil
+}
+
+func (s *Service) UpdateBuilding(ctx context.Context, req *api2.UpdateBuildingRequest) (*api2.Building, error) {
+	if err := validateBuildingConfig(req.Config); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.db.GetBuilding(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("unable to get building", zap.String("building_id", req.Id), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get building")
+	} else if existing == nil {
+		return nil, status.Error(codes.NotFound, "building doesn't exist")
+	}
+
+	building := buildingConfigToDB(req.Config)
+	building.ID = existing.ID
+
+	res, err := s.db.UpdateBuilding(ctx, building)
+	if err != nil {
+		s.logger.Error("unable to update building", zap.String("building_id", req.Id), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to update building")
+	}
+
+	return buildingDBToAPI(*res), nil
+}
+
+func (s *Service) DeleteBuilding(ctx context.Context, req *api2.DeleteBuildingRequest) (*emptypb.Empty, error) {
+	err := s.db.DeleteBuilding(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("unable to delete building", zap.String("building_id", req.Id), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to delete building")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// validateBuildingConfig confirms the supplied config has a name, a known timezone and coordinates which are in range.
+func validateBuildingConfig(config *api2.Building_Config) error {
+	if config == nil || len(config.Name) < 1 {
+		return status.Error(codes.InvalidArgument, "building name must be set")
+	}
+	if _, err := time.LoadLocation(config.Timezone); err != nil {
+		return status.Error(codes.InvalidArgument, "invalid timezone specified")
+	}
+	if config.Latitude < -90 || config.Latitude > 90 {
+		return status.Error(codes.InvalidArgument, "latitude must be between -90 and 90")
+	}
+	if config.Longitude < -180 || config.Longitude > 180 {
+		return status.Error(codes.InvalidArgument, "longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+func buildingConfigToDB(config *api2.Building_Config) *db.Building {
+	tz := config.Timezone
+	if len(tz) < 1 {
+		tz = defaultTimezone
+	}
+
+	return &db.Building{
+		Name: config.Name,
+		TZ:   tz,
+		Location: db.Location{
+			Latitude:  config.Latitude,
+			Longitude: config.Longitude,
+		},
+	}
+}
+
+func buildingDBToAPI(building db.Building) *api2.Building {
+	return &api2.Building{
+		Id: building.ID,
+		Config: &api2.Building_Config{
+			Name:      building.Name,
+			Timezone:  building.TZ,
+			Latitude:  building.Location.Latitude,
+			Longitude: building.Location.Longitude,
+		},
+	}
+}
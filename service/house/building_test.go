@@ -0,0 +1,108 @@
+package house
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+)
+
+func TestValidateBuildingConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *api2.Building_Config
+		code   codes.Code
+	}{
+		{"valid", &api2.Building_Config{Name: "Home", Timezone: "America/Toronto", Latitude: 43.65, Longitude: -79.38}, codes.OK},
+		{"default timezone", &api2.Building_Config{Name: "Home"}, codes.OK},
+		{"bounds", &api2.Building_Config{Name: "Home", Latitude: -90, Longitude: 180}, codes.OK},
+		{"missing config", nil, codes.InvalidArgument},
+		{"missing name", &api2.Building_Config{Timezone: "UTC"}, codes.InvalidArgument},
+		{"unknown timezone", &api2.Building_Config{Name: "Home", Timezone: "Mars/Olympus_Mons"}, codes.InvalidArgument},
+		{"latitude out of range", &api2.Building_Config{Name: "Home", Latitude: 90.5}, codes.InvalidArgument},
+		{"longitude out of range", &api2.Building_Config{Name: "Home", Longitude: -180.5}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.code, status.Code(validateBuildingConfig(tt.config)))
+		})
+	}
+}
+
+func TestBuildingLifecycle(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	_, err := svc.CreateBuilding(ctx, &api2.CreateBuildingRequest{Config: &api2.Building_Config{Name: "Home", Timezone: "Nowhere"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	building, err := svc.CreateBuilding(ctx, &api2.CreateBuildingRequest{Config: &api2.Building_Config{Name: "Home"}})
+	require.NoError(t, err)
+	assert.Equal(t, defaultTimezone, building.Config.Timezone)
+
+	updated, err := svc.UpdateBuilding(ctx, &api2.UpdateBuildingRequest{
+		Id:     building.Id,
+		Config: &api2.Building_Config{Name: "Cottage", Timezone: "America/Toronto", Latitude: 45.3, Longitude: -79.2},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, building.Id, updated.Id)
+
+	res, err := svc.GetBuilding(ctx, &api2.GetBuildingRequest{Id: building.Id})
+	require.NoError(t, err)
+	assert.Equal(t, "Cottage", res.Config.Name)
+	assert.Equal(t, "America/Toronto", res.Config.Timezone)
+	assert.Equal(t, 45.3, res.Config.Latitude)
+	assert.Equal(t, -79.2, res.Config.Longitude)
+
+	_, err = svc.UpdateBuilding(ctx, &api2.UpdateBuildingRequest{Id: building.Id, Config: &api2.Building_Config{Name: "Cottage", Latitude: 91}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = svc.UpdateBuilding(ctx, &api2.UpdateBuildingRequest{Id: "missing", Config: &api2.Building_Config{Name: "Cottage"}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = svc.DeleteBuilding(ctx, &api2.DeleteBuildingRequest{Id: building.Id})
+	require.NoError(t, err)
+	_, err = svc.GetBuilding(ctx, &api2.GetBuildingRequest{Id: building.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = svc.DeleteBuilding(ctx, &api2.DeleteBuildingRequest{Id: building.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestDeleteBuildingWithRooms(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	home, err := svc.CreateBuilding(ctx, &api2.CreateBuildingRequest{Config: &api2.Building_Config{Name: "Home"}})
+	require.NoError(t, err)
+	cottage, err := svc.CreateBuilding(ctx, &api2.CreateBuildingRequest{Config: &api2.Building_Config{Name: "Cottage"}})
+	require.NoError(t, err)
+	floor, err := svc.CreateFloor(ctx, &api2.CreateFloorRequest{BuildingId: home.Id, Config: &api2.Floor_Config{Name: "Ground"}})
+	require.NoError(t, err)
+	kitchen, err := svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: home.Id, Config: &api2.Room_Config{Name: "Kitchen", FloorId: floor.Id}})
+	require.NoError(t, err)
+	dock, err := svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: cottage.Id, Config: &api2.Room_Config{Name: "Dock"}})
+	require.NoError(t, err)
+	_, err = svc.LinkDevice(ctx, &api2.LinkDeviceRequest{DeviceId: "device1", RoomId: kitchen.Id})
+	require.NoError(t, err)
+
+	// Deleting a building removes its floors, its rooms and the devices linked to them, but not those of other buildings.
+	_, err = svc.DeleteBuilding(ctx, &api2.DeleteBuildingRequest{Id: home.Id})
+	require.NoError(t, err)
+
+	rooms, err := svc.ListRooms(ctx, &api2.ListRoomsRequest{BuildingId: home.Id})
+	require.NoError(t, err)
+	assert.Empty(t, rooms.Rooms)
+	_, err = svc.UnlinkDevice(ctx, &api2.UnlinkDeviceRequest{Id: "device1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = svc.LinkDevice(ctx, &api2.LinkDeviceRequest{DeviceId: "device1", RoomId: kitchen.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	rooms, err = svc.ListRooms(ctx, &api2.ListRoomsRequest{BuildingId: cottage.Id})
+	require.NoError(t, err)
+	require.Len(t, rooms.Rooms, 1)
+	assert.Equal(t, dock.Id, rooms.Rooms[0].Id)
+}
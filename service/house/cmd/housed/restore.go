@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rmrobinson/house/service/house/db"
+	"go.uber.org/zap"
+)
+
+// preRestoreTimeSpec formats the time of a restore into the name the previous database is kept under.
+const preRestoreTimeSpec = "20060102T150405Z"
+
+// sqliteSidecarSuffixes are appended to the path of a database to name the files SQLite keeps alongside it.
+var sqliteSidecarSuffixes = []string{"-journal", "-wal", "-shm"}
+
+// restore replaces the database with the supplied snapshot, once the snapshot has been verified.
+// The previous database is kept alongside it, along with its journal and write-ahead log, with a .pre-restore suffix
+// and the time of the restore, so earlier restores aren't overwritten.
+// housed must not be serving from the database while it is restored.
+func restore(logger *zap.Logger, args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dbPath := fs.String("db", "", "Path to the database to replace")
+	snapshotPath := fs.String("snapshot", "", "Path to the snapshot to restore")
+	fs.Parse(args)
+
+	if len(*dbPath) < 1 || len(*snapshotPath) < 1 {
+		logger.Fatal("both the database and snapshot paths must be set")
+	}
+
+	version, err := db.VerifySnapshot(context.Background(), *snapshotPath)
+	if err != nil {
+		logger.Fatal("snapshot can't be restored", zap.String("snapshot", *snapshotPath), zap.Uint("version", version), zap.Error(err))
+	}
+
+	// Copy the snapshot next to the database first so the final rename is atomic.
+	tmpPath := *dbPath + ".restore"
+	if err := copyFile(*snapshotPath, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		logger.Fatal("unable to copy snapshot", zap.String("path", tmpPath), zap.Error(err))
+	}
+
+	if _, err := os.Stat(*dbPath); err == nil {
+		prevPath := *dbPath + ".pre-restore-" + time.Now().UTC().Format(preRestoreTimeSpec)
+		if _, err := os.Stat(prevPath); err == nil {
+			os.Remove(tmpPath)
+			logger.Fatal("previous database already exists; wait a second and retry", zap.String("path", prevPath))
+		}
+		if err := os.Rename(*dbPath, prevPath); err != nil {
+			os.Remove(tmpPath)
+			logger.Fatal("unable to move existing database aside", zap.Error(err))
+		}
+		// The journal and write-ahead log hold changes not yet in the database file, so they are kept with it.
+		for _, suffix := range sqliteSidecarSuffixes {
+			if err := os.Rename(*dbPath+suffix, prevPath+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+				os.Remove(tmpPath)
+				logger.Fatal("unable to move existing database aside", zap.String("path", *dbPath+suffix), zap.Error(err))
+			}
+		}
+		logger.Info("previous database kept", zap.String("path", prevPath))
+	}
+	for _, suffix := range sqliteSidecarSuffixes {
+		os.Remove(*dbPath + suffix)
+	}
+	if err := os.Rename(tmpPath, *dbPath); err != nil {
+		logger.Fatal("unable to move snapshot into place", zap.Error(err))
+	}
+
+	logger.Info("snapshot restored",
+		zap.String("db_path", *dbPath),
+		zap.String("snapshot", *snapshotPath),
+		zap.Uint("version", version),
+	)
+}
+
+func copyFile(src string, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// ErrUnsupportedSchema is returned when a snapshot was written by a newer version of the database,
+// or was written while a migration was only partially applied.
+var ErrUnsupportedSchema = errors.New("snapshot schema is not supported")
+
+// Backup writes a consistent copy of the database to the specified path using SQLite's online backup API.
+// Any existing file at the path is overwritten. The database remains usable while the backup runs.
+// ErrNotSupported is returned for PostgreSQL databases, which should be backed up with the PostgreSQL tools instead.
+func (db *Database) Backup(ctx context.Context, path string) error {
+	if db.db.dialect != dialectSQLite {
+		return ErrNotSupported
+	}
+
+	srcConn, err := db.db.Conn(ctx)
+	if err != nil {
+		db.logger.Error("unable to get database connection", zap.Error(err))
+		return err
+	}
+	defer srcConn.Close()
+
+	destDB, err := sql.Open("sqlite3", fmt.Sprintf("file:%s", path))
+	if err != nil {
+		db.logger.Error("unable to open backup database", zap.String("path", path), zap.Error(err))
+		return err
+	}
+	defer destDB.Close()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		db.logger.Error("unable to get backup database connection", zap.String("path", path), zap.Error(err))
+		return err
+	}
+	defer destConn.Close()
+
+	err = destConn.Raw(func(destDriverConn any) error {
+		return srcConn.Raw(func(srcDriverConn any) error {
+			dest, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("backup database isn't a sqlite3 connection")
+			}
+			src, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("database isn't a sqlite3 connection")
+			}
+
+			backup, err := dest.Backup("main", src, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		db.logger.Error("unable to back up database", zap.String("path", path), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// LatestSchemaVersion returns the version of the newest migration embedded in this package.
+func LatestSchemaVersion() (uint, error) {
+	migrations, err := iofs.New(migrationFS, "migrations")
+	if err != nil {
+		return 0, err
+	}
+	defer migrations.Close()
+
+	version, err := migrations.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := migrations.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		} else if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// VerifySnapshot checks that the database at the specified path is intact and that its schema can be used by
+// this version. Snapshots with an older schema are accepted as they are migrated when opened.
+func VerifySnapshot(ctx context.Context, path string) (uint, error) {
+	snapshot, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return 0, err
+	}
+	defer snapshot.Close()
+
+	var result string
+	if err := snapshot.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return 0, err
+	} else if result != "ok" {
+		return 0, fmt.Errorf("snapshot failed integrity check: %s", result)
+	}
+
+	var version uint
+	var dirty bool
+	if err := snapshot.QueryRowContext(ctx, "SELECT version,dirty FROM schema_migrations").Scan(&version, &dirty); err != nil {
+		return 0, fmt.Errorf("unable to read snapshot schema version: %w", err)
+	}
+
+	latest, err := LatestSchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if dirty || version > latest {
+		return version, ErrUnsupportedSchema
+	}
+	return version, nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Bridge is a bridge which has been paired with the house.
+type Bridge struct {
+	// ID is the ID reported by the bridge itself.
+	ID      string
+	Address string
+	Name    string
+
+	// Token is issued by the bridge during pairing and is presented by the house on each call to the bridge.
+	Token string
+	// PrincipalID identifies the principal created for the bridge to call the house with during pairing.
+	PrincipalID string
+
+	PairedAt time.Time
+}
+
+// SaveBridge inserts the supplied bridge, or replaces it if the bridge has been paired before.
+func (db *Database) SaveBridge(ctx context.Context, b *Bridge) (*Bridge, error) {
+	_, err := db.db.ExecContext(ctx, "INSERT INTO bridge (id, address, name, token, principal_id, paired_at) VALUES (?, ?, ?, ?, ?, ?) "+
+		"ON CONFLICT(id) DO UPDATE SET address=excluded.address, name=excluded.name, token=excluded.token, principal_id=excluded.principal_id, paired_at=excluded.paired_at",
+		b.ID, b.Address, b.Name, b.Token, nullString(b.PrincipalID), nullTime(b.PairedAt))
+	if err != nil {
+		db.logger.Error("unable to save bridge", zap.String("bridge_id", b.ID), zap.Error(err))
+		return nil, mapError(err)
+	}
+	return b, nil
+}
+
+// DeleteBridge removes the specified bridge.
+func (db *Database) DeleteBridge(ctx context.Context, bridgeID string) error {
+	if err := execOne(ctx, db.db, "DELETE FROM bridge WHERE id=?", bridgeID); err != nil {
+		db.logger.Error("unable to delete bridge", zap.String("bridge_id", bridgeID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// GetBridges retrieves all paired bridges.
+func (db *Database) GetBridges(ctx context.Context) ([]Bridge, error) {
+	bridges, err := db.queryBridges(ctx, "1=1")
+	if err != nil {
+		db.logger.Error("unable to get bridges", zap.Error(err))
+		return nil, err
+	}
+	return bridges, nil
+}
+
+// GetBridge retrieves the specified bridge.
+func (db *Database) GetBridge(ctx context.Context, bridgeID string) (*Bridge, error) {
+	bridges, err := db.queryBridges(ctx, "id=?", bridgeID)
+	if err != nil {
+		db.logger.Error("unable to retrieve bridge", zap.String("bridge_id", bridgeID), zap.Error(err))
+		return nil, err
+	} else if len(bridges) < 1 {
+		return nil, nil
+	}
+	return &bridges[0], nil
+}
+
+func (db *Database) queryBridges(ctx context.Context, condition string, args ...any) ([]Bridge, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id,address,name,token,principal_id,paired_at FROM bridge WHERE "+condition+" ORDER BY name,id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []Bridge
+	for rows.Next() {
+		bridge := Bridge{}
+		var principalID sql.NullString
+		var pairedAt sql.NullInt64
+		if err := rows.Scan(&bridge.ID, &bridge.Address, &bridge.Name, &bridge.Token, &principalID, &pairedAt); err != nil {
+			return nil, err
+		}
+		bridge.PrincipalID = principalID.String
+		if pairedAt.Valid {
+			bridge.PairedAt = time.Unix(pairedAt.Int64, 0)
+		}
+		ret = append(ret, bridge)
+	}
+	return ret, rows.Err()
+}
@@ -5,55 +5,141 @@ import (
 	"database/sql"
 	"embed"
 	"errors"
+	"io/fs"
+	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
-//go:embed migrations/*.sql
-var fs embed.FS
+//go:embed migrations/*.sql migrations/postgres/*.sql
+var migrationFS embed.FS
 
-// Database contains a handle to interface with the building DB
+// Database contains a handle to interface with the building DB.
+// It is backed by either SQLite or PostgreSQL; both share the same statements, and the same migrations where possible.
 type Database struct {
 	logger *zap.Logger
 
-	db *sql.DB
+	db *handle
 }
 
-// NewDatabase creates a new handle to access the building database.
+// NewDatabase creates a new handle to access the building database stored in SQLite.
 // If necessary, the linked migrations will be run.
 func NewDatabase(logger *zap.Logger, db *sql.DB) (*Database, error) {
-	migrations, err := iofs.New(fs, "migrations")
-	if err != nil {
-		logger.Error("unable to open embedded migrations")
-	}
 	driver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
 	if err != nil {
 		logger.Error("unable to create migration driver", zap.Error(err))
 		return nil, err
 	}
-	m, err := migrate.NewWithInstance(
-		"iofs", migrations,
-		"sqlite3", driver)
-	if err != nil {
-		logger.Error("unable to create migration", zap.Error(err))
+	if err := runMigrations(logger, driver, "sqlite3"); err != nil {
 		return nil, err
 	}
 
-	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		logger.Error("error running migration", zap.Error(err))
+	var foreignKeysEnabled bool
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeysEnabled); err != nil || !foreignKeysEnabled {
+		logger.Warn("foreign key enforcement is disabled; set _foreign_keys=on in the DSN to enable it")
+	}
+
+	return &Database{
+		logger: logger,
+		db:     &handle{DB: db, dialect: dialectSQLite},
+	}, nil
+}
+
+// Ping checks that the database can still be reached.
+func (db *Database) Ping(ctx context.Context) error {
+	return db.db.PingContext(ctx)
+}
+
+// Close releases the connections to the database once it is no longer being used.
+func (db *Database) Close() error {
+	return db.db.Close()
+}
+
+// NewPostgresDatabase creates a new handle to access the building database stored in PostgreSQL.
+// This allows several instances of the house service to share one database.
+// If necessary, the linked migrations will be run; concurrent instances wait for each other while this happens.
+func NewPostgresDatabase(logger *zap.Logger, db *sql.DB) (*Database, error) {
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		logger.Error("unable to create migration driver", zap.Error(err))
+		return nil, err
+	}
+	if err := runMigrations(logger, driver, "postgres"); err != nil {
 		return nil, err
 	}
 
 	return &Database{
 		logger: logger,
-		db:     db,
+		db:     &handle{DB: db, dialect: dialectPostgres},
 	}, nil
 }
 
+// migrationSource returns the migrations for the named driver.
+// The migrations are shared by both engines, except where a migration in migrations/postgres replaces the one of the
+// same name because its statements don't run on PostgreSQL.
+func migrationSource(driverName string) (source.Driver, error) {
+	migrations, err := fs.Sub(migrationFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if driverName == "postgres" {
+		replacements, err := fs.Sub(migrations, "postgres")
+		if err != nil {
+			return nil, err
+		}
+		migrations = &overlayFS{base: migrations, overlay: replacements}
+	}
+	return iofs.New(migrations, ".")
+}
+
+// overlayFS lists the files of base, but opens the file of the same name in overlay when it exists.
+type overlayFS struct {
+	base    fs.FS
+	overlay fs.FS
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	if name != "." {
+		if f, err := o.overlay.Open(name); err == nil {
+			return f, nil
+		}
+	}
+	return o.base.Open(name)
+}
+
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(o.base, name)
+}
+
+func newMigration(driver database.Driver, driverName string) (*migrate.Migrate, error) {
+	migrations, err := migrationSource(driverName)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.NewWithInstance("iofs", migrations, driverName, driver)
+}
+
+func runMigrations(logger *zap.Logger, driver database.Driver, driverName string) error {
+	m, err := newMigration(driver, driverName)
+	if err != nil {
+		logger.Error("unable to create migration", zap.Error(err))
+		return err
+	}
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		logger.Error("error running migration", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
 // CreateBuilding inserts a new building into the database.
 func (db *Database) CreateBuilding(ctx context.Context, b *Building) (*Building, error) {
 	newID := uuid.NewString()
@@ -61,7 +147,7 @@ func (db *Database) CreateBuilding(ctx context.Context, b *Building) (*Building,
 	_, err := db.db.ExecContext(ctx, "INSERT INTO building (id, name, tz, lat, lon) VALUES (?, ?, ?, ?, ?)", newID, b.Name, b.TZ, b.Location.Latitude, b.Location.Longitude)
 	if err != nil {
 		db.logger.Error("unable to create building", zap.Error(err))
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	b.ID = newID
@@ -106,13 +192,42 @@ func (db *Database) GetBuilding(ctx context.Context, buildingID string) (*Buildi
 	return building, nil
 }
 
+// UpdateBuilding saves the name, timezone and location of the supplied building.
+func (db *Database) UpdateBuilding(ctx context.Context, b *Building) (*Building, error) {
+	err := execOne(ctx, db.db, "UPDATE building SET name=?,tz=?,lat=?,lon=? WHERE id=?", b.Name, b.TZ, b.Location.Latitude, b.Location.Longitude, b.ID)
+	if err != nil {
+		db.logger.Error("unable to update building", zap.String("building_id", b.ID), zap.Error(err))
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// DeleteBuilding removes the specified building, along with its floors, rooms and the devices linked to those rooms.
+// Zones which contained the rooms are kept.
+func (db *Database) DeleteBuilding(ctx context.Context, buildingID string) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.Error("unable to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteBuilding(ctx, tx, buildingID); err != nil {
+		db.logger.Error("unable to delete building", zap.String("building_id", buildingID), zap.Error(err))
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (db *Database) CreateRoom(ctx context.Context, r *Room) (*Room, error) {
 	newID := uuid.NewString()
 
-	_, err := db.db.ExecContext(ctx, "INSERT INTO room (id, building_id, name, type) VALUES (?, ?, ?, ?)", newID, r.BuildingID, r.Name, r.Type)
+	_, err := db.db.ExecContext(ctx, "INSERT INTO room (id, building_id, floor_id, name, type) VALUES (?, ?, ?, ?, ?)", newID, r.BuildingID, nullString(r.FloorID), r.Name, r.Type)
 	if err != nil {
 		db.logger.Error("unable to create room", zap.Error(err))
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	r.ID = newID
@@ -120,7 +235,7 @@ func (db *Database) CreateRoom(ctx context.Context, r *Room) (*Room, error) {
 }
 
 func (db *Database) UpdateRoom(ctx context.Context, r *Room) (*Room, error) {
-	_, err := db.db.ExecContext(ctx, "UPDATE room SET name=?,type=? WHERE id=?", r.Name, r.Type, r.ID)
+	err := execOne(ctx, db.db, "UPDATE room SET floor_id=?,name=?,type=? WHERE id=?", nullString(r.FloorID), r.Name, r.Type, r.ID)
 	if err != nil {
 		db.logger.Error("unable to update room", zap.String("room_id", r.ID), zap.Error(err))
 		return nil, err
@@ -129,48 +244,80 @@ func (db *Database) UpdateRoom(ctx context.Context, r *Room) (*Room, error) {
 	return r, nil
 }
 
+// DeleteRoom removes the specified room, along with the devices linked to it and its zone memberships.
 func (db *Database) DeleteRoom(ctx context.Context, roomID string) error {
-	_, err := db.db.ExecContext(ctx, "DELETE FROM room WHERE id = ?", roomID)
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
+		db.logger.Error("unable to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteRoom(ctx, tx, roomID); err != nil {
 		db.logger.Error("unable to delete room", zap.String("room_id", roomID), zap.Error(err))
 		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 func (db *Database) GetRoom(ctx context.Context, roomID string) (*Room, error) {
 	room := &Room{}
-	row := db.db.QueryRowContext(ctx, "SELECT id,building_id,name,type FROM room WHERE id=?", roomID)
+	row := db.db.QueryRowContext(ctx, "SELECT id,building_id,floor_id,name,type FROM room WHERE id=?", roomID)
 
+	var floorID sql.NullString
 	var err error
-	if err = row.Scan(&room.ID, &room.BuildingID, &room.Name, &room.Type); err == sql.ErrNoRows {
+	if err = row.Scan(&room.ID, &room.BuildingID, &floorID, &room.Name, &room.Type); err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		db.logger.Error("unable to retrieve room", zap.Error(err))
 		return nil, err
 	}
+	room.FloorID = floorID.String
 	return room, nil
 }
 
+// GetRoomWithDevices retrieves the specified room along with the devices linked to it, returning nil if the room
+// doesn't exist.
+func (db *Database) GetRoomWithDevices(ctx context.Context, roomID string) (*Room, error) {
+	rooms, err := db.queryRoomsWithDevices(ctx, "room.id=?", roomID)
+	if err != nil {
+		db.logger.Error("unable to get room and devices", zap.String("room_id", roomID), zap.Error(err))
+		return nil, err
+	} else if len(rooms) < 1 {
+		return nil, nil
+	}
+	return &rooms[0], nil
+}
+
 func (db *Database) GetBuildingRooms(ctx context.Context, buildingID string) ([]Room, error) {
-	rows, err := db.db.QueryContext(ctx, "SELECT room.id AS room_id,room.building_id,room.name,room.type,device_room.id FROM room LEFT JOIN device_room ON room.id=device_room.room_id WHERE room.building_id=?", buildingID)
+	rooms, err := db.queryRoomsWithDevices(ctx, "room.building_id=?", buildingID)
 	if err != nil {
 		db.logger.Error("unable to get building rooms and devices", zap.String("building_id", buildingID), zap.Error(err))
 		return nil, err
 	}
+	return rooms, nil
+}
+
+// queryRoomsWithDevices retrieves the rooms matching the supplied condition, along with the devices linked to them.
+func (db *Database) queryRoomsWithDevices(ctx context.Context, condition string, args ...any) ([]Room, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT room.id AS room_id,room.building_id,room.floor_id,room.name,room.type,device_room.id FROM room LEFT JOIN device_room ON room.id=device_room.room_id WHERE "+condition, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	rooms := map[string]Room{}
 	for rows.Next() {
 		room := Room{}
+		var floorID sql.NullString
 		var deviceID sql.NullString
-		err = rows.Scan(&room.ID, &room.BuildingID, &room.Name, &room.Type, &deviceID)
+		err = rows.Scan(&room.ID, &room.BuildingID, &floorID, &room.Name, &room.Type, &deviceID)
 		if err != nil && err != sql.ErrNoRows {
-			db.logger.Error("unable to scan room row", zap.String("building_id", buildingID), zap.Error(err))
 			return nil, err
 		}
+		room.FloorID = floorID.String
 
 		if deviceID.Valid {
 			device := Device{}
@@ -188,17 +335,263 @@ func (db *Database) GetBuildingRooms(ctx context.Context, buildingID string) ([]
 	}
 
 	var ret []Room
+	var devices []*Device
 	for _, r := range rooms {
 		ret = append(ret, r)
+		for idx := range ret[len(ret)-1].Devices {
+			devices = append(devices, &ret[len(ret)-1].Devices[idx])
+		}
+	}
+
+	if err := loadDeviceMetadata(ctx, db.db, devices); err != nil {
+		return nil, err
 	}
 	return ret, nil
 }
 
+// CreateFloor inserts a new floor into the specified building.
+func (db *Database) CreateFloor(ctx context.Context, f *Floor) (*Floor, error) {
+	newID := uuid.NewString()
+
+	_, err := db.db.ExecContext(ctx, "INSERT INTO floor (id, building_id, name, level) VALUES (?, ?, ?, ?)", newID, f.BuildingID, f.Name, f.Level)
+	if err != nil {
+		db.logger.Error("unable to create floor", zap.Error(err))
+		return nil, mapError(err)
+	}
+
+	f.ID = newID
+	return f, nil
+}
+
+// UpdateFloor saves the name and level of the supplied floor.
+func (db *Database) UpdateFloor(ctx context.Context, f *Floor) (*Floor, error) {
+	err := execOne(ctx, db.db, "UPDATE floor SET name=?,level=? WHERE id=?", f.Name, f.Level, f.ID)
+	if err != nil {
+		db.logger.Error("unable to update floor", zap.String("floor_id", f.ID), zap.Error(err))
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// DeleteFloor removes the specified floor. Any rooms on the floor are kept but are no longer assigned to a floor.
+func (db *Database) DeleteFloor(ctx context.Context, floorID string) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.Error("unable to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteFloor(ctx, tx, floorID); err != nil {
+		db.logger.Error("unable to delete floor", zap.String("floor_id", floorID), zap.Error(err))
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetFloor retrieves the specified floor. The rooms on the floor are not populated.
+func (db *Database) GetFloor(ctx context.Context, floorID string) (*Floor, error) {
+	floor := &Floor{}
+	row := db.db.QueryRowContext(ctx, "SELECT id,building_id,name,level FROM floor WHERE id=?", floorID)
+
+	var err error
+	if err = row.Scan(&floor.ID, &floor.BuildingID, &floor.Name, &floor.Level); err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		db.logger.Error("unable to retrieve floor", zap.Error(err))
+		return nil, err
+	}
+	return floor, nil
+}
+
+// GetBuildingFloors retrieves the floors of the specified building, ordered by their level.
+// The rooms on each floor are not populated.
+func (db *Database) GetBuildingFloors(ctx context.Context, buildingID string) ([]Floor, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id,building_id,name,level FROM floor WHERE building_id=? ORDER BY level", buildingID)
+	if err != nil {
+		db.logger.Error("unable to get building floors", zap.String("building_id", buildingID), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var floors []Floor
+	for rows.Next() {
+		floor := Floor{}
+		err = rows.Scan(&floor.ID, &floor.BuildingID, &floor.Name, &floor.Level)
+		if err != nil && err != sql.ErrNoRows {
+			db.logger.Error("unable to scan floor row", zap.String("building_id", buildingID), zap.Error(err))
+			return nil, err
+		}
+		floors = append(floors, floor)
+	}
+	return floors, nil
+}
+
+// CreateZone inserts a new zone containing the specified rooms.
+func (db *Database) CreateZone(ctx context.Context, z *Zone) (*Zone, error) {
+	newID := uuid.NewString()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.Error("unable to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO zone (id, name) VALUES (?, ?)", newID, z.Name); err != nil {
+		db.logger.Error("unable to create zone", zap.Error(err))
+		return nil, mapError(err)
+	}
+	if err := insertZoneRooms(ctx, tx, newID, z.RoomIDs); err != nil {
+		db.logger.Error("unable to add rooms to zone", zap.String("zone_id", newID), zap.Error(err))
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		db.logger.Error("unable to commit zone", zap.String("zone_id", newID), zap.Error(err))
+		return nil, err
+	}
+
+	z.ID = newID
+	return z, nil
+}
+
+// UpdateZone saves the name of the supplied zone and replaces the set of rooms in it.
+func (db *Database) UpdateZone(ctx context.Context, z *Zone) (*Zone, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.Error("unable to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := execOne(ctx, tx, "UPDATE zone SET name=? WHERE id=?", z.Name, z.ID); err != nil {
+		db.logger.Error("unable to update zone", zap.String("zone_id", z.ID), zap.Error(err))
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM zone_room WHERE zone_id=?", z.ID); err != nil {
+		db.logger.Error("unable to clear zone rooms", zap.String("zone_id", z.ID), zap.Error(err))
+		return nil, mapError(err)
+	}
+	if err := insertZoneRooms(ctx, tx, z.ID, z.RoomIDs); err != nil {
+		db.logger.Error("unable to add rooms to zone", zap.String("zone_id", z.ID), zap.Error(err))
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		db.logger.Error("unable to commit zone", zap.String("zone_id", z.ID), zap.Error(err))
+		return nil, err
+	}
+
+	return z, nil
+}
+
+// DeleteZone removes the specified zone. The rooms in the zone are not changed.
+func (db *Database) DeleteZone(ctx context.Context, zoneID string) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.Error("unable to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM zone_room WHERE zone_id=?", zoneID); err != nil {
+		db.logger.Error("unable to clear zone rooms", zap.String("zone_id", zoneID), zap.Error(err))
+		return mapError(err)
+	}
+	if err := execOne(ctx, tx, "DELETE FROM zone WHERE id=?", zoneID); err != nil {
+		db.logger.Error("unable to delete zone", zap.String("zone_id", zoneID), zap.Error(err))
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetZones retrieves all stored zones, along with the IDs of the rooms in each.
+func (db *Database) GetZones(ctx context.Context) ([]Zone, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT zone.id,zone.name,zone_room.room_id FROM zone LEFT JOIN zone_room ON zone.id=zone_room.zone_id ORDER BY zone.name,zone.id")
+	if err != nil {
+		db.logger.Error("unable to get zones", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var zones []Zone
+	for rows.Next() {
+		zone := Zone{}
+		var roomID sql.NullString
+		if err := rows.Scan(&zone.ID, &zone.Name, &roomID); err != nil {
+			db.logger.Error("unable to scan zone row", zap.Error(err))
+			return nil, err
+		}
+
+		if len(zones) < 1 || zones[len(zones)-1].ID != zone.ID {
+			zones = append(zones, zone)
+		}
+		if roomID.Valid {
+			zones[len(zones)-1].RoomIDs = append(zones[len(zones)-1].RoomIDs, roomID.String)
+		}
+	}
+	return zones, nil
+}
+
+// GetZone retrieves the specified zone, along with the IDs of the rooms in it.
+func (db *Database) GetZone(ctx context.Context, zoneID string) (*Zone, error) {
+	zone := &Zone{}
+	row := db.db.QueryRowContext(ctx, "SELECT id,name FROM zone WHERE id=?", zoneID)
+
+	var err error
+	if err = row.Scan(&zone.ID, &zone.Name); err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		db.logger.Error("unable to retrieve zone", zap.Error(err))
+		return nil, err
+	}
+
+	rows, err := db.db.QueryContext(ctx, "SELECT room_id FROM zone_room WHERE zone_id=?", zoneID)
+	if err != nil {
+		db.logger.Error("unable to get zone rooms", zap.String("zone_id", zoneID), zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var roomID string
+		if err := rows.Scan(&roomID); err != nil {
+			db.logger.Error("unable to scan zone room row", zap.String("zone_id", zoneID), zap.Error(err))
+			return nil, err
+		}
+		zone.RoomIDs = append(zone.RoomIDs, roomID)
+	}
+	return zone, nil
+}
+
+// GetZoneRooms retrieves the rooms in the specified zone, along with the devices linked to them.
+func (db *Database) GetZoneRooms(ctx context.Context, zoneID string) ([]Room, error) {
+	rooms, err := db.queryRoomsWithDevices(ctx, "room.id IN (SELECT room_id FROM zone_room WHERE zone_id=?)", zoneID)
+	if err != nil {
+		db.logger.Error("unable to get zone rooms and devices", zap.String("zone_id", zoneID), zap.Error(err))
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func insertZoneRooms(ctx context.Context, e execer, zoneID string, roomIDs []string) error {
+	for _, roomID := range roomIDs {
+		if _, err := e.ExecContext(ctx, "INSERT INTO zone_room (zone_id, room_id) VALUES (?, ?)", zoneID, roomID); err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
 func (db *Database) CreateDevice(ctx context.Context, deviceID string, room Room) (*Device, error) {
 	_, err := db.db.ExecContext(ctx, "INSERT INTO device_room (id, room_id) VALUES (?, ?)", deviceID, room.ID)
 	if err != nil {
 		db.logger.Error("unable to create device", zap.String("device_id", deviceID), zap.Error(err))
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return &Device{
@@ -208,7 +601,7 @@ func (db *Database) CreateDevice(ctx context.Context, deviceID string, room Room
 }
 
 func (db *Database) DeleteDevice(ctx context.Context, deviceID string) error {
-	_, err := db.db.ExecContext(ctx, "DELETE FROM device_room WHERE id = ?", deviceID)
+	err := execOne(ctx, db.db, "DELETE FROM device_room WHERE id = ?", deviceID)
 	if err != nil {
 		db.logger.Error("unable to delete device", zap.String("device_id", deviceID), zap.Error(err))
 		return err
@@ -216,3 +609,222 @@ func (db *Database) DeleteDevice(ctx context.Context, deviceID string) error {
 
 	return nil
 }
+
+// GetDevice retrieves the room link and metadata of the specified device.
+func (db *Database) GetDevice(ctx context.Context, deviceID string) (*Device, error) {
+	devices, err := db.SearchDevices(ctx, DeviceFilter{ID: deviceID})
+	if err != nil {
+		return nil, err
+	} else if len(devices) < 1 {
+		return nil, nil
+	}
+	return &devices[0], nil
+}
+
+// UpdateDeviceMetadata replaces the tags and aliases of the supplied device.
+// Metadata is kept when a device is unlinked from its room.
+func (db *Database) UpdateDeviceMetadata(ctx context.Context, d *Device) (*Device, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.Error("unable to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := clearDeviceMetadata(ctx, tx, d.ID); err != nil {
+		db.logger.Error("unable to clear device metadata", zap.String("device_id", d.ID), zap.Error(err))
+		return nil, err
+	}
+	if err := insertDeviceMetadata(ctx, tx, d); err != nil {
+		db.logger.Error("unable to add device metadata", zap.String("device_id", d.ID), zap.Error(err))
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		db.logger.Error("unable to commit device metadata", zap.String("device_id", d.ID), zap.Error(err))
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// SearchDevices retrieves the devices which are linked to a room or have metadata, and which match the supplied filter.
+func (db *Database) SearchDevices(ctx context.Context, filter DeviceFilter) ([]Device, error) {
+	query := "SELECT devices.device_id,device_room.room_id FROM (SELECT id AS device_id FROM device_room UNION SELECT device_id FROM device_tag UNION SELECT device_id FROM device_alias) AS devices LEFT JOIN device_room ON devices.device_id=device_room.id WHERE 1=1"
+	var args []any
+	if len(filter.ID) > 0 {
+		query += " AND devices.device_id=?"
+		args = append(args, filter.ID)
+	}
+	if len(filter.Tag) > 0 {
+		query += " AND devices.device_id IN (SELECT device_id FROM device_tag WHERE tag=?)"
+		args = append(args, filter.Tag)
+	}
+	if len(filter.Alias) > 0 {
+		query += " AND devices.device_id IN (SELECT device_id FROM device_alias WHERE lower(alias)=lower(CAST(? AS TEXT)))"
+		args = append(args, filter.Alias)
+	}
+	if len(filter.RoomID) > 0 {
+		query += " AND device_room.room_id=?"
+		args = append(args, filter.RoomID)
+	}
+	if len(filter.ZoneID) > 0 {
+		query += " AND device_room.room_id IN (SELECT room_id FROM zone_room WHERE zone_id=?)"
+		args = append(args, filter.ZoneID)
+	}
+	query += " ORDER BY devices.device_id"
+
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		db.logger.Error("unable to search devices", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []Device
+	for rows.Next() {
+		device := Device{}
+		var roomID sql.NullString
+		if err := rows.Scan(&device.ID, &roomID); err != nil {
+			db.logger.Error("unable to scan device row", zap.Error(err))
+			return nil, err
+		}
+		device.RoomID = roomID.String
+		ret = append(ret, device)
+	}
+
+	var devices []*Device
+	for idx := range ret {
+		devices = append(devices, &ret[idx])
+	}
+	if err := loadDeviceMetadata(ctx, db.db, devices); err != nil {
+		db.logger.Error("unable to get device metadata", zap.Error(err))
+		return nil, err
+	}
+	return ret, nil
+}
+
+func clearDeviceMetadata(ctx context.Context, e execer, deviceID string) error {
+	if _, err := e.ExecContext(ctx, "DELETE FROM device_tag WHERE device_id=?", deviceID); err != nil {
+		return mapError(err)
+	}
+	if _, err := e.ExecContext(ctx, "DELETE FROM device_alias WHERE device_id=?", deviceID); err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+func insertDeviceMetadata(ctx context.Context, e execer, d *Device) error {
+	for _, tag := range d.Tags {
+		if _, err := e.ExecContext(ctx, "INSERT INTO device_tag (device_id, tag) VALUES (?, ?)", d.ID, tag); err != nil {
+			return mapError(err)
+		}
+	}
+	for _, alias := range d.Aliases {
+		if _, err := e.ExecContext(ctx, "INSERT INTO device_alias (alias, device_id) VALUES (?, ?)", alias, d.ID); err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+// loadDeviceMetadata populates the tags and aliases of the supplied devices.
+func loadDeviceMetadata(ctx context.Context, q querier, devices []*Device) error {
+	if len(devices) < 1 {
+		return nil
+	}
+
+	byID := map[string]*Device{}
+	var args []any
+	for _, device := range devices {
+		byID[device.ID] = device
+		args = append(args, device.ID)
+	}
+	placeholders := strings.Repeat(",?", len(args))[1:]
+
+	rows, err := q.QueryContext(ctx, "SELECT device_id,tag,'' FROM device_tag WHERE device_id IN ("+placeholders+") UNION ALL SELECT device_id,'',alias FROM device_alias WHERE device_id IN ("+placeholders+") ORDER BY 1,2,3", append(args, args...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var deviceID, tag, alias string
+		if err := rows.Scan(&deviceID, &tag, &alias); err != nil {
+			return err
+		}
+
+		device := byID[deviceID]
+		if len(tag) > 0 {
+			device.Tags = append(device.Tags, tag)
+		} else {
+			device.Aliases = append(device.Aliases, alias)
+		}
+	}
+	return nil
+}
+
+// deleteBuilding removes the specified building along with everything in it, returning ErrNotFound if it doesn't exist.
+func deleteBuilding(ctx context.Context, e execer, buildingID string) error {
+	stmts := []string{
+		"DELETE FROM device_room WHERE room_id IN (SELECT id FROM room WHERE building_id=?)",
+		"DELETE FROM zone_room WHERE room_id IN (SELECT id FROM room WHERE building_id=?)",
+		"DELETE FROM room WHERE building_id=?",
+		"DELETE FROM floor WHERE building_id=?",
+	}
+	for _, stmt := range stmts {
+		if _, err := e.ExecContext(ctx, stmt, buildingID); err != nil {
+			return mapError(err)
+		}
+	}
+	return execOne(ctx, e, "DELETE FROM building WHERE id=?", buildingID)
+}
+
+// deleteRoom removes the specified room along with its device links and zone memberships, returning ErrNotFound if it doesn't exist.
+func deleteRoom(ctx context.Context, e execer, roomID string) error {
+	if _, err := e.ExecContext(ctx, "DELETE FROM device_room WHERE room_id = ?", roomID); err != nil {
+		return mapError(err)
+	}
+	if _, err := e.ExecContext(ctx, "DELETE FROM zone_room WHERE room_id = ?", roomID); err != nil {
+		return mapError(err)
+	}
+	return execOne(ctx, e, "DELETE FROM room WHERE id = ?", roomID)
+}
+
+// deleteFloor unassigns the rooms on the specified floor and removes it, returning ErrNotFound if it doesn't exist.
+func deleteFloor(ctx context.Context, e execer, floorID string) error {
+	if _, err := e.ExecContext(ctx, "UPDATE room SET floor_id=NULL WHERE floor_id=?", floorID); err != nil {
+		return mapError(err)
+	}
+	return execOne(ctx, e, "DELETE FROM floor WHERE id = ?", floorID)
+}
+
+// nullString converts an optional ID into a value which is stored as NULL when empty.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: len(s) > 0}
+}
+
+// execer is satisfied by both the database handle and any transactions created from it.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// querier is satisfied by both the database handle and any transactions created from it.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// execOne runs the supplied statement and returns ErrNotFound if no rows were changed by it.
+func execOne(ctx context.Context, e execer, query string, args ...any) error {
+	res, err := e.ExecContext(ctx, query, args...)
+	if err != nil {
+		return mapError(err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	} else if count < 1 {
+		return ErrNotFound
+	}
+	return nil
+}
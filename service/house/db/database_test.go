@@ -0,0 +1,533 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// newTestDatabase creates an empty database for a test. It is replaced to run the tests against other engines.
+var newTestDatabase = newSQLiteTestDatabase
+
+func newSQLiteTestDatabase(t *testing.T) *Database {
+	dsn := fmt.Sprintf("file:%s?parseTime=true&_foreign_keys=on", filepath.Join(t.TempDir(), "house.db"))
+	sqlDB, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := NewDatabase(zaptest.NewLogger(t), sqlDB)
+	require.NoError(t, err)
+	return db
+}
+
+// newTestMigration returns the migrations of the test database, so they can be stepped through.
+func newTestMigration(t *testing.T, db *Database) *migrate.Migrate {
+	var driver database.Driver
+	var err error
+	driverName := "sqlite3"
+	if db.db.dialect == dialectPostgres {
+		driverName = "postgres"
+		driver, err = postgres.WithInstance(db.db.DB, &postgres.Config{})
+	} else {
+		driver, err = sqlite3.WithInstance(db.db.DB, &sqlite3.Config{})
+	}
+	require.NoError(t, err)
+
+	m, err := newMigration(driver, driverName)
+	require.NoError(t, err)
+	return m
+}
+
+func TestCreateRoomMissingBuilding(t *testing.T) {
+	db := newTestDatabase(t)
+
+	_, err := db.CreateRoom(context.Background(), &Room{BuildingID: "missing", Name: "Kitchen"})
+	assert.ErrorIs(t, err, ErrInvalidReference)
+}
+
+func TestDeleteRoomRemovesDeviceLinks(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	building, err := db.CreateBuilding(ctx, &Building{Name: "Home", TZ: "UTC"})
+	require.NoError(t, err)
+	room, err := db.CreateRoom(ctx, &Room{BuildingID: building.ID, Name: "Kitchen", Type: Kitchen})
+	require.NoError(t, err)
+	_, err = db.CreateDevice(ctx, "device1", *room)
+	require.NoError(t, err)
+
+	_, err = db.CreateDevice(ctx, "device1", *room)
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	require.NoError(t, db.DeleteRoom(ctx, room.ID))
+	assert.ErrorIs(t, db.DeleteRoom(ctx, room.ID), ErrNotFound)
+	assert.ErrorIs(t, db.DeleteDevice(ctx, "device1"), ErrNotFound)
+}
+
+func TestDeleteBuildingCascades(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	building, err := db.CreateBuilding(ctx, &Building{Name: "Home", TZ: "UTC"})
+	require.NoError(t, err)
+	floor, err := db.CreateFloor(ctx, &Floor{BuildingID: building.ID, Name: "Ground"})
+	require.NoError(t, err)
+	room, err := db.CreateRoom(ctx, &Room{BuildingID: building.ID, FloorID: floor.ID, Name: "Kitchen"})
+	require.NoError(t, err)
+	_, err = db.CreateDevice(ctx, "device1", *room)
+	require.NoError(t, err)
+
+	require.NoError(t, db.DeleteBuilding(ctx, building.ID))
+
+	res, err := db.GetRoom(ctx, room.ID)
+	require.NoError(t, err)
+	assert.Nil(t, res)
+	floorRes, err := db.GetFloor(ctx, floor.ID)
+	require.NoError(t, err)
+	assert.Nil(t, floorRes)
+	assert.ErrorIs(t, db.DeleteDevice(ctx, "device1"), ErrNotFound)
+}
+
+func TestFloors(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	building, err := db.CreateBuilding(ctx, &Building{Name: "Home", TZ: "UTC"})
+	require.NoError(t, err)
+	upstairs, err := db.CreateFloor(ctx, &Floor{BuildingID: building.ID, Name: "Upstairs", Level: 1})
+	require.NoError(t, err)
+	basement, err := db.CreateFloor(ctx, &Floor{BuildingID: building.ID, Name: "Basement", Level: -1})
+	require.NoError(t, err)
+	room, err := db.CreateRoom(ctx, &Room{BuildingID: building.ID, FloorID: upstairs.ID, Name: "Bedroom", Type: Bedroom})
+	require.NoError(t, err)
+
+	_, err = db.CreateFloor(ctx, &Floor{BuildingID: "missing", Name: "Ground"})
+	assert.ErrorIs(t, err, ErrInvalidReference)
+
+	floors, err := db.GetBuildingFloors(ctx, building.ID)
+	require.NoError(t, err)
+	require.Len(t, floors, 2)
+	assert.Equal(t, basement.ID, floors[0].ID)
+	assert.Equal(t, upstairs.ID, floors[1].ID)
+
+	// Deleting a floor keeps its rooms, but they are no longer on a floor.
+	require.NoError(t, db.DeleteFloor(ctx, upstairs.ID))
+	assert.ErrorIs(t, db.DeleteFloor(ctx, upstairs.ID), ErrNotFound)
+
+	res, err := db.GetFloor(ctx, upstairs.ID)
+	require.NoError(t, err)
+	assert.Nil(t, res)
+	roomRes, err := db.GetRoom(ctx, room.ID)
+	require.NoError(t, err)
+	require.NotNil(t, roomRes)
+	assert.Empty(t, roomRes.FloorID)
+}
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	building, err := db.CreateBuilding(ctx, &Building{Name: "Home", TZ: "UTC"})
+	require.NoError(t, err)
+	floor, err := db.CreateFloor(ctx, &Floor{BuildingID: building.ID, Name: "Ground"})
+	require.NoError(t, err)
+	room, err := db.CreateRoom(ctx, &Room{BuildingID: building.ID, FloorID: floor.ID, Name: "Kitchen", Type: Kitchen})
+	require.NoError(t, err)
+	_, err = db.CreateDevice(ctx, "device1", *room)
+	require.NoError(t, err)
+
+	// Migrating down to before floors were added keeps the rooms and the devices in them.
+	m := newTestMigration(t, db)
+	require.NoError(t, m.Migrate(2))
+	require.NoError(t, m.Up())
+
+	rooms, err := db.GetBuildingRooms(ctx, building.ID)
+	require.NoError(t, err)
+	require.Len(t, rooms, 1)
+	assert.Equal(t, "Kitchen", rooms[0].Name)
+	assert.Empty(t, rooms[0].FloorID)
+	require.Len(t, rooms[0].Devices, 1)
+	assert.Equal(t, "device1", rooms[0].Devices[0].ID)
+
+	// The migrations can also be reversed entirely.
+	require.NoError(t, m.Down())
+	require.NoError(t, m.Up())
+}
+
+func TestZoneRooms(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	building, err := db.CreateBuilding(ctx, &Building{Name: "Home", TZ: "UTC"})
+	require.NoError(t, err)
+	bedroom, err := db.CreateRoom(ctx, &Room{BuildingID: building.ID, Name: "Bedroom", Type: Bedroom})
+	require.NoError(t, err)
+	office, err := db.CreateRoom(ctx, &Room{BuildingID: building.ID, Name: "Office", Type: Office})
+	require.NoError(t, err)
+
+	_, err = db.CreateZone(ctx, &Zone{Name: "Upstairs", RoomIDs: []string{"missing"}})
+	assert.ErrorIs(t, err, ErrInvalidReference)
+
+	zone, err := db.CreateZone(ctx, &Zone{Name: "Upstairs", RoomIDs: []string{bedroom.ID, office.ID}})
+	require.NoError(t, err)
+
+	require.NoError(t, db.DeleteRoom(ctx, office.ID))
+
+	res, err := db.GetZone(ctx, zone.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{bedroom.ID}, res.RoomIDs)
+
+	rooms, err := db.GetZoneRooms(ctx, zone.ID)
+	require.NoError(t, err)
+	require.Len(t, rooms, 1)
+	assert.Equal(t, bedroom.ID, rooms[0].ID)
+
+	zones, err := db.GetZones(ctx)
+	require.NoError(t, err)
+	assert.Len(t, zones, 1)
+}
+
+func TestZonesWithSameName(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	building, err := db.CreateBuilding(ctx, &Building{Name: "Home", TZ: "UTC"})
+	require.NoError(t, err)
+	var roomIDs []string
+	for _, name := range []string{"Porch", "Deck", "Garage", "Shed"} {
+		room, err := db.CreateRoom(ctx, &Room{BuildingID: building.ID, Name: name})
+		require.NoError(t, err)
+		roomIDs = append(roomIDs, room.ID)
+	}
+
+	front, err := db.CreateZone(ctx, &Zone{Name: "Outdoors", RoomIDs: []string{roomIDs[0], roomIDs[2]}})
+	require.NoError(t, err)
+	back, err := db.CreateZone(ctx, &Zone{Name: "Outdoors", RoomIDs: []string{roomIDs[1], roomIDs[3]}})
+	require.NoError(t, err)
+	empty, err := db.CreateZone(ctx, &Zone{Name: "Outdoors"})
+	require.NoError(t, err)
+
+	// Each zone keeps its own rooms, even though they share a name.
+	zones, err := db.GetZones(ctx)
+	require.NoError(t, err)
+	require.Len(t, zones, 3)
+	byID := map[string][]string{}
+	for _, zone := range zones {
+		assert.Equal(t, "Outdoors", zone.Name)
+		assert.NotContains(t, byID, zone.ID)
+		byID[zone.ID] = zone.RoomIDs
+	}
+	assert.ElementsMatch(t, front.RoomIDs, byID[front.ID])
+	assert.ElementsMatch(t, back.RoomIDs, byID[back.ID])
+	assert.Empty(t, byID[empty.ID])
+}
+
+func TestSearchDevicesByZone(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	building, err := db.CreateBuilding(ctx, &Building{Name: "Home", TZ: "UTC"})
+	require.NoError(t, err)
+	bedroom, err := db.CreateRoom(ctx, &Room{BuildingID: building.ID, Name: "Bedroom", Type: Bedroom})
+	require.NoError(t, err)
+	kitchen, err := db.CreateRoom(ctx, &Room{BuildingID: building.ID, Name: "Kitchen", Type: Kitchen})
+	require.NoError(t, err)
+	zone, err := db.CreateZone(ctx, &Zone{Name: "Upstairs", RoomIDs: []string{bedroom.ID}})
+	require.NoError(t, err)
+
+	for id, room := range map[string]*Room{"lamp": bedroom, "fan": bedroom, "kettle": kitchen} {
+		_, err = db.CreateDevice(ctx, id, *room)
+		require.NoError(t, err)
+	}
+	_, err = db.UpdateDeviceMetadata(ctx, &Device{ID: "lamp", Tags: []string{"light"}})
+	require.NoError(t, err)
+
+	devices, err := db.SearchDevices(ctx, DeviceFilter{ZoneID: zone.ID})
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.Equal(t, "fan", devices[0].ID)
+	assert.Equal(t, "lamp", devices[1].ID)
+
+	devices, err = db.SearchDevices(ctx, DeviceFilter{ZoneID: zone.ID, Tag: "light"})
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, "lamp", devices[0].ID)
+}
+
+func TestDeviceMetadata(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	building, err := db.CreateBuilding(ctx, &Building{Name: "Home", TZ: "UTC"})
+	require.NoError(t, err)
+	room, err := db.CreateRoom(ctx, &Room{BuildingID: building.ID, Name: "Bedroom", Type: Bedroom})
+	require.NoError(t, err)
+	_, err = db.CreateDevice(ctx, "device1", *room)
+	require.NoError(t, err)
+
+	_, err = db.UpdateDeviceMetadata(ctx, &Device{ID: "device1", Tags: []string{"night-light"}, Aliases: []string{"Lamp"}})
+	require.NoError(t, err)
+	_, err = db.UpdateDeviceMetadata(ctx, &Device{ID: "device2", Tags: []string{"critical"}, Aliases: []string{"lamp"}})
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	_, err = db.UpdateDeviceMetadata(ctx, &Device{ID: "device2", Tags: []string{"critical", "night-light"}})
+	require.NoError(t, err)
+
+	devices, err := db.SearchDevices(ctx, DeviceFilter{Tag: "night-light"})
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.Equal(t, room.ID, devices[0].RoomID)
+	assert.Empty(t, devices[1].RoomID)
+
+	devices, err = db.SearchDevices(ctx, DeviceFilter{Alias: "LAMP"})
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, "device1", devices[0].ID)
+
+	rooms, err := db.GetBuildingRooms(ctx, building.ID)
+	require.NoError(t, err)
+	require.Len(t, rooms, 1)
+	require.Len(t, rooms[0].Devices, 1)
+	assert.Equal(t, []string{"night-light"}, rooms[0].Devices[0].Tags)
+	assert.Equal(t, []string{"Lamp"}, rooms[0].Devices[0].Aliases)
+}
+
+func TestPortableColumns(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	m := newTestMigration(t, db)
+
+	require.NoError(t, m.Steps(-1))
+	building, err := db.CreateBuilding(ctx, &Building{Name: "Home", TZ: "UTC", Location: Location{Latitude: 43.6532247, Longitude: -79.3831843}})
+	require.NoError(t, err)
+	_, err = db.UpdateDeviceMetadata(ctx, &Device{ID: "device1", Aliases: []string{"Lamp"}})
+	require.NoError(t, err)
+
+	require.NoError(t, m.Steps(1))
+	_, err = db.UpdateDeviceMetadata(ctx, &Device{ID: "device2", Aliases: []string{"lamp"}})
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	devices, err := db.SearchDevices(ctx, DeviceFilter{Alias: "LAMP"})
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, "device1", devices[0].ID)
+
+	// Coordinates are kept in double precision from now on.
+	_, err = db.UpdateBuilding(ctx, &Building{ID: building.ID, Name: "Home", TZ: "UTC", Location: Location{Latitude: 43.6532247, Longitude: -79.3831843}})
+	require.NoError(t, err)
+	res, err := db.GetBuilding(ctx, building.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Location{Latitude: 43.6532247, Longitude: -79.3831843}, res.Location)
+}
+
+func TestPrincipals(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	building, err := db.CreateBuilding(ctx, &Building{Name: "Home", TZ: "UTC"})
+	require.NoError(t, err)
+	room, err := db.CreateRoom(ctx, &Room{BuildingID: building.ID, Name: "Spare Room", Type: Bedroom})
+	require.NoError(t, err)
+
+	admin, err := db.CreatePrincipal(ctx, &Principal{Subject: "housecli", Role: RoleAdmin})
+	require.NoError(t, err)
+	_, err = db.CreatePrincipal(ctx, &Principal{Subject: "housecli", Role: RoleReadOnly})
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	expiresAt := time.Unix(1700000000, 0)
+	guest, err := db.CreatePrincipal(ctx, &Principal{
+		TokenHash:   "hash",
+		Description: "guest",
+		Role:        RoleControl,
+		RoomIDs:     []string{room.ID},
+		DeviceTypes: []string{"light", "television"},
+		ExpiresAt:   expiresAt,
+	})
+	require.NoError(t, err)
+
+	res, err := db.GetPrincipalByTokenHash(ctx, "hash")
+	require.NoError(t, err)
+	require.NotNil(t, res)
+	assert.Equal(t, guest.ID, res.ID)
+	assert.Empty(t, res.Subject)
+	assert.Equal(t, RoleControl, res.Role)
+	assert.Equal(t, []string{room.ID}, res.RoomIDs)
+	assert.Equal(t, []string{"light", "television"}, res.DeviceTypes)
+	assert.True(t, expiresAt.Equal(res.ExpiresAt))
+
+	res, err = db.GetPrincipalBySubject(ctx, "housecli")
+	require.NoError(t, err)
+	require.NotNil(t, res)
+	assert.Equal(t, admin.ID, res.ID)
+	assert.True(t, res.ExpiresAt.IsZero())
+
+	// Deleting the room mustn't widen what the guest can control.
+	require.NoError(t, db.DeleteRoom(ctx, room.ID))
+	res, err = db.GetPrincipal(ctx, guest.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{room.ID}, res.RoomIDs)
+
+	res, err = db.UpdatePrincipal(ctx, &Principal{ID: guest.ID, Role: RoleReadOnly})
+	require.NoError(t, err)
+	assert.Equal(t, "hash", res.TokenHash)
+	assert.Empty(t, res.RoomIDs)
+	assert.True(t, res.ExpiresAt.IsZero())
+
+	require.NoError(t, db.DeletePrincipal(ctx, guest.ID))
+	assert.ErrorIs(t, db.DeletePrincipal(ctx, guest.ID), ErrNotFound)
+
+	principals, err := db.GetPrincipals(ctx)
+	require.NoError(t, err)
+	require.Len(t, principals, 1)
+	assert.Equal(t, admin.ID, principals[0].ID)
+}
+
+func TestBridges(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	pairedAt := time.Unix(1700000000, 0)
+	_, err := db.SaveBridge(ctx, &Bridge{ID: "roku", Address: "roku.local:5000", Name: "Roku", Token: "first", PrincipalID: "p1", PairedAt: pairedAt})
+	require.NoError(t, err)
+	_, err = db.SaveBridge(ctx, &Bridge{ID: "clock", Address: "clock.local:5000", Name: "Clock", Token: "clock"})
+	require.NoError(t, err)
+
+	res, err := db.GetBridge(ctx, "roku")
+	require.NoError(t, err)
+	require.NotNil(t, res)
+	assert.Equal(t, "first", res.Token)
+	assert.Equal(t, "p1", res.PrincipalID)
+	assert.True(t, pairedAt.Equal(res.PairedAt))
+
+	// Re-pairing replaces the credentials of the existing bridge.
+	_, err = db.SaveBridge(ctx, &Bridge{ID: "roku", Address: "10.0.0.5:5000", Name: "Roku", Token: "second", PrincipalID: "p2", PairedAt: pairedAt})
+	require.NoError(t, err)
+	res, err = db.GetBridge(ctx, "roku")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.5:5000", res.Address)
+	assert.Equal(t, "second", res.Token)
+	assert.Equal(t, "p2", res.PrincipalID)
+
+	bridges, err := db.GetBridges(ctx)
+	require.NoError(t, err)
+	require.Len(t, bridges, 2)
+	assert.Equal(t, "clock", bridges[0].ID)
+	assert.Empty(t, bridges[0].PrincipalID)
+	assert.True(t, bridges[0].PairedAt.IsZero())
+
+	require.NoError(t, db.DeleteBridge(ctx, "roku"))
+	assert.ErrorIs(t, db.DeleteBridge(ctx, "roku"), ErrNotFound)
+	res, err = db.GetBridge(ctx, "roku")
+	require.NoError(t, err)
+	assert.Nil(t, res)
+}
+
+func TestDeviceLinks(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	updatedAt := time.Unix(1700000000, 0)
+	_, err := db.SaveDeviceLink(ctx, &DeviceLink{DeviceID: "tv", NetworkDeviceID: "AA-BB-CC-00-00-01", Status: LinkRejected, UpdatedAt: updatedAt})
+	require.NoError(t, err)
+	_, err = db.SaveDeviceLink(ctx, &DeviceLink{DeviceID: "clock", NetworkDeviceID: "AA-BB-CC-00-00-02", Status: LinkConfirmed})
+	require.NoError(t, err)
+
+	// Saving the link again replaces the decision made about it.
+	_, err = db.SaveDeviceLink(ctx, &DeviceLink{DeviceID: "tv", NetworkDeviceID: "AA-BB-CC-00-00-01", Status: LinkConfirmed, UpdatedAt: updatedAt})
+	require.NoError(t, err)
+
+	links, err := db.GetDeviceLinks(ctx)
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	assert.Equal(t, "clock", links[0].DeviceID)
+	assert.True(t, links[0].UpdatedAt.IsZero())
+	assert.Equal(t, "tv", links[1].DeviceID)
+	assert.Equal(t, "AA-BB-CC-00-00-01", links[1].NetworkDeviceID)
+	assert.Equal(t, LinkConfirmed, links[1].Status)
+	assert.True(t, updatedAt.Equal(links[1].UpdatedAt))
+}
+
+func TestApplyLayout(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	layout := []LayoutBuilding{
+		{
+			Building: Building{Name: "Home", TZ: "UTC"},
+			Floors: []Floor{
+				{Name: "Ground", Rooms: []Room{
+					{Name: "Kitchen", Type: Kitchen, Devices: []Device{{ID: "device1", Tags: []string{"night-light"}}}},
+				}},
+			},
+			Rooms: []Room{{Name: "Porch", Type: Porch}},
+		},
+	}
+
+	changes, err := db.ApplyLayout(ctx, layout, true)
+	require.NoError(t, err)
+	assert.Len(t, changes, 5)
+	buildings, err := db.GetBuildings(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, buildings)
+
+	_, err = db.ApplyLayout(ctx, layout, false)
+	require.NoError(t, err)
+	changes, err = db.ApplyLayout(ctx, layout, false)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	exported, err := db.GetLayout(ctx)
+	require.NoError(t, err)
+	require.Len(t, exported, 1)
+	require.Len(t, exported[0].Floors, 1)
+	require.Len(t, exported[0].Floors[0].Rooms, 1)
+	assert.Equal(t, []string{"night-light"}, exported[0].Floors[0].Rooms[0].Devices[0].Tags)
+
+	layout[0].Rooms = nil
+	layout[0].Floors[0].Rooms[0].Devices[0].Tags = nil
+	changes, err = db.ApplyLayout(ctx, layout, false)
+	require.NoError(t, err)
+	assert.Equal(t, []LayoutChange{
+		{Action: LayoutUpdate, Kind: LayoutKindDevice, Path: "device1"},
+		{Action: LayoutDelete, Kind: LayoutKindRoom, Path: "Home/Porch"},
+	}, changes)
+}
+
+func TestBackup(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	_, err := db.CreateBuilding(ctx, &Building{Name: "Home", TZ: "UTC"})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	require.NoError(t, db.Backup(ctx, path))
+
+	version, err := VerifySnapshot(ctx, path)
+	require.NoError(t, err)
+	latest, err := LatestSchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, latest, version)
+
+	snapshot, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer snapshot.Close()
+	var count int
+	require.NoError(t, snapshot.QueryRow("SELECT COUNT(*) FROM building").Scan(&count))
+	assert.Equal(t, 1, count)
+
+	_, err = snapshot.Exec("UPDATE schema_migrations SET version=?", latest+1)
+	require.NoError(t, err)
+	_, err = VerifySnapshot(ctx, path)
+	assert.ErrorIs(t, err, ErrUnsupportedSchema)
+}
@@ -2,6 +2,20 @@ package db
 
 // Device captures metadata linking the physical location of a device to a room.
 type Device struct {
+	ID string
+	// RoomID is the room the device is linked to; empty if the device hasn't been linked to a room.
+	RoomID string
+
+	// Tags and Aliases are set by users of the house, independent of the configuration held by the bridge.
+	Tags    []string
+	Aliases []string
+}
+
+// DeviceFilter restricts the devices returned by a search. Empty fields aren't used to filter.
+type DeviceFilter struct {
 	ID     string
+	Tag    string
+	Alias  string
 	RoomID string
+	ZoneID string
 }
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LinkStatus records the decision made about a suggested device link.
+type LinkStatus int
+
+const (
+	LinkUnspecified LinkStatus = iota
+	// LinkConfirmed joins the devices so they are presented as one.
+	LinkConfirmed
+	// LinkRejected stops the devices being suggested as a link.
+	LinkRejected
+)
+
+// DeviceLink joins a device to the connected device reporting its network presence.
+type DeviceLink struct {
+	DeviceID        string
+	NetworkDeviceID string
+	Status          LinkStatus
+
+	UpdatedAt time.Time
+}
+
+// SaveDeviceLink inserts the supplied link, or replaces the status of the existing link between the devices.
+func (db *Database) SaveDeviceLink(ctx context.Context, l *DeviceLink) (*DeviceLink, error) {
+	_, err := db.db.ExecContext(ctx, "INSERT INTO device_link (device_id, network_device_id, status, updated_at) VALUES (?, ?, ?, ?) "+
+		"ON CONFLICT(device_id, network_device_id) DO UPDATE SET status=excluded.status, updated_at=excluded.updated_at",
+		l.DeviceID, l.NetworkDeviceID, l.Status, nullTime(l.UpdatedAt))
+	if err != nil {
+		db.logger.Error("unable to save device link", zap.String("device_id", l.DeviceID), zap.String("network_device_id", l.NetworkDeviceID), zap.Error(err))
+		return nil, mapError(err)
+	}
+	return l, nil
+}
+
+// GetDeviceLinks retrieves all confirmed and rejected device links.
+func (db *Database) GetDeviceLinks(ctx context.Context) ([]DeviceLink, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT device_id,network_device_id,status,updated_at FROM device_link ORDER BY device_id,network_device_id")
+	if err != nil {
+		db.logger.Error("unable to get device links", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []DeviceLink
+	for rows.Next() {
+		link := DeviceLink{}
+		var updatedAt sql.NullInt64
+		if err := rows.Scan(&link.DeviceID, &link.NetworkDeviceID, &link.Status, &updatedAt); err != nil {
+			db.logger.Error("unable to scan device link", zap.Error(err))
+			return nil, err
+		}
+		if updatedAt.Valid {
+			link.UpdatedAt = time.Unix(updatedAt.Int64, 0)
+		}
+		ret = append(ret, link)
+	}
+	return ret, rows.Err()
+}
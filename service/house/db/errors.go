@@ -0,0 +1,47 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// These errors are returned by the database when a change can't be made because of the data already present.
+var (
+	// ErrNotFound is returned when the record being changed doesn't exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrAlreadyExists is returned when a record is created with the same ID as an existing record.
+	ErrAlreadyExists = errors.New("record already exists")
+	// ErrInvalidReference is returned when a record refers to a record which doesn't exist,
+	// or when a record is removed while other records still refer to it.
+	ErrInvalidReference = errors.New("record references an invalid record")
+	// ErrNotSupported is returned when an operation can't be performed by the database engine in use.
+	ErrNotSupported = errors.New("operation not supported by this database")
+)
+
+// mapError converts constraint violations reported by the driver into one of the errors above.
+// Any other error is returned unchanged.
+func mapError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.ExtendedCode {
+		case sqlite3.ErrConstraintForeignKey:
+			return ErrInvalidReference
+		case sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintUnique:
+			return ErrAlreadyExists
+		}
+		return err
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "foreign_key_violation":
+			return ErrInvalidReference
+		case "unique_violation":
+			return ErrAlreadyExists
+		}
+	}
+	return err
+}
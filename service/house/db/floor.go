@@ -0,0 +1,12 @@
+package db
+
+// Floor describes a single storey of a building, which contains a set of rooms.
+type Floor struct {
+	ID         string
+	BuildingID string
+	Name       string
+	// Level is the position of the floor in the building; 0 is the ground floor and basements are negative.
+	Level int
+
+	Rooms []Room
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// dialect identifies the database engine the statements are run against.
+type dialect int
+
+const (
+	dialectSQLite dialect = iota
+	dialectPostgres
+)
+
+// rebind converts the ? placeholders used in the statements of this package into the form used by the dialect.
+// Question marks inside quoted strings are left alone.
+func (d dialect) rebind(query string) string {
+	if d != dialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	quoted := false
+	count := 0
+	for _, c := range query {
+		if c == '\'' {
+			quoted = !quoted
+		} else if c == '?' && !quoted {
+			count++
+			b.WriteString("$" + strconv.Itoa(count))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// handle wraps the database connection so the statements of this package run on each supported engine.
+type handle struct {
+	*sql.DB
+	dialect dialect
+}
+
+func (h *handle) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return h.DB.ExecContext(ctx, h.dialect.rebind(query), args...)
+}
+
+func (h *handle) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return h.DB.QueryContext(ctx, h.dialect.rebind(query), args...)
+}
+
+func (h *handle) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return h.DB.QueryRowContext(ctx, h.dialect.rebind(query), args...)
+}
+
+func (h *handle) BeginTx(ctx context.Context, opts *sql.TxOptions) (*txHandle, error) {
+	tx, err := h.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &txHandle{
+		Tx:      tx,
+		dialect: h.dialect,
+	}, nil
+}
+
+// txHandle wraps a transaction in the same way as handle.
+type txHandle struct {
+	*sql.Tx
+	dialect dialect
+}
+
+func (t *txHandle) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.Tx.ExecContext(ctx, t.dialect.rebind(query), args...)
+}
+
+func (t *txHandle) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.Tx.QueryContext(ctx, t.dialect.rebind(query), args...)
+}
+
+func (t *txHandle) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return t.Tx.QueryRowContext(ctx, t.dialect.rebind(query), args...)
+}
@@ -0,0 +1,372 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// LayoutAction describes how a record is changed when a layout is applied.
+type LayoutAction int
+
+const (
+	LayoutCreate LayoutAction = iota + 1
+	LayoutUpdate
+	LayoutDelete
+)
+
+// The kinds of records which are changed when a layout is applied.
+const (
+	LayoutKindBuilding = "building"
+	LayoutKindFloor    = "floor"
+	LayoutKindRoom     = "room"
+	LayoutKindDevice   = "device"
+)
+
+// LayoutChange describes a single change made when applying a layout.
+type LayoutChange struct {
+	Action LayoutAction
+	Kind   string
+	// Path names the changed record, i.e. "Home/Ground" for a floor or "Home/Kitchen" for a room. Devices are named by their ID.
+	Path string
+}
+
+// LayoutBuilding describes the desired state of a building and everything in it.
+// Buildings are matched by name, floors by name within their building, rooms by name within their building and devices by ID.
+// The IDs and building references of the contained floors and rooms are ignored.
+type LayoutBuilding struct {
+	Building
+
+	Floors []Floor
+	// Rooms are the rooms in the building which aren't on a floor.
+	Rooms []Room
+}
+
+// GetLayout retrieves every building along with its floors, rooms and the devices linked to those rooms.
+// Rooms are ordered by name and devices by ID so the layout can be compared between calls.
+func (db *Database) GetLayout(ctx context.Context) ([]LayoutBuilding, error) {
+	buildings, err := db.GetBuildings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(buildings, func(i, j int) bool { return buildings[i].Name < buildings[j].Name })
+
+	var ret []LayoutBuilding
+	for _, building := range buildings {
+		floors, err := db.GetBuildingFloors(ctx, building.ID)
+		if err != nil {
+			return nil, err
+		}
+		rooms, err := db.GetBuildingRooms(ctx, building.ID)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+
+		layout := LayoutBuilding{
+			Building: building,
+			Floors:   floors,
+		}
+		for _, room := range rooms {
+			sort.Slice(room.Devices, func(i, j int) bool { return room.Devices[i].ID < room.Devices[j].ID })
+
+			if idx := slices.IndexFunc(layout.Floors, func(f Floor) bool { return f.ID == room.FloorID }); idx >= 0 {
+				layout.Floors[idx].Rooms = append(layout.Floors[idx].Rooms, room)
+			} else {
+				layout.Rooms = append(layout.Rooms, room)
+			}
+		}
+		ret = append(ret, layout)
+	}
+	return ret, nil
+}
+
+// ApplyLayout changes the stored buildings to match the supplied layout, and returns the changes which were made.
+// Records which aren't in the layout are removed. The layout is applied in a single transaction; if dryRun is set
+// the transaction is rolled back so the returned changes describe what would be done.
+// Applying the same layout again makes no further changes.
+func (db *Database) ApplyLayout(ctx context.Context, buildings []LayoutBuilding, dryRun bool) ([]LayoutChange, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.Error("unable to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	a := &layoutApplier{
+		tx:        tx,
+		buildings: map[string]bool{},
+		floors:    map[string]bool{},
+		rooms:     map[string]bool{},
+		devices:   map[string]bool{},
+	}
+	if err := a.apply(ctx, buildings); err != nil {
+		db.logger.Error("unable to apply layout", zap.Error(err))
+		return nil, err
+	}
+
+	if dryRun {
+		return a.changes, nil
+	}
+	if err := tx.Commit(); err != nil {
+		db.logger.Error("unable to commit layout", zap.Error(err))
+		return nil, err
+	}
+	return a.changes, nil
+}
+
+// layoutApplier tracks the records which are kept while a layout is applied, so the rest can be removed afterwards.
+type layoutApplier struct {
+	tx      *txHandle
+	changes []LayoutChange
+
+	buildings map[string]bool
+	floors    map[string]bool
+	rooms     map[string]bool
+	devices   map[string]bool
+
+	// metadata contains the devices whose tags or aliases need to be replaced.
+	metadata []Device
+}
+
+func (a *layoutApplier) record(action LayoutAction, kind string, path string) {
+	a.changes = append(a.changes, LayoutChange{
+		Action: action,
+		Kind:   kind,
+		Path:   path,
+	})
+}
+
+func (a *layoutApplier) apply(ctx context.Context, buildings []LayoutBuilding) error {
+	existing := map[string]Building{}
+	rows, err := a.tx.QueryContext(ctx, "SELECT id,name,tz,lat,lon FROM building")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		building := Building{}
+		if err := rows.Scan(&building.ID, &building.Name, &building.TZ, &building.Location.Latitude, &building.Location.Longitude); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[building.Name] = building
+	}
+	rows.Close()
+
+	for _, building := range buildings {
+		current, found := existing[building.Name]
+		if !found {
+			current = building.Building
+			current.ID = uuid.NewString()
+			if _, err := a.tx.ExecContext(ctx, "INSERT INTO building (id, name, tz, lat, lon) VALUES (?, ?, ?, ?, ?)", current.ID, current.Name, current.TZ, current.Location.Latitude, current.Location.Longitude); err != nil {
+				return mapError(err)
+			}
+			a.record(LayoutCreate, LayoutKindBuilding, building.Name)
+		} else if current.TZ != building.TZ || current.Location != building.Location {
+			if _, err := a.tx.ExecContext(ctx, "UPDATE building SET tz=?,lat=?,lon=? WHERE id=?", building.TZ, building.Location.Latitude, building.Location.Longitude, current.ID); err != nil {
+				return mapError(err)
+			}
+			a.record(LayoutUpdate, LayoutKindBuilding, building.Name)
+		}
+		a.buildings[current.ID] = true
+
+		if err := a.applyBuilding(ctx, current.ID, building); err != nil {
+			return err
+		}
+	}
+
+	if err := a.applyDeviceMetadata(ctx); err != nil {
+		return err
+	}
+	return a.removeUnused(ctx)
+}
+
+func (a *layoutApplier) applyBuilding(ctx context.Context, buildingID string, building LayoutBuilding) error {
+	floors := map[string]Floor{}
+	rows, err := a.tx.QueryContext(ctx, "SELECT id,name,level FROM floor WHERE building_id=?", buildingID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		floor := Floor{}
+		if err := rows.Scan(&floor.ID, &floor.Name, &floor.Level); err != nil {
+			rows.Close()
+			return err
+		}
+		floors[floor.Name] = floor
+	}
+	rows.Close()
+
+	for _, floor := range building.Floors {
+		path := building.Name + "/" + floor.Name
+		current, found := floors[floor.Name]
+		if !found {
+			current = floor
+			current.ID = uuid.NewString()
+			if _, err := a.tx.ExecContext(ctx, "INSERT INTO floor (id, building_id, name, level) VALUES (?, ?, ?, ?)", current.ID, buildingID, floor.Name, floor.Level); err != nil {
+				return mapError(err)
+			}
+			a.record(LayoutCreate, LayoutKindFloor, path)
+		} else if current.Level != floor.Level {
+			if _, err := a.tx.ExecContext(ctx, "UPDATE floor SET level=? WHERE id=?", floor.Level, current.ID); err != nil {
+				return mapError(err)
+			}
+			a.record(LayoutUpdate, LayoutKindFloor, path)
+		}
+		a.floors[current.ID] = true
+
+		for _, room := range floor.Rooms {
+			if err := a.applyRoom(ctx, buildingID, current.ID, building.Name, room); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, room := range building.Rooms {
+		if err := a.applyRoom(ctx, buildingID, "", building.Name, room); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *layoutApplier) applyRoom(ctx context.Context, buildingID string, floorID string, buildingName string, room Room) error {
+	path := buildingName + "/" + room.Name
+
+	current := Room{}
+	var currentFloorID sql.NullString
+	row := a.tx.QueryRowContext(ctx, "SELECT id,floor_id,type FROM room WHERE building_id=? AND name=?", buildingID, room.Name)
+	if err := row.Scan(&current.ID, &currentFloorID, &current.Type); err == sql.ErrNoRows {
+		current.ID = uuid.NewString()
+		if _, err := a.tx.ExecContext(ctx, "INSERT INTO room (id, building_id, floor_id, name, type) VALUES (?, ?, ?, ?, ?)", current.ID, buildingID, nullString(floorID), room.Name, room.Type); err != nil {
+			return mapError(err)
+		}
+		a.record(LayoutCreate, LayoutKindRoom, path)
+	} else if err != nil {
+		return err
+	} else if currentFloorID.String != floorID || current.Type != room.Type {
+		if _, err := a.tx.ExecContext(ctx, "UPDATE room SET floor_id=?,type=? WHERE id=?", nullString(floorID), room.Type, current.ID); err != nil {
+			return mapError(err)
+		}
+		a.record(LayoutUpdate, LayoutKindRoom, path)
+	}
+	a.rooms[current.ID] = true
+
+	for _, device := range room.Devices {
+		if err := a.applyDevice(ctx, current.ID, device); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *layoutApplier) applyDevice(ctx context.Context, roomID string, device Device) error {
+	a.devices[device.ID] = true
+
+	var currentRoomID string
+	linkChanged := true
+	row := a.tx.QueryRowContext(ctx, "SELECT room_id FROM device_room WHERE id=?", device.ID)
+	if err := row.Scan(&currentRoomID); err == sql.ErrNoRows {
+		if _, err := a.tx.ExecContext(ctx, "INSERT INTO device_room (id, room_id) VALUES (?, ?)", device.ID, roomID); err != nil {
+			return mapError(err)
+		}
+		a.record(LayoutCreate, LayoutKindDevice, device.ID)
+	} else if err != nil {
+		return err
+	} else if currentRoomID != roomID {
+		if _, err := a.tx.ExecContext(ctx, "UPDATE device_room SET room_id=? WHERE id=?", roomID, device.ID); err != nil {
+			return mapError(err)
+		}
+		a.record(LayoutUpdate, LayoutKindDevice, device.ID)
+	} else {
+		linkChanged = false
+	}
+
+	current := &Device{ID: device.ID}
+	if err := loadDeviceMetadata(ctx, a.tx, []*Device{current}); err != nil {
+		return err
+	}
+	if !sameValues(current.Tags, device.Tags) || !sameValues(current.Aliases, device.Aliases) {
+		a.metadata = append(a.metadata, device)
+		if !linkChanged {
+			a.record(LayoutUpdate, LayoutKindDevice, device.ID)
+		}
+	}
+	return nil
+}
+
+// applyDeviceMetadata replaces the tags and aliases of the changed devices.
+// All of the old values are removed first so aliases can be moved between devices.
+func (a *layoutApplier) applyDeviceMetadata(ctx context.Context) error {
+	for _, device := range a.metadata {
+		if err := clearDeviceMetadata(ctx, a.tx, device.ID); err != nil {
+			return err
+		}
+	}
+	for _, device := range a.metadata {
+		if err := insertDeviceMetadata(ctx, a.tx, &device); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeUnused deletes the device links, rooms, floors and buildings which weren't in the applied layout.
+// Device metadata is kept, as it is when a device is unlinked.
+func (a *layoutApplier) removeUnused(ctx context.Context) error {
+	steps := []struct {
+		kind   string
+		query  string
+		kept   map[string]bool
+		remove func(ctx context.Context, e execer, id string) error
+	}{
+		{LayoutKindDevice, "SELECT id,id FROM device_room ORDER BY id", a.devices, func(ctx context.Context, e execer, id string) error {
+			return execOne(ctx, e, "DELETE FROM device_room WHERE id=?", id)
+		}},
+		{LayoutKindRoom, "SELECT room.id,building.name || '/' || room.name FROM room JOIN building ON room.building_id=building.id ORDER BY 2", a.rooms, deleteRoom},
+		{LayoutKindFloor, "SELECT floor.id,building.name || '/' || floor.name FROM floor JOIN building ON floor.building_id=building.id ORDER BY 2", a.floors, deleteFloor},
+		{LayoutKindBuilding, "SELECT id,name FROM building ORDER BY name", a.buildings, deleteBuilding},
+	}
+
+	for _, step := range steps {
+		rows, err := a.tx.QueryContext(ctx, step.query)
+		if err != nil {
+			return err
+		}
+
+		var ids, paths []string
+		for rows.Next() {
+			var id, path string
+			if err := rows.Scan(&id, &path); err != nil {
+				rows.Close()
+				return err
+			}
+			if !step.kept[id] {
+				ids = append(ids, id)
+				paths = append(paths, path)
+			}
+		}
+		rows.Close()
+
+		for idx, id := range ids {
+			if err := step.remove(ctx, a.tx, id); err != nil {
+				return err
+			}
+			a.record(LayoutDelete, step.kind, paths[idx])
+		}
+	}
+	return nil
+}
+
+// sameValues checks whether the two sets contain the same values, ignoring their order.
+func sameValues(a []string, b []string) bool {
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,24 @@
+-- SQLite can't drop a column with a foreign key in every version, so the room table is rebuilt without it.
+-- Rooms are referenced by other tables; their checks are deferred until the rebuilt table holds the same rooms.
+PRAGMA defer_foreign_keys = ON;
+
+CREATE TABLE room_copy(
+    id TEXT PRIMARY KEY,
+    building_id TEXT,
+    name TEXT,
+    type INT
+);
+INSERT INTO room_copy (id, building_id, name, type) SELECT id, building_id, name, type FROM room;
+DROP TABLE room;
+
+CREATE TABLE room(
+    id TEXT PRIMARY KEY,
+    building_id TEXT,
+    name TEXT,
+    type INT,
+    FOREIGN KEY(building_id) REFERENCES building(id)
+);
+INSERT INTO room (id, building_id, name, type) SELECT id, building_id, name, type FROM room_copy;
+DROP TABLE room_copy;
+
+DROP TABLE floor;
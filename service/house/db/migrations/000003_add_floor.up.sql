@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS floor(
+    id TEXT PRIMARY KEY,
+    building_id TEXT,
+    name TEXT,
+    level INT,
+    FOREIGN KEY(building_id) REFERENCES building(id)
+);
+
+ALTER TABLE room ADD COLUMN floor_id TEXT REFERENCES floor(id);
@@ -0,0 +1 @@
+-- The orphaned rows removed by the up migration can not be restored.
@@ -0,0 +1,5 @@
+-- Foreign keys are now enforced; remove any rows left behind by earlier deletes which would violate them.
+DELETE FROM device_room WHERE room_id IS NULL OR room_id NOT IN (SELECT room.id FROM room JOIN building ON room.building_id=building.id);
+UPDATE room SET floor_id=NULL WHERE floor_id IS NOT NULL AND floor_id NOT IN (SELECT floor.id FROM floor JOIN building ON floor.building_id=building.id);
+DELETE FROM room WHERE building_id IS NULL OR building_id NOT IN (SELECT id FROM building);
+DELETE FROM floor WHERE building_id IS NULL OR building_id NOT IN (SELECT id FROM building);
@@ -0,0 +1,2 @@
+DROP TABLE zone_room;
+DROP TABLE zone;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS zone(
+    id TEXT PRIMARY KEY,
+    name TEXT
+);
+
+CREATE TABLE IF NOT EXISTS zone_room(
+    zone_id TEXT,
+    room_id TEXT,
+    PRIMARY KEY(zone_id, room_id),
+    FOREIGN KEY(zone_id) REFERENCES zone(id),
+    FOREIGN KEY(room_id) REFERENCES room(id)
+);
@@ -0,0 +1,3 @@
+DROP INDEX device_alias_device_id;
+DROP TABLE device_alias;
+DROP TABLE device_tag;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS device_tag(
+    device_id TEXT,
+    tag TEXT,
+    PRIMARY KEY(device_id, tag)
+);
+
+CREATE TABLE IF NOT EXISTS device_alias(
+    alias TEXT PRIMARY KEY COLLATE NOCASE,
+    device_id TEXT
+);
+CREATE INDEX IF NOT EXISTS device_alias_device_id ON device_alias(device_id);
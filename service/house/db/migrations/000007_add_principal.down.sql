@@ -0,0 +1,3 @@
+DROP TABLE principal_device_type;
+DROP TABLE principal_room;
+DROP TABLE principal;
\ No newline at end of file
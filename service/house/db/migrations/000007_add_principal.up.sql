@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS principal(
+    id TEXT PRIMARY KEY,
+    subject TEXT UNIQUE,
+    token_hash TEXT UNIQUE,
+    description TEXT,
+    role INTEGER,
+    expires_at BIGINT
+);
+
+-- Room IDs are kept when the room is deleted so the principal doesn't gain control of every room.
+CREATE TABLE IF NOT EXISTS principal_room(
+    principal_id TEXT,
+    room_id TEXT,
+    PRIMARY KEY(principal_id, room_id),
+    FOREIGN KEY(principal_id) REFERENCES principal(id)
+);
+
+CREATE TABLE IF NOT EXISTS principal_device_type(
+    principal_id TEXT,
+    device_type TEXT,
+    PRIMARY KEY(principal_id, device_type),
+    FOREIGN KEY(principal_id) REFERENCES principal(id)
+);
@@ -0,0 +1 @@
+DROP TABLE bridge;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- The principal isn't a foreign key so the bridge record can outlive an administrator deleting it.
+CREATE TABLE IF NOT EXISTS bridge(
+    id TEXT PRIMARY KEY,
+    address TEXT,
+    name TEXT,
+    token TEXT,
+    principal_id TEXT,
+    paired_at BIGINT
+);
@@ -0,0 +1 @@
+DROP TABLE device_link;
\ No newline at end of file
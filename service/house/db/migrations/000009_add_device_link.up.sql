@@ -0,0 +1,8 @@
+-- Devices are reported by the bridges rather than stored, so neither ID is a foreign key.
+CREATE TABLE IF NOT EXISTS device_link(
+    device_id TEXT,
+    network_device_id TEXT,
+    status INTEGER,
+    updated_at BIGINT,
+    PRIMARY KEY(device_id, network_device_id)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE device_alias_copy(
+    alias TEXT PRIMARY KEY COLLATE NOCASE,
+    device_id TEXT
+);
+INSERT INTO device_alias_copy (alias, device_id) SELECT alias, device_id FROM device_alias;
+DROP TABLE device_alias;
+ALTER TABLE device_alias_copy RENAME TO device_alias;
+CREATE INDEX IF NOT EXISTS device_alias_device_id ON device_alias(device_id);
+
+ALTER TABLE building ADD COLUMN lat_real REAL;
+ALTER TABLE building ADD COLUMN lon_real REAL;
+UPDATE building SET lat_real=lat, lon_real=lon;
+ALTER TABLE building DROP COLUMN lat;
+ALTER TABLE building DROP COLUMN lon;
+ALTER TABLE building RENAME COLUMN lat_real TO lat;
+ALTER TABLE building RENAME COLUMN lon_real TO lon;
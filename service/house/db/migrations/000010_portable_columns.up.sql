@@ -0,0 +1,19 @@
+-- Building coordinates were declared REAL, which PostgreSQL stores in single precision.
+ALTER TABLE building ADD COLUMN lat_double DOUBLE PRECISION;
+ALTER TABLE building ADD COLUMN lon_double DOUBLE PRECISION;
+UPDATE building SET lat_double=lat, lon_double=lon;
+ALTER TABLE building DROP COLUMN lat;
+ALTER TABLE building DROP COLUMN lon;
+ALTER TABLE building RENAME COLUMN lat_double TO lat;
+ALTER TABLE building RENAME COLUMN lon_double TO lon;
+
+-- Aliases are kept unique ignoring case with an index on lower(alias), rather than SQLite's NOCASE collation.
+CREATE TABLE device_alias_copy(
+    alias TEXT PRIMARY KEY,
+    device_id TEXT
+);
+INSERT INTO device_alias_copy (alias, device_id) SELECT alias, device_id FROM device_alias;
+DROP TABLE device_alias;
+ALTER TABLE device_alias_copy RENAME TO device_alias;
+CREATE UNIQUE INDEX IF NOT EXISTS device_alias_lower ON device_alias(lower(alias));
+CREATE INDEX IF NOT EXISTS device_alias_device_id ON device_alias(device_id);
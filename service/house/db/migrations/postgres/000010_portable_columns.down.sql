@@ -0,0 +1,4 @@
+DROP INDEX device_alias_lower;
+
+ALTER TABLE building ALTER COLUMN lat TYPE REAL;
+ALTER TABLE building ALTER COLUMN lon TYPE REAL;
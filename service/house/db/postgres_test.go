@@ -0,0 +1,125 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// postgresTestDSN names the environment variable which may hold the URL of an existing PostgreSQL server to run the
+// tests against, such as postgres://postgres@localhost:5432/postgres?sslmode=disable, in place of launching one.
+// The user must be able to create databases.
+const postgresTestDSN = "HOUSE_TEST_POSTGRES_DSN"
+
+// postgresBinary locates a PostgreSQL server binary, preferring the directory set in POSTGRES_BIN_DIR.
+func postgresBinary(name string) (string, error) {
+	if dir := os.Getenv("POSTGRES_BIN_DIR"); len(dir) > 0 {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	return exec.LookPath(name)
+}
+
+// startPostgres launches a PostgreSQL server in a temporary directory for the duration of the test, returning its URL.
+// The test is skipped if the server binaries aren't available.
+func startPostgres(t *testing.T) string {
+	initdb, err := postgresBinary("initdb")
+	if err != nil {
+		t.Skip("initdb not found; set POSTGRES_BIN_DIR or " + postgresTestDSN + " to run the PostgreSQL tests")
+	}
+	postgres, err := postgresBinary("postgres")
+	if err != nil {
+		t.Skip("postgres not found; set POSTGRES_BIN_DIR or " + postgresTestDSN + " to run the PostgreSQL tests")
+	}
+
+	dataDir := t.TempDir()
+	out, err := exec.Command(initdb, "-D", dataDir, "-U", "postgres", "--auth=trust", "--no-sync").CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	server := exec.Command(postgres, "-D", dataDir, "-p", fmt.Sprint(port), "-c", "listen_addresses=127.0.0.1", "-k", dataDir, "-F")
+	require.NoError(t, server.Start())
+	t.Cleanup(func() {
+		server.Process.Signal(os.Interrupt)
+		server.Wait()
+	})
+	return fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+}
+
+// TestPostgres runs the database tests against a PostgreSQL server, with each test using a new database.
+// The server is launched from the local binaries unless one is set in HOUSE_TEST_POSTGRES_DSN.
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv(postgresTestDSN)
+	if len(dsn) < 1 {
+		dsn = startPostgres(t)
+	}
+	serverURL, err := url.Parse(dsn)
+	require.NoError(t, err)
+
+	admin, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer admin.Close()
+
+	// A launched server takes a moment to start accepting connections.
+	deadline := time.Now().Add(30 * time.Second)
+	for err = admin.Ping(); err != nil && time.Now().Before(deadline); err = admin.Ping() {
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.NoError(t, err)
+
+	count := 0
+	newTestDatabase = func(t *testing.T) *Database {
+		count++
+		name := fmt.Sprintf("house_test_%d_%d", os.Getpid(), count)
+		_, err := admin.Exec("CREATE DATABASE " + name)
+		require.NoError(t, err)
+		t.Cleanup(func() { admin.Exec("DROP DATABASE " + name) })
+
+		dbURL := *serverURL
+		dbURL.Path = "/" + name
+		sqlDB, err := sql.Open("postgres", dbURL.String())
+		require.NoError(t, err)
+		t.Cleanup(func() { sqlDB.Close() })
+
+		db, err := NewPostgresDatabase(zaptest.NewLogger(t), sqlDB)
+		require.NoError(t, err)
+		return db
+	}
+	defer func() { newTestDatabase = newSQLiteTestDatabase }()
+
+	t.Run("CreateRoomMissingBuilding", TestCreateRoomMissingBuilding)
+	t.Run("DeleteRoomRemovesDeviceLinks", TestDeleteRoomRemovesDeviceLinks)
+	t.Run("DeleteBuildingCascades", TestDeleteBuildingCascades)
+	t.Run("Floors", TestFloors)
+	t.Run("MigrationsRoundTrip", TestMigrationsRoundTrip)
+	t.Run("ZoneRooms", TestZoneRooms)
+	t.Run("ZonesWithSameName", TestZonesWithSameName)
+	t.Run("SearchDevicesByZone", TestSearchDevicesByZone)
+	t.Run("DeviceMetadata", TestDeviceMetadata)
+	t.Run("Principals", TestPrincipals)
+	t.Run("Bridges", TestBridges)
+	t.Run("DeviceLinks", TestDeviceLinks)
+	t.Run("ApplyLayout", TestApplyLayout)
+	t.Run("PortableColumns", TestPortableColumns)
+}
+
+func TestRebind(t *testing.T) {
+	require.Equal(t, "SELECT a FROM b WHERE c=$1 AND d IN ($2,$3) AND e='?'", dialectPostgres.rebind("SELECT a FROM b WHERE c=? AND d IN (?,?) AND e='?'"))
+	require.Equal(t, "SELECT a FROM b WHERE c=?", dialectSQLite.rebind("SELECT a FROM b WHERE c=?"))
+}
@@ -0,0 +1,262 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Role describes what a principal is allowed to do.
+type Role int
+
+const (
+	RoleUnspecified Role = iota
+	// RoleReadOnly may retrieve state but not change it.
+	RoleReadOnly
+	// RoleControl may also control devices, limited by the rooms and device types of the principal.
+	RoleControl
+	// RoleAdmin may do anything.
+	RoleAdmin
+	// RoleBridge may read, and check the access of other principals.
+	RoleBridge
+)
+
+// Principal grants a client identity access to the house and its bridges.
+// A principal is identified by either the subject of its client certificate or the hash of its token.
+type Principal struct {
+	ID          string
+	Subject     string
+	TokenHash   string
+	Description string
+	Role        Role
+
+	// RoomIDs and DeviceTypes limit the devices which may be controlled; empty if there is no limit.
+	RoomIDs     []string
+	DeviceTypes []string
+
+	// ExpiresAt is when the principal stops being granted access; zero if it never expires.
+	ExpiresAt time.Time
+}
+
+// CreatePrincipal inserts a new principal along with the rooms and device types it is limited to.
+func (db *Database) CreatePrincipal(ctx context.Context, p *Principal) (*Principal, error) {
+	newID := uuid.NewString()
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.Error("unable to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO principal (id, subject, token_hash, description, role, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		newID, nullString(p.Subject), nullString(p.TokenHash), p.Description, p.Role, nullTime(p.ExpiresAt))
+	if err != nil {
+		db.logger.Error("unable to create principal", zap.Error(err))
+		return nil, mapError(err)
+	}
+	if err := insertPrincipalScopes(ctx, tx, newID, p); err != nil {
+		db.logger.Error("unable to add principal scopes", zap.String("principal_id", newID), zap.Error(err))
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		db.logger.Error("unable to commit principal", zap.String("principal_id", newID), zap.Error(err))
+		return nil, err
+	}
+
+	p.ID = newID
+	return p, nil
+}
+
+// UpdatePrincipal saves the description, role, expiry and scopes of the supplied principal.
+// The subject and token hash identifying the principal can't be changed.
+func (db *Database) UpdatePrincipal(ctx context.Context, p *Principal) (*Principal, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.Error("unable to begin transaction", zap.Error(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := execOne(ctx, tx, "UPDATE principal SET description=?, role=?, expires_at=? WHERE id=?", p.Description, p.Role, nullTime(p.ExpiresAt), p.ID); err != nil {
+		db.logger.Error("unable to update principal", zap.String("principal_id", p.ID), zap.Error(err))
+		return nil, err
+	}
+	if err := clearPrincipalScopes(ctx, tx, p.ID); err != nil {
+		db.logger.Error("unable to clear principal scopes", zap.String("principal_id", p.ID), zap.Error(err))
+		return nil, err
+	}
+	if err := insertPrincipalScopes(ctx, tx, p.ID, p); err != nil {
+		db.logger.Error("unable to add principal scopes", zap.String("principal_id", p.ID), zap.Error(err))
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		db.logger.Error("unable to commit principal", zap.String("principal_id", p.ID), zap.Error(err))
+		return nil, err
+	}
+
+	return db.GetPrincipal(ctx, p.ID)
+}
+
+// DeletePrincipal removes the specified principal.
+func (db *Database) DeletePrincipal(ctx context.Context, principalID string) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.Error("unable to begin transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := clearPrincipalScopes(ctx, tx, principalID); err != nil {
+		db.logger.Error("unable to clear principal scopes", zap.String("principal_id", principalID), zap.Error(err))
+		return err
+	}
+	if err := execOne(ctx, tx, "DELETE FROM principal WHERE id=?", principalID); err != nil {
+		db.logger.Error("unable to delete principal", zap.String("principal_id", principalID), zap.Error(err))
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetPrincipals retrieves all stored principals, including expired ones.
+func (db *Database) GetPrincipals(ctx context.Context) ([]Principal, error) {
+	principals, err := db.queryPrincipals(ctx, "1=1")
+	if err != nil {
+		db.logger.Error("unable to get principals", zap.Error(err))
+		return nil, err
+	}
+	return principals, nil
+}
+
+// GetPrincipal retrieves the specified principal.
+func (db *Database) GetPrincipal(ctx context.Context, principalID string) (*Principal, error) {
+	return db.getPrincipal(ctx, "id=?", principalID)
+}
+
+// GetPrincipalBySubject retrieves the principal identified by the specified client certificate subject.
+func (db *Database) GetPrincipalBySubject(ctx context.Context, subject string) (*Principal, error) {
+	return db.getPrincipal(ctx, "subject=?", subject)
+}
+
+// GetPrincipalByTokenHash retrieves the principal identified by the token with the specified hash.
+func (db *Database) GetPrincipalByTokenHash(ctx context.Context, tokenHash string) (*Principal, error) {
+	return db.getPrincipal(ctx, "token_hash=?", tokenHash)
+}
+
+func (db *Database) getPrincipal(ctx context.Context, condition string, arg string) (*Principal, error) {
+	principals, err := db.queryPrincipals(ctx, condition, arg)
+	if err != nil {
+		db.logger.Error("unable to retrieve principal", zap.Error(err))
+		return nil, err
+	} else if len(principals) < 1 {
+		return nil, nil
+	}
+	return &principals[0], nil
+}
+
+// queryPrincipals retrieves the principals matching the supplied condition, along with their scopes.
+func (db *Database) queryPrincipals(ctx context.Context, condition string, args ...any) ([]Principal, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id,subject,token_hash,description,role,expires_at FROM principal WHERE "+condition+" ORDER BY COALESCE(subject,''),description,id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []Principal
+	for rows.Next() {
+		principal := Principal{}
+		var subject, tokenHash sql.NullString
+		var expiresAt sql.NullInt64
+		if err := rows.Scan(&principal.ID, &subject, &tokenHash, &principal.Description, &principal.Role, &expiresAt); err != nil {
+			return nil, err
+		}
+		principal.Subject = subject.String
+		principal.TokenHash = tokenHash.String
+		if expiresAt.Valid {
+			principal.ExpiresAt = time.Unix(expiresAt.Int64, 0)
+		}
+		ret = append(ret, principal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var principals []*Principal
+	for idx := range ret {
+		principals = append(principals, &ret[idx])
+	}
+	if err := loadPrincipalScopes(ctx, db.db, principals); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func clearPrincipalScopes(ctx context.Context, e execer, principalID string) error {
+	if _, err := e.ExecContext(ctx, "DELETE FROM principal_room WHERE principal_id=?", principalID); err != nil {
+		return mapError(err)
+	}
+	if _, err := e.ExecContext(ctx, "DELETE FROM principal_device_type WHERE principal_id=?", principalID); err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+func insertPrincipalScopes(ctx context.Context, e execer, principalID string, p *Principal) error {
+	for _, roomID := range p.RoomIDs {
+		if _, err := e.ExecContext(ctx, "INSERT INTO principal_room (principal_id, room_id) VALUES (?, ?)", principalID, roomID); err != nil {
+			return mapError(err)
+		}
+	}
+	for _, deviceType := range p.DeviceTypes {
+		if _, err := e.ExecContext(ctx, "INSERT INTO principal_device_type (principal_id, device_type) VALUES (?, ?)", principalID, deviceType); err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+// loadPrincipalScopes populates the rooms and device types of the supplied principals.
+func loadPrincipalScopes(ctx context.Context, q querier, principals []*Principal) error {
+	if len(principals) < 1 {
+		return nil
+	}
+
+	byID := map[string]*Principal{}
+	var args []any
+	for _, principal := range principals {
+		byID[principal.ID] = principal
+		args = append(args, principal.ID)
+	}
+	placeholders := strings.Repeat(",?", len(args))[1:]
+
+	rows, err := q.QueryContext(ctx, "SELECT principal_id,room_id,'' FROM principal_room WHERE principal_id IN ("+placeholders+") UNION ALL SELECT principal_id,'',device_type FROM principal_device_type WHERE principal_id IN ("+placeholders+") ORDER BY 1,2,3", append(args, args...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var principalID, roomID, deviceType string
+		if err := rows.Scan(&principalID, &roomID, &deviceType); err != nil {
+			return err
+		}
+
+		principal := byID[principalID]
+		if len(roomID) > 0 {
+			principal.RoomIDs = append(principal.RoomIDs, roomID)
+		} else {
+			principal.DeviceTypes = append(principal.DeviceTypes, deviceType)
+		}
+	}
+	return rows.Err()
+}
+
+// nullTime converts an optional time into Unix seconds which are stored as NULL when the time is zero.
+func nullTime(t time.Time) sql.NullInt64 {
+	return sql.NullInt64{Int64: t.Unix(), Valid: !t.IsZero()}
+}
@@ -23,8 +23,10 @@ const (
 type Room struct {
 	ID         string
 	BuildingID string
-	Name       string
-	Type       RoomType
+	// FloorID is the floor the room is located on; empty if the room hasn't been assigned to a floor.
+	FloorID string
+	Name    string
+	Type    RoomType
 
 	Devices []Device
 }
@@ -0,0 +1,63 @@
+package db
+
+import "context"
+
+// Store describes the operations used by the house service to persist its state.
+// Database implements it for both SQLite and PostgreSQL.
+type Store interface {
+	CreateBuilding(ctx context.Context, b *Building) (*Building, error)
+	GetBuildings(ctx context.Context) ([]Building, error)
+	GetBuilding(ctx context.Context, buildingID string) (*Building, error)
+	UpdateBuilding(ctx context.Context, b *Building) (*Building, error)
+	DeleteBuilding(ctx context.Context, buildingID string) error
+
+	CreateRoom(ctx context.Context, r *Room) (*Room, error)
+	UpdateRoom(ctx context.Context, r *Room) (*Room, error)
+	DeleteRoom(ctx context.Context, roomID string) error
+	GetRoom(ctx context.Context, roomID string) (*Room, error)
+	GetRoomWithDevices(ctx context.Context, roomID string) (*Room, error)
+	GetBuildingRooms(ctx context.Context, buildingID string) ([]Room, error)
+
+	CreateFloor(ctx context.Context, f *Floor) (*Floor, error)
+	UpdateFloor(ctx context.Context, f *Floor) (*Floor, error)
+	DeleteFloor(ctx context.Context, floorID string) error
+	GetFloor(ctx context.Context, floorID string) (*Floor, error)
+	GetBuildingFloors(ctx context.Context, buildingID string) ([]Floor, error)
+
+	CreateZone(ctx context.Context, z *Zone) (*Zone, error)
+	UpdateZone(ctx context.Context, z *Zone) (*Zone, error)
+	DeleteZone(ctx context.Context, zoneID string) error
+	GetZones(ctx context.Context) ([]Zone, error)
+	GetZone(ctx context.Context, zoneID string) (*Zone, error)
+	GetZoneRooms(ctx context.Context, zoneID string) ([]Room, error)
+
+	CreateDevice(ctx context.Context, deviceID string, room Room) (*Device, error)
+	DeleteDevice(ctx context.Context, deviceID string) error
+	GetDevice(ctx context.Context, deviceID string) (*Device, error)
+	UpdateDeviceMetadata(ctx context.Context, d *Device) (*Device, error)
+	SearchDevices(ctx context.Context, filter DeviceFilter) ([]Device, error)
+
+	CreatePrincipal(ctx context.Context, p *Principal) (*Principal, error)
+	UpdatePrincipal(ctx context.Context, p *Principal) (*Principal, error)
+	DeletePrincipal(ctx context.Context, principalID string) error
+	GetPrincipals(ctx context.Context) ([]Principal, error)
+	GetPrincipal(ctx context.Context, principalID string) (*Principal, error)
+	GetPrincipalBySubject(ctx context.Context, subject string) (*Principal, error)
+	GetPrincipalByTokenHash(ctx context.Context, tokenHash string) (*Principal, error)
+
+	SaveBridge(ctx context.Context, b *Bridge) (*Bridge, error)
+	DeleteBridge(ctx context.Context, bridgeID string) error
+	GetBridges(ctx context.Context) ([]Bridge, error)
+	GetBridge(ctx context.Context, bridgeID string) (*Bridge, error)
+
+	SaveDeviceLink(ctx context.Context, l *DeviceLink) (*DeviceLink, error)
+	GetDeviceLinks(ctx context.Context) ([]DeviceLink, error)
+
+	GetLayout(ctx context.Context) ([]LayoutBuilding, error)
+	ApplyLayout(ctx context.Context, buildings []LayoutBuilding, dryRun bool) ([]LayoutChange, error)
+
+	// Backup writes a consistent copy of the store to the specified path, or returns ErrNotSupported.
+	Backup(ctx context.Context, path string) error
+}
+
+var _ Store = (*Database)(nil)
@@ -0,0 +1,10 @@
+package db
+
+// Zone describes a named grouping of rooms, such as "upstairs" or "outdoors".
+// The rooms in a zone may be on different floors or in different buildings.
+type Zone struct {
+	ID   string
+	Name string
+
+	RoomIDs []string
+}
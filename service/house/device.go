@@ -0,0 +1,93 @@
+package house
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/service/house/db"
+)
+
+func (s *Service) UpdateDeviceMetadata(ctx context.Context, req *api2.UpdateDeviceMetadataRequest) (*api2.DeviceMetadata, error) {
+	if len(req.DeviceId) < 1 {
+		return nil, status.Error(codes.InvalidArgument, "device ID is required")
+	}
+
+	device := &db.Device{
+		ID: req.DeviceId,
+	}
+	if req.Metadata != nil {
+		device.Tags = normalizeValues(req.Metadata.Tags, true)
+		device.Aliases = normalizeValues(req.Metadata.Aliases, false)
+	}
+
+	device, err := s.db.UpdateDeviceMetadata(ctx, device)
+	if err != nil {
+		s.logger.Error("unable to update device metadata", zap.String("device_id", req.DeviceId), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to update device metadata")
+	}
+	return deviceMetadataDBToAPI(*device), nil
+}
+
+func (s *Service) SearchDevices(ctx context.Context, req *api2.SearchDevicesRequest) (*api2.SearchDevicesResponse, error) {
+	if len(req.ZoneId) > 0 {
+		zone, err := s.db.GetZone(ctx, req.ZoneId)
+		if err != nil {
+			s.logger.Error("unable to get zone", zap.String("zone_id", req.ZoneId), zap.Error(err))
+			return nil, status.Error(codes.Internal, "unable to get zone")
+		} else if zone == nil {
+			return nil, status.Error(codes.NotFound, "zone doesn't exist")
+		}
+	}
+
+	devices, err := s.db.SearchDevices(ctx, db.DeviceFilter{
+		Tag:    strings.ToLower(strings.TrimSpace(req.Tag)),
+		Alias:  strings.TrimSpace(req.Alias),
+		RoomID: req.RoomId,
+		ZoneID: req.ZoneId,
+	})
+	if err != nil {
+		s.logger.Error("unable to search devices", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to search devices")
+	}
+
+	ret := &api2.SearchDevicesResponse{}
+	for _, device := range devices {
+		ret.Results = append(ret.Results, &api2.SearchDevicesResponse_Result{
+			Device:   deviceToAPI(device),
+			RoomId:   device.RoomID,
+			Metadata: deviceMetadataDBToAPI(device),
+		})
+	}
+	return ret, nil
+}
+
+// normalizeValues trims the supplied values, dropping empty and duplicated entries.
+// Tags are compared and stored in lower case; aliases keep their case but are compared ignoring it.
+func normalizeValues(values []string, lower bool) []string {
+	var ret []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if lower {
+			value = strings.ToLower(value)
+		}
+		if len(value) < 1 || seen[strings.ToLower(value)] {
+			continue
+		}
+		seen[strings.ToLower(value)] = true
+		ret = append(ret, value)
+	}
+	return ret
+}
+
+func deviceMetadataDBToAPI(device db.Device) *api2.DeviceMetadata {
+	return &api2.DeviceMetadata{
+		Tags:    device.Tags,
+		Aliases: device.Aliases,
+	}
+}
@@ -0,0 +1,363 @@
+package house
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+	apiDevice "github.com/rmrobinson/house/api/device"
+	"github.com/rmrobinson/house/api/trait"
+	"github.com/rmrobinson/house/service/house/db"
+)
+
+// The kinds of address devices are matched on, as reported in DeviceLink.matched_on.
+const (
+	matchHardwareAddress = "hardware_address"
+	matchIPAddress       = "ip_address"
+	matchHostname        = "hostname"
+)
+
+// bridgeDevicesTimeout limits how long each bridge is given to list its devices.
+const bridgeDevicesTimeout = 5 * time.Second
+
+var linkStatuses = map[db.LinkStatus]api2.DeviceLink_Status{
+	db.LinkConfirmed: api2.DeviceLink_STATUS_CONFIRMED,
+	db.LinkRejected:  api2.DeviceLink_STATUS_REJECTED,
+}
+
+// bridgeDevice is a device reported by one of the paired bridges.
+type bridgeDevice struct {
+	bridgeID string
+	device   *apiDevice.Device
+}
+
+// linkKey identifies a link by the device and the connected device it joins.
+type linkKey struct {
+	deviceID        string
+	networkDeviceID string
+}
+
+func (s *BridgeService) ListLogicalDevices(ctx context.Context, req *api2.ListLogicalDevicesRequest) (*api2.ListLogicalDevicesResponse, error) {
+	devices, err := s.bridgeDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.getDeviceLinks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[string]bridgeDevice{}
+	for _, d := range devices {
+		byID[d.device.Id] = d
+	}
+
+	// Connected devices are folded into the device they are linked to, if that device was reported.
+	linked := map[string][]string{}
+	folded := map[string]bool{}
+	for key, link := range links {
+		if link.Status != db.LinkConfirmed {
+			continue
+		} else if _, found := byID[key.deviceID]; !found {
+			continue
+		}
+		linked[key.deviceID] = append(linked[key.deviceID], key.networkDeviceID)
+		folded[key.networkDeviceID] = true
+	}
+
+	ret := &api2.ListLogicalDevicesResponse{}
+	for _, d := range devices {
+		if folded[d.device.Id] {
+			continue
+		}
+
+		logical := &api2.LogicalDevice{
+			Device:   d.device,
+			BridgeId: d.bridgeID,
+		}
+		sort.Strings(linked[d.device.Id])
+		for _, networkDeviceID := range linked[d.device.Id] {
+			logical.LinkedDeviceIds = append(logical.LinkedDeviceIds, networkDeviceID)
+			if presence := networkPresence(byID[networkDeviceID].device); presence != nil && logical.NetworkPresence == nil {
+				logical.NetworkPresence = presence
+			}
+		}
+		ret.Devices = append(ret.Devices, logical)
+	}
+	return ret, nil
+}
+
+func (s *BridgeService) ListDeviceLinks(ctx context.Context, req *api2.ListDeviceLinksRequest) (*api2.ListDeviceLinksResponse, error) {
+	devices, err := s.bridgeDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.getDeviceLinks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchDevices(devices)
+	keys := map[linkKey]bool{}
+	for key := range matches {
+		keys[key] = true
+	}
+	for key := range links {
+		keys[key] = true
+	}
+
+	ret := &api2.ListDeviceLinksResponse{}
+	for key := range keys {
+		link := &api2.DeviceLink{
+			DeviceId:        key.deviceID,
+			NetworkDeviceId: key.networkDeviceID,
+			Status:          api2.DeviceLink_STATUS_SUGGESTED,
+			MatchedOn:       matches[key],
+		}
+		if stored, found := links[key]; found {
+			link.Status = linkStatuses[stored.Status]
+		}
+
+		if req.Status != api2.DeviceLink_STATUS_UNSPECIFIED && link.Status != req.Status {
+			continue
+		}
+		ret.Links = append(ret.Links, link)
+	}
+	sort.Slice(ret.Links, func(i, j int) bool {
+		if ret.Links[i].DeviceId != ret.Links[j].DeviceId {
+			return ret.Links[i].DeviceId < ret.Links[j].DeviceId
+		}
+		return ret.Links[i].NetworkDeviceId < ret.Links[j].NetworkDeviceId
+	})
+	return ret, nil
+}
+
+func (s *BridgeService) ConfirmDeviceLink(ctx context.Context, req *api2.ConfirmDeviceLinkRequest) (*api2.DeviceLink, error) {
+	if err := validateDeviceLink(req.DeviceId, req.NetworkDeviceId); err != nil {
+		return nil, err
+	}
+
+	links, err := s.getDeviceLinks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for key, link := range links {
+		if link.Status == db.LinkConfirmed && key.networkDeviceID == req.NetworkDeviceId && key.deviceID != req.DeviceId {
+			return nil, status.Errorf(codes.FailedPrecondition, "network device is already linked to %s", key.deviceID)
+		}
+	}
+
+	return s.saveDeviceLink(ctx, req.DeviceId, req.NetworkDeviceId, db.LinkConfirmed)
+}
+
+func (s *BridgeService) RejectDeviceLink(ctx context.Context, req *api2.RejectDeviceLinkRequest) (*api2.DeviceLink, error) {
+	if err := validateDeviceLink(req.DeviceId, req.NetworkDeviceId); err != nil {
+		return nil, err
+	}
+
+	return s.saveDeviceLink(ctx, req.DeviceId, req.NetworkDeviceId, db.LinkRejected)
+}
+
+func validateDeviceLink(deviceID string, networkDeviceID string) error {
+	if len(deviceID) < 1 {
+		return status.Error(codes.InvalidArgument, "device ID is required")
+	} else if len(networkDeviceID) < 1 {
+		return status.Error(codes.InvalidArgument, "network device ID is required")
+	} else if deviceID == networkDeviceID {
+		return status.Error(codes.InvalidArgument, "a device can't be linked to itself")
+	}
+	return nil
+}
+
+func (s *BridgeService) saveDeviceLink(ctx context.Context, deviceID string, networkDeviceID string, linkStatus db.LinkStatus) (*api2.DeviceLink, error) {
+	link, err := s.db.SaveDeviceLink(ctx, &db.DeviceLink{
+		DeviceID:        deviceID,
+		NetworkDeviceID: networkDeviceID,
+		Status:          linkStatus,
+		UpdatedAt:       time.Now(),
+	})
+	if err != nil {
+		s.logger.Error("unable to save device link", zap.String("device_id", deviceID), zap.String("network_device_id", networkDeviceID), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to save device link")
+	}
+
+	return &api2.DeviceLink{
+		DeviceId:        link.DeviceID,
+		NetworkDeviceId: link.NetworkDeviceID,
+		Status:          linkStatuses[link.Status],
+	}, nil
+}
+
+// getDeviceLinks retrieves the confirmed and rejected links, keyed by the devices they join.
+func (s *BridgeService) getDeviceLinks(ctx context.Context) (map[linkKey]db.DeviceLink, error) {
+	links, err := s.db.GetDeviceLinks(ctx)
+	if err != nil {
+		s.logger.Error("unable to get device links", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get device links")
+	}
+
+	ret := map[linkKey]db.DeviceLink{}
+	for _, link := range links {
+		ret[linkKey{deviceID: link.DeviceID, networkDeviceID: link.NetworkDeviceID}] = link
+	}
+	return ret, nil
+}
+
+// bridgeDevices retrieves the devices of every paired bridge, asking each bridge in parallel. Bridges which can't be
+// reached in time are skipped, so one bridge being down doesn't hide the devices of the others.
+func (s *BridgeService) bridgeDevices(ctx context.Context) ([]bridgeDevice, error) {
+	bridges, err := s.db.GetBridges(ctx)
+	if err != nil {
+		s.logger.Error("unable to get bridges", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get bridges")
+	}
+
+	// Each bridge's devices are kept in its own slot so the bridges are reported in a consistent order.
+	results := make([][]bridgeDevice, len(bridges))
+	var wg sync.WaitGroup
+	for i := range bridges {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = s.listBridgeDevices(ctx, &bridges[i])
+		}(i)
+	}
+	wg.Wait()
+
+	var ret []bridgeDevice
+	for _, devices := range results {
+		ret = append(ret, devices...)
+	}
+	return ret, nil
+}
+
+// listBridgeDevices retrieves the devices of the bridge, returning none if the bridge can't be reached in time.
+func (s *BridgeService) listBridgeDevices(ctx context.Context, bridge *db.Bridge) []bridgeDevice {
+	conn, err := s.dial(bridge.Address)
+	if err != nil {
+		s.logger.Warn("unable to connect to bridge", zap.String("bridge_id", bridge.ID), zap.Error(err))
+		return nil
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, bridgeDevicesTimeout)
+	defer cancel()
+	resp, err := api2.NewBridgeServiceClient(conn).ListDevices(ctx, &api2.ListDevicesRequest{}, bridgeCallOptions(bridge)...)
+	if err != nil {
+		s.logger.Warn("unable to list bridge devices", zap.String("bridge_id", bridge.ID), zap.Error(err))
+		return nil
+	}
+
+	var ret []bridgeDevice
+	for _, d := range resp.Devices {
+		ret = append(ret, bridgeDevice{bridgeID: bridge.ID, device: d})
+	}
+	return ret
+}
+
+// matchDevices suggests links between the connected devices and the devices reported by other bridges which share
+// one of their addresses, returning what each pair shares.
+func matchDevices(devices []bridgeDevice) map[linkKey][]string {
+	ret := map[linkKey][]string{}
+	for _, network := range devices {
+		if network.device.GetConnectedDevice() == nil {
+			continue
+		}
+		networkAddrs := deviceAddresses(network.device)
+
+		for _, d := range devices {
+			if d.bridgeID == network.bridgeID || d.device.GetConnectedDevice() != nil {
+				continue
+			}
+
+			addrs := deviceAddresses(d.device)
+			var matchedOn []string
+			for _, kind := range []string{matchHardwareAddress, matchIPAddress, matchHostname} {
+				for addr := range addrs[kind] {
+					if networkAddrs[kind][addr] {
+						matchedOn = append(matchedOn, kind)
+						break
+					}
+				}
+			}
+			if len(matchedOn) > 0 {
+				ret[linkKey{deviceID: d.device.Id, networkDeviceID: network.device.Id}] = matchedOn
+			}
+		}
+	}
+	return ret
+}
+
+// deviceAddresses collects the normalized addresses of the device, by kind, from its network presence and the
+// address it is reached at.
+func deviceAddresses(d *apiDevice.Device) map[string]map[string]bool {
+	ret := map[string]map[string]bool{
+		matchHardwareAddress: {},
+		matchIPAddress:       {},
+		matchHostname:        {},
+	}
+	add := func(addr string) {
+		addr = strings.TrimSpace(addr)
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if _, err := strconv.Atoi(port); err == nil {
+				addr = host
+			}
+		}
+
+		if ip := net.ParseIP(addr); ip != nil {
+			ret[matchIPAddress][ip.String()] = true
+		} else if mac, err := net.ParseMAC(addr); err == nil {
+			ret[matchHardwareAddress][strings.ToUpper(mac.String())] = true
+		} else if hostname := normalizeHostname(addr); len(hostname) > 0 {
+			ret[matchHostname][hostname] = true
+		}
+	}
+
+	if presence := networkPresence(d); presence != nil {
+		state := presence.GetState()
+		if mac, err := net.ParseMAC(state.GetHardwareAddress()); err == nil {
+			ret[matchHardwareAddress][strings.ToUpper(mac.String())] = true
+		}
+		for _, ip := range state.GetIpAddresses() {
+			add(ip)
+		}
+		if hostname := normalizeHostname(state.GetHostname()); len(hostname) > 0 {
+			ret[matchHostname][hostname] = true
+		}
+	}
+	add(d.GetAddress().GetAddress())
+	return ret
+}
+
+// normalizeHostname returns the lower case host part of the name, so "TV.local" matches "tv".
+// Names which couldn't be a hostname, such as UUIDs reported as addresses, are ignored.
+func normalizeHostname(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if host, _, found := strings.Cut(name, "."); found {
+		name = host
+	}
+	if len(name) < 1 || strings.ContainsAny(name, ":/ ") {
+		return ""
+	}
+	return name
+}
+
+// networkPresence returns the network presence reported in the details of the device, if any.
+func networkPresence(d *apiDevice.Device) *trait.NetworkPresence {
+	switch details := d.GetDetails().(type) {
+	case *apiDevice.Device_ConnectedDevice:
+		return details.ConnectedDevice.GetNetworkPresence()
+	case *apiDevice.Device_NetworkDevice:
+		return details.NetworkDevice.GetNetworkPresence()
+	}
+	return nil
+}
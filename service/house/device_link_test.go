@@ -0,0 +1,420 @@
+package house
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	api2 "github.com/rmrobinson/house/api"
+	apiDevice "github.com/rmrobinson/house/api/device"
+	"github.com/rmrobinson/house/api/trait"
+	"github.com/rmrobinson/house/service/house/db"
+)
+
+// deviceBridge is a bridge which reports a fixed list of devices.
+type deviceBridge struct {
+	api2.UnimplementedBridgeServiceServer
+
+	devices []*apiDevice.Device
+}
+
+func (b *deviceBridge) ListDevices(ctx context.Context, req *api2.ListDevicesRequest) (*api2.ListDevicesResponse, error) {
+	return &api2.ListDevicesResponse{Devices: b.devices}, nil
+}
+
+func connectedDevice(id string, hardwareAddress string, hostname string, ips ...string) *apiDevice.Device {
+	return &apiDevice.Device{
+		Id: id,
+		Details: &apiDevice.Device_ConnectedDevice{ConnectedDevice: &apiDevice.ConnectedDevice{
+			NetworkPresence: &trait.NetworkPresence{State: &trait.NetworkPresence_State{
+				HardwareAddress: hardwareAddress,
+				IpAddresses:     ips,
+				Hostname:        hostname,
+			}},
+		}},
+	}
+}
+
+func mediaPlayer(id string, address string) *apiDevice.Device {
+	return &apiDevice.Device{
+		Id:      id,
+		Address: &apiDevice.Device_Address{Address: address},
+		Details: &apiDevice.Device_MediaPlayer{MediaPlayer: &apiDevice.MediaPlayer{}},
+	}
+}
+
+// newTestLinkService serves each list of devices from its own bridge, pairing the bridges with the service by ID.
+// Bridges with a nil list are paired but can't be reached.
+func newTestLinkService(t *testing.T, bridgeDevices map[string][]*apiDevice.Device) (*BridgeService, db.Store) {
+	ca, dialOpt := newTestCA(t)
+	store := newTestDatabase(t)
+	svc := NewBridgeService(zaptest.NewLogger(t), store, dialOpt)
+
+	for bridgeID, devices := range bridgeDevices {
+		var addr string
+		if devices != nil {
+			_, addr = serveTestBridge(t, ca, &deviceBridge{devices: devices})
+		} else {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			addr = lis.Addr().String()
+			lis.Close()
+		}
+
+		_, err := store.SaveBridge(context.Background(), &db.Bridge{ID: bridgeID, Address: addr, Token: "token-" + bridgeID})
+		require.NoError(t, err)
+	}
+	return svc, store
+}
+
+// testBridgeDevices are the devices reported by the paired bridges: a network controller, a media bridge, and a
+// bridge which is down.
+var testBridgeDevices = map[string][]*apiDevice.Device{
+	"omada": {
+		connectedDevice("client-den", "aa:bb:cc:dd:ee:01", "Roku-Den", "192.168.1.20"),
+		connectedDevice("client-phone", "aa:bb:cc:dd:ee:02", "phone", "192.168.1.30"),
+	},
+	"roku": {
+		mediaPlayer("roku-den", "192.168.1.20:8060"),
+		mediaPlayer("roku-bedroom", "192.168.1.21:8060"),
+	},
+	"down": nil,
+}
+
+func TestNormalizeHostname(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"tv", "tv"},
+		{" TV.local ", "tv"},
+		{"den-roku.lan.example.com", "den-roku"},
+		{"", ""},
+		{"uuid:4c1b-98ad", ""},
+		{"http://tv/", ""},
+		{"living room", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, normalizeHostname(tt.name))
+		})
+	}
+}
+
+func TestDeviceAddresses(t *testing.T) {
+	tests := []struct {
+		name     string
+		device   *apiDevice.Device
+		hardware []string
+		ips      []string
+		hosts    []string
+	}{
+		{
+			name:     "network presence",
+			device:   connectedDevice("client", "aa-bb-cc-dd-ee-01", "Roku-Den.local", "192.168.1.20", "FE80::1"),
+			hardware: []string{"AA:BB:CC:DD:EE:01"},
+			ips:      []string{"192.168.1.20", "fe80::1"},
+			hosts:    []string{"roku-den"},
+		},
+		{
+			name:   "IP address with port",
+			device: mediaPlayer("roku", "192.168.1.20:8060"),
+			ips:    []string{"192.168.1.20"},
+		},
+		{
+			name:   "hostname with port",
+			device: mediaPlayer("roku", "Roku-Den.local:8060"),
+			hosts:  []string{"roku-den"},
+		},
+		{
+			name:     "hardware address",
+			device:   mediaPlayer("tv", "aa:bb:cc:dd:ee:03"),
+			hardware: []string{"AA:BB:CC:DD:EE:03"},
+		},
+		{
+			name:   "not an address",
+			device: mediaPlayer("plex", "uuid:4c1b-98ad"),
+		},
+		{
+			name:   "invalid hardware address",
+			device: connectedDevice("client", "unknown", "", "not an ip"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addrs := deviceAddresses(tt.device)
+			assert.ElementsMatch(t, tt.hardware, keys(addrs[matchHardwareAddress]))
+			assert.ElementsMatch(t, tt.ips, keys(addrs[matchIPAddress]))
+			assert.ElementsMatch(t, tt.hosts, keys(addrs[matchHostname]))
+		})
+	}
+}
+
+func keys(m map[string]bool) []string {
+	var ret []string
+	for k := range m {
+		ret = append(ret, k)
+	}
+	return ret
+}
+
+func TestMatchDevices(t *testing.T) {
+	tests := []struct {
+		name     string
+		devices  []bridgeDevice
+		expected map[linkKey][]string
+	}{
+		{
+			name: "matched on IP address",
+			devices: []bridgeDevice{
+				{"omada", connectedDevice("client", "", "", "192.168.1.20")},
+				{"roku", mediaPlayer("roku", "192.168.1.20:8060")},
+			},
+			expected: map[linkKey][]string{{"roku", "client"}: {matchIPAddress}},
+		},
+		{
+			name: "matched on every kind",
+			devices: []bridgeDevice{
+				{"omada", connectedDevice("client", "aa:bb:cc:dd:ee:01", "tv", "192.168.1.20")},
+				{"tv", &apiDevice.Device{
+					Id:      "tv",
+					Address: &apiDevice.Device_Address{Address: "tv.local"},
+					Details: &apiDevice.Device_NetworkDevice{NetworkDevice: &apiDevice.NetworkDevice{
+						NetworkPresence: &trait.NetworkPresence{State: &trait.NetworkPresence_State{
+							HardwareAddress: "AA-BB-CC-DD-EE-01",
+							IpAddresses:     []string{"192.168.1.20"},
+						}},
+					}},
+				}},
+			},
+			expected: map[linkKey][]string{{"tv", "client"}: {matchHardwareAddress, matchIPAddress, matchHostname}},
+		},
+		{
+			name: "no shared address",
+			devices: []bridgeDevice{
+				{"omada", connectedDevice("client", "", "phone", "192.168.1.30")},
+				{"roku", mediaPlayer("roku", "192.168.1.20:8060")},
+			},
+			expected: map[linkKey][]string{},
+		},
+		{
+			name: "devices of the same bridge",
+			devices: []bridgeDevice{
+				{"omada", connectedDevice("client", "", "", "192.168.1.20")},
+				{"omada", mediaPlayer("ap", "192.168.1.20")},
+			},
+			expected: map[linkKey][]string{},
+		},
+		{
+			name: "connected devices aren't linked to each other",
+			devices: []bridgeDevice{
+				{"omada", connectedDevice("client", "", "", "192.168.1.20")},
+				{"unifi", connectedDevice("other", "", "", "192.168.1.20")},
+			},
+			expected: map[linkKey][]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchDevices(tt.devices))
+		})
+	}
+}
+
+func TestListDeviceLinks(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newTestLinkService(t, testBridgeDevices)
+
+	resp, err := svc.ListDeviceLinks(ctx, &api2.ListDeviceLinksRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Links, 1)
+	assert.True(t, proto.Equal(&api2.DeviceLink{
+		DeviceId:        "roku-den",
+		NetworkDeviceId: "client-den",
+		Status:          api2.DeviceLink_STATUS_SUGGESTED,
+		MatchedOn:       []string{matchIPAddress},
+	}, resp.Links[0]))
+
+	_, err = svc.RejectDeviceLink(ctx, &api2.RejectDeviceLinkRequest{DeviceId: "roku-den", NetworkDeviceId: "client-den"})
+	require.NoError(t, err)
+	resp, err = svc.ListDeviceLinks(ctx, &api2.ListDeviceLinksRequest{Status: api2.DeviceLink_STATUS_SUGGESTED})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Links)
+}
+
+func TestListLogicalDevices(t *testing.T) {
+	tests := []struct {
+		name  string
+		links []db.DeviceLink
+		// expected lists the linked devices of each logical device.
+		expected map[string][]string
+	}{
+		{
+			name: "no links",
+			expected: map[string][]string{
+				"client-den":   nil,
+				"client-phone": nil,
+				"roku-den":     nil,
+				"roku-bedroom": nil,
+			},
+		},
+		{
+			name: "confirmed link is folded",
+			links: []db.DeviceLink{
+				{DeviceID: "roku-den", NetworkDeviceID: "client-den", Status: db.LinkConfirmed},
+			},
+			expected: map[string][]string{
+				"client-phone": nil,
+				"roku-den":     {"client-den"},
+				"roku-bedroom": nil,
+			},
+		},
+		{
+			name: "rejected link isn't folded",
+			links: []db.DeviceLink{
+				{DeviceID: "roku-den", NetworkDeviceID: "client-den", Status: db.LinkRejected},
+			},
+			expected: map[string][]string{
+				"client-den":   nil,
+				"client-phone": nil,
+				"roku-den":     nil,
+				"roku-bedroom": nil,
+			},
+		},
+		{
+			name: "link to a device which wasn't reported isn't folded",
+			links: []db.DeviceLink{
+				{DeviceID: "lamp", NetworkDeviceID: "client-phone", Status: db.LinkConfirmed},
+			},
+			expected: map[string][]string{
+				"client-den":   nil,
+				"client-phone": nil,
+				"roku-den":     nil,
+				"roku-bedroom": nil,
+			},
+		},
+		{
+			name: "several links are folded into one device",
+			links: []db.DeviceLink{
+				{DeviceID: "roku-den", NetworkDeviceID: "client-phone", Status: db.LinkConfirmed},
+				{DeviceID: "roku-den", NetworkDeviceID: "client-den", Status: db.LinkConfirmed},
+			},
+			expected: map[string][]string{
+				"roku-den":     {"client-den", "client-phone"},
+				"roku-bedroom": nil,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, store := newTestLinkService(t, testBridgeDevices)
+			for _, link := range tt.links {
+				link.UpdatedAt = time.Now()
+				_, err := store.SaveDeviceLink(ctx, &link)
+				require.NoError(t, err)
+			}
+
+			resp, err := svc.ListLogicalDevices(ctx, &api2.ListLogicalDevicesRequest{})
+			require.NoError(t, err)
+
+			actual := map[string][]string{}
+			for _, d := range resp.Devices {
+				actual[d.Device.Id] = d.LinkedDeviceIds
+				if len(d.LinkedDeviceIds) > 0 {
+					assert.Equal(t, "Roku-Den", d.NetworkPresence.GetState().GetHostname(), "presence of the first linked device")
+				} else if d.Device.GetConnectedDevice() == nil {
+					assert.Nil(t, d.NetworkPresence)
+				}
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestConfirmDeviceLink(t *testing.T) {
+	tests := []struct {
+		name  string
+		links []db.DeviceLink
+		req   *api2.ConfirmDeviceLinkRequest
+		code  codes.Code
+	}{
+		{
+			name: "suggested link",
+			req:  &api2.ConfirmDeviceLinkRequest{DeviceId: "roku-den", NetworkDeviceId: "client-den"},
+			code: codes.OK,
+		},
+		{
+			name: "already confirmed",
+			links: []db.DeviceLink{
+				{DeviceID: "roku-den", NetworkDeviceID: "client-den", Status: db.LinkConfirmed},
+			},
+			req:  &api2.ConfirmDeviceLinkRequest{DeviceId: "roku-den", NetworkDeviceId: "client-den"},
+			code: codes.OK,
+		},
+		{
+			name: "previously rejected",
+			links: []db.DeviceLink{
+				{DeviceID: "roku-den", NetworkDeviceID: "client-den", Status: db.LinkRejected},
+			},
+			req:  &api2.ConfirmDeviceLinkRequest{DeviceId: "roku-den", NetworkDeviceId: "client-den"},
+			code: codes.OK,
+		},
+		{
+			name: "linked to another device",
+			links: []db.DeviceLink{
+				{DeviceID: "roku-bedroom", NetworkDeviceID: "client-den", Status: db.LinkConfirmed},
+			},
+			req:  &api2.ConfirmDeviceLinkRequest{DeviceId: "roku-den", NetworkDeviceId: "client-den"},
+			code: codes.FailedPrecondition,
+		},
+		{
+			name: "rejected for another device",
+			links: []db.DeviceLink{
+				{DeviceID: "roku-bedroom", NetworkDeviceID: "client-den", Status: db.LinkRejected},
+			},
+			req:  &api2.ConfirmDeviceLinkRequest{DeviceId: "roku-den", NetworkDeviceId: "client-den"},
+			code: codes.OK,
+		},
+		{
+			name: "linked to itself",
+			req:  &api2.ConfirmDeviceLinkRequest{DeviceId: "roku-den", NetworkDeviceId: "roku-den"},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "missing network device",
+			req:  &api2.ConfirmDeviceLinkRequest{DeviceId: "roku-den"},
+			code: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, store := newTestLinkService(t, testBridgeDevices)
+			for _, link := range tt.links {
+				link.UpdatedAt = time.Now()
+				_, err := store.SaveDeviceLink(ctx, &link)
+				require.NoError(t, err)
+			}
+
+			link, err := svc.ConfirmDeviceLink(ctx, tt.req)
+			require.Equal(t, tt.code, status.Code(err))
+			if err != nil {
+				return
+			}
+			assert.Equal(t, api2.DeviceLink_STATUS_CONFIRMED, link.Status)
+
+			resp, err := svc.ListDeviceLinks(ctx, &api2.ListDeviceLinksRequest{Status: api2.DeviceLink_STATUS_CONFIRMED})
+			require.NoError(t, err)
+			assert.Len(t, resp.Links, 1)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package house
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/service/house/db"
+)
+
+var layoutActions = map[db.LayoutAction]api2.ApplyLayoutResponse_Change_Action{
+	db.LayoutCreate: api2.ApplyLayoutResponse_Change_ACTION_CREATE,
+	db.LayoutUpdate: api2.ApplyLayoutResponse_Change_ACTION_UPDATE,
+	db.LayoutDelete: api2.ApplyLayoutResponse_Change_ACTION_DELETE,
+}
+
+func (s *Service) ExportLayout(ctx context.Context, req *api2.ExportLayoutRequest) (*api2.Layout, error) {
+	buildings, err := s.db.GetLayout(ctx)
+	if err != nil {
+		s.logger.Error("unable to get layout", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get layout")
+	}
+
+	ret := &api2.Layout{}
+	for _, building := range buildings {
+		layout := &api2.Layout_Building{
+			Config: buildingDBToAPI(building.Building).Config,
+		}
+		for _, floor := range building.Floors {
+			layoutFloor := &api2.Layout_Floor{
+				Name:  floor.Name,
+				Level: int32(floor.Level),
+			}
+			for _, room := range floor.Rooms {
+				layoutFloor.Rooms = append(layoutFloor.Rooms, roomDBToLayout(room))
+			}
+			layout.Floors = append(layout.Floors, layoutFloor)
+		}
+		for _, room := range building.Rooms {
+			layout.Rooms = append(layout.Rooms, roomDBToLayout(room))
+		}
+		ret.Buildings = append(ret.Buildings, layout)
+	}
+	return ret, nil
+}
+
+func (s *Service) ApplyLayout(ctx context.Context, req *api2.ApplyLayoutRequest) (*api2.ApplyLayoutResponse, error) {
+	buildings, err := layoutToDB(req.Layout)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.db.ApplyLayout(ctx, buildings, req.DryRun)
+	if err != nil {
+		s.logger.Error("unable to apply layout", zap.Bool("dry_run", req.DryRun), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to apply layout")
+	}
+
+	ret := &api2.ApplyLayoutResponse{}
+	for _, change := range changes {
+		ret.Changes = append(ret.Changes, &api2.ApplyLayoutResponse_Change{
+			Action: layoutActions[change.Action],
+			Kind:   change.Kind,
+			Path:   change.Path,
+		})
+	}
+	return ret, nil
+}
+
+// layoutToDB validates the supplied layout and converts it. Names must be unique where they are used for matching,
+// and a device may only be linked to one room.
+func layoutToDB(layout *api2.Layout) ([]db.LayoutBuilding, error) {
+	if layout == nil {
+		return nil, status.Error(codes.InvalidArgument, "layout must be set")
+	}
+
+	var ret []db.LayoutBuilding
+	buildingNames := map[string]bool{}
+	deviceIDs := map[string]bool{}
+	for _, building := range layout.Buildings {
+		if err := validateBuildingConfig(building.Config); err != nil {
+			return nil, err
+		} else if buildingNames[building.Config.Name] {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("building %s is defined more than once", building.Config.Name))
+		}
+		buildingNames[building.Config.Name] = true
+
+		ret = append(ret, db.LayoutBuilding{
+			Building: *buildingConfigToDB(building.Config),
+		})
+		dbBuilding := &ret[len(ret)-1]
+
+		floorNames := map[string]bool{}
+		roomNames := map[string]bool{}
+		convertRooms := func(rooms []*api2.Layout_Room) ([]db.Room, error) {
+			var ret []db.Room
+			for _, room := range rooms {
+				if len(room.Name) < 1 {
+					return nil, status.Error(codes.InvalidArgument, "room name must be set")
+				} else if roomNames[room.Name] {
+					return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("room %s/%s is defined more than once", building.Config.Name, room.Name))
+				}
+				roomNames[room.Name] = true
+
+				roomType, err := roomTypeAPIToDB(room.Type)
+				if err != nil {
+					return nil, err
+				}
+
+				dbRoom := db.Room{
+					Name: room.Name,
+					Type: roomType,
+				}
+				for _, device := range room.Devices {
+					if len(device.Id) < 1 {
+						return nil, status.Error(codes.InvalidArgument, "device ID must be set")
+					} else if deviceIDs[device.Id] {
+						return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("device %s is linked more than once", device.Id))
+					}
+					deviceIDs[device.Id] = true
+
+					dbDevice := db.Device{
+						ID: device.Id,
+					}
+					if device.Metadata != nil {
+						dbDevice.Tags = normalizeValues(device.Metadata.Tags, true)
+						dbDevice.Aliases = normalizeValues(device.Metadata.Aliases, false)
+					}
+					dbRoom.Devices = append(dbRoom.Devices, dbDevice)
+				}
+				ret = append(ret, dbRoom)
+			}
+			return ret, nil
+		}
+
+		for _, floor := range building.Floors {
+			if len(floor.Name) < 1 {
+				return nil, status.Error(codes.InvalidArgument, "floor name must be set")
+			} else if floorNames[floor.Name] {
+				return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("floor %s/%s is defined more than once", building.Config.Name, floor.Name))
+			}
+			floorNames[floor.Name] = true
+
+			rooms, err := convertRooms(floor.Rooms)
+			if err != nil {
+				return nil, err
+			}
+			dbBuilding.Floors = append(dbBuilding.Floors, db.Floor{
+				Name:  floor.Name,
+				Level: int(floor.Level),
+				Rooms: rooms,
+			})
+		}
+
+		rooms, err := convertRooms(building.Rooms)
+		if err != nil {
+			return nil, err
+		}
+		dbBuilding.Rooms = rooms
+	}
+	return ret, nil
+}
+
+func roomDBToLayout(room db.Room) *api2.Layout_Room {
+	ret := &api2.Layout_Room{
+		Name: room.Name,
+		Type: roomTypeDBToAPI(room.Type),
+	}
+	for _, device := range room.Devices {
+		layoutDevice := &api2.Layout_Device{
+			Id: device.ID,
+		}
+		if len(device.Tags) > 0 || len(device.Aliases) > 0 {
+			layoutDevice.Metadata = deviceMetadataDBToAPI(device)
+		}
+		ret.Devices = append(ret.Devices, layoutDevice)
+	}
+	return ret
+}
@@ -0,0 +1,91 @@
+// Package layout reads and writes house layouts as YAML or JSON files, so they can be kept under version control.
+package layout
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+
+	api2 "github.com/rmrobinson/house/api"
+)
+
+// Format is the encoding used for a layout file.
+type Format int
+
+const (
+	YAML Format = iota
+	JSON
+)
+
+// FormatFromPath returns the format of the file at the specified path, based on its extension.
+// Files which don't end in .json are treated as YAML.
+func FormatFromPath(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return JSON
+	}
+	return YAML
+}
+
+var marshalOpts = protojson.MarshalOptions{
+	Multiline:     true,
+	UseProtoNames: true,
+}
+
+// Marshal encodes the layout in the specified format. Fields are written in the order they are defined in the API.
+func Marshal(layout *api2.Layout, format Format) ([]byte, error) {
+	data, err := marshalOpts.Marshal(layout)
+	if err != nil {
+		return nil, err
+	}
+	if format == JSON {
+		return data, nil
+	}
+
+	// JSON is valid YAML, so decoding it into a node keeps the field order. Clearing the style of each node
+	// writes it as block YAML rather than as JSON.
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal(data, node); err != nil {
+		return nil, err
+	}
+	clearStyle(node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a layout written in either YAML or JSON.
+func Unmarshal(data []byte) (*api2.Layout, error) {
+	var contents any
+	if err := yaml.Unmarshal(data, &contents); err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := &api2.Layout{}
+	if err := protojson.Unmarshal(jsonData, layout); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
@@ -0,0 +1,150 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	api2 "github.com/rmrobinson/house/api"
+)
+
+// testLayout uses names which YAML would read as other types if they were written unquoted.
+func testLayout() *api2.Layout {
+	return &api2.Layout{
+		Buildings: []*api2.Layout_Building{
+			{
+				Config: &api2.Building_Config{
+					Name:      "123",
+					Timezone:  "America/Toronto",
+					Latitude:  43.65,
+					Longitude: -79.38,
+				},
+				Floors: []*api2.Layout_Floor{
+					{
+						Name:  "yes",
+						Level: -1,
+						Rooms: []*api2.Layout_Room{
+							{
+								Name: "on",
+								Type: api2.RoomType_ROOM_TYPE_FURNACE_ROOM,
+								Devices: []*api2.Layout_Device{
+									{
+										Id: "0x10",
+										Metadata: &api2.DeviceMetadata{
+											Tags:    []string{"null", "true"},
+											Aliases: []string{"~", "1e3"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				Rooms: []*api2.Layout_Room{
+					{Name: "Porch", Type: api2.RoomType_ROOM_TYPE_PORCH},
+				},
+			},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+	}{
+		{"yaml", YAML},
+		{"json", JSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := testLayout()
+			data, err := Marshal(layout, tt.format)
+			require.NoError(t, err)
+
+			res, err := Unmarshal(data)
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(layout, res), "layout changed by the round trip:\n%s", data)
+		})
+	}
+}
+
+func TestMarshalYAML(t *testing.T) {
+	data, err := Marshal(testLayout(), YAML)
+	require.NoError(t, err)
+
+	// The output is block YAML with the fields in the order they are defined in the API.
+	assert.Contains(t, string(data), "buildings:\n  - config:\n      name: \"123\"\n      timezone: America/Toronto\n")
+	assert.NotContains(t, string(data), "{")
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected *api2.Layout
+		err      bool
+	}{
+		{
+			name: "written by hand",
+			data: `
+buildings:
+  - config:
+      name: Home
+      timezone: UTC
+    floors:
+      - name: Basement
+        level: -1
+        rooms:
+          - name: Furnace
+            type: ROOM_TYPE_FURNACE_ROOM
+`,
+			expected: &api2.Layout{Buildings: []*api2.Layout_Building{{
+				Config: &api2.Building_Config{Name: "Home", Timezone: "UTC"},
+				Floors: []*api2.Layout_Floor{{
+					Name:  "Basement",
+					Level: -1,
+					Rooms: []*api2.Layout_Room{{Name: "Furnace", Type: api2.RoomType_ROOM_TYPE_FURNACE_ROOM}},
+				}},
+			}}},
+		},
+		{
+			name:     "json",
+			data:     `{"buildings": [{"config": {"name": "Home"}}]}`,
+			expected: &api2.Layout{Buildings: []*api2.Layout_Building{{Config: &api2.Building_Config{Name: "Home"}}}},
+		},
+		{
+			name: "unknown field",
+			data: "buildings:\n  - config:\n      name: Home\n      colour: red\n",
+			err:  true,
+		},
+		{
+			name: "unknown field in json",
+			data: `{"buildings": [], "zones": []}`,
+			err:  true,
+		},
+		{
+			name: "unknown room type",
+			data: "buildings:\n  - rooms:\n      - name: Attic\n        type: ROOM_TYPE_ATTIC\n",
+			err:  true,
+		},
+		{
+			name: "invalid yaml",
+			data: "buildings: [",
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Unmarshal([]byte(tt.data))
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(tt.expected, res), "got %v", res)
+		})
+	}
+}
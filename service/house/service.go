@@ -2,6 +2,7 @@ package house
 
 import (
 	"context"
+	"errors"
 
 	api2 "github.com/rmrobinson/house/api"
 	apiDevice "github.com/rmrobinson/house/api/device"
@@ -17,121 +18,155 @@ var ErrNotImplemented = status.Error(codes.Unimplemented, "method not implemente
 type Service struct {
 	logger *zap.Logger
 
-	db *db.Database
+	db db.Store
 }
 
-func NewService(logger *zap.Logger, db *db.Database) *Service {
+func NewService(logger *zap.Logger, db db.Store) *Service {
 	return &Service{
 		logger: logger,
 		db:     db,
 	}
 }
 
-func (s *Service) ListBuildings(ctx context.Context, req *api2.ListBuildingsRequest) (*api2.ListBuildingsResponse, error) {
-	buildings, err := s.db.GetBuildings(ctx)
+func (s *Service) LinkDevice(ctx context.Context, req *api2.LinkDeviceRequest) (*api2.Room, error) {
+	room, err := s.db.GetRoom(ctx, req.RoomId)
 	if err != nil {
-		s.logger.Error("unable to get buildings", zap.Error(err))
-		return nil, status.Error(codes.Internal, "unable to get buildings")
+		s.logger.Error("unable to get room", zap.String("room_id", req.RoomId), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get room")
+	} else if room == nil {
+		return nil, status.Error(codes.NotFound, "room doesn't exist")
 	}
 
-	ret := &api2.ListBuildingsResponse{}
-	for _, building := range buildings {
-		ret.Buildings = append(ret.Buildings, &api2.Building{
-			Id: building.ID,
-			Config: &api2.Building_Config{
-				Name: building.Name,
-			},
-		})
+	device, err := s.db.CreateDevice(ctx, req.DeviceId, *room)
+	if err != nil {
+		s.logger.Error("unable to link device to room", zap.String("device_id", req.DeviceId), zap.String("room_id", req.RoomId), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to link device")
 	}
-	return ret, nil
+
+	room.Devices = append(room.Devices, *device)
+	return roomDBToAPI(*room), nil
 }
 
-func (s *Service) GetBuilding(ctx context.Context, req *api2.GetBuildingRequest) (*api2.Building, error) {
-	building, err := s.db.GetBuilding(ctx, req.Id)
+func (s *Service) UnlinkDevice(ctx context.Context, req *api2.UnlinkDeviceRequest) (*emptypb.Empty, error) {
+	err := s.db.DeleteDevice(ctx, req.Id)
 	if err != nil {
-		s.logger.Error("unable to get building", zap.String("building_id", req.Id), zap.Error(err))
-		return nil, status.Error(codes.Internal, "unable to get building")
-	} else if building == nil {
-		return nil, status.Error(codes.NotFound, "building doesn't exist")
+		s.logger.Error("unable to unlink device", zap.String("device_id", req.Id), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to unlink device")
 	}
 
-	rooms, err := s.db.GetBuildingRooms(ctx, req.Id)
-	if err != nil {
-		s.logger.Error("unable to get building rooms", zap.String("building_id", req.Id), zap.Error(err))
-	}
+	return &emptypb.Empty{}, nil
+}
 
-	ret := &api2.Building{
-		Id: building.ID,
-		Config: &api2.Building_Config{
-			Name: building.Name,
-		},
+func (s *Service) ListRooms(ctx context.Context, req *api2.ListRoomsRequest) (*api2.ListRoomsResponse, error) {
+	var rooms []db.Room
+	var err error
+	if len(req.ZoneId) > 0 {
+		rooms, err = s.db.GetZoneRooms(ctx, req.ZoneId)
+		if err != nil {
+			s.logger.Error("unable to get zone rooms", zap.String("zone_id", req.ZoneId), zap.Error(err))
+			return nil, status.Error(codes.Internal, "unable to get rooms")
+		}
+	} else {
+		rooms, err = s.db.GetBuildingRooms(ctx, req.BuildingId)
+		if err != nil {
+			s.logger.Error("unable to get building rooms", zap.String("building_id", req.BuildingId), zap.Error(err))
+			return nil, status.Error(codes.Internal, "unable to get rooms")
+		}
 	}
 
+	ret := &api2.ListRoomsResponse{}
 	for _, room := range rooms {
+		if len(req.BuildingId) > 0 && room.BuildingID != req.BuildingId {
+			continue
+		} else if len(req.FloorId) > 0 && room.FloorID != req.FloorId {
+			continue
+		}
 		ret.Rooms = append(ret.Rooms, roomDBToAPI(room))
 	}
 	return ret, nil
 }
 
-func (s *Service) LinkDevice(ctx context.Context, req *api2.LinkDeviceRequest) (*api2.Room, error) {
-	room, err := s.db.GetRoom(ctx, req.RoomId)
+// GetRoom returns the specified room along with the devices linked to it.
+func (s *Service) GetRoom(ctx context.Context, req *api2.GetRoomRequest) (*api2.Room, error) {
+	room, err := s.db.GetRoomWithDevices(ctx, req.Id)
 	if err != nil {
-		s.logger.Error("unable to get room", zap.String("room_id", req.RoomId), zap.Error(err))
+		s.logger.Error("unable to get room", zap.String("room_id", req.Id), zap.Error(err))
 		return nil, status.Error(codes.Internal, "unable to get room")
 	} else if room == nil {
 		return nil, status.Error(codes.NotFound, "room doesn't exist")
 	}
 
-	device, err := s.db.CreateDevice(ctx, req.DeviceId, *room)
-	if err != nil {
-		s.logger.Error("unable to link device to room", zap.String("device_id", req.DeviceId), zap.String("room_id", req.RoomId), zap.Error(err))
-		return nil, status.Error(codes.Internal, "unable to link device")
-	}
-
-	room.Devices = append(room.Devices, *device)
 	return roomDBToAPI(*room), nil
 }
 
-func (s *Service) UnlinkDevice(ctx context.Context, req *api2.UnlinkDeviceRequest) (*emptypb.Empty, error) {
-	err := s.db.DeleteDevice(ctx, req.Id)
+func (s *Service) CreateRoom(ctx context.Context, req *api2.CreateRoomRequest) (*api2.Room, error) {
+	if req.Config == nil {
+		return nil, status.Error(codes.InvalidArgument, "room config is required")
+	}
+
+	roomType, err := roomTypeAPIToDB(req.Config.Type)
 	if err != nil {
-		s.logger.Error("unable to unlink device", zap.String("device_id", req.Id), zap.Error(err))
-		return nil, status.Error(codes.Internal, "unable to unlink device")
+		return nil, err
 	}
 
-	return &emptypb.Empty{}, nil
-}
+	building, err := s.db.GetBuilding(ctx, req.BuildingId)
+	if err != nil {
+		s.logger.Error("unable to get building", zap.String("building_id", req.BuildingId), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get building")
+	} else if building == nil {
+		return nil, status.Error(codes.NotFound, "building doesn't exist")
+	}
 
-func (s *Service) CreateRoom(ctx context.Context, req *api2.CreateRoomRequest) (*api2.Room, error) {
 	room := &db.Room{
 		Name:       req.Config.Name,
 		BuildingID: req.BuildingId,
-		Type:       db.RoomType(req.Config.Type),
+		FloorID:    req.Config.FloorId,
+		Type:       roomType,
+	}
+
+	if err := s.checkFloorInBuilding(ctx, room.FloorID, room.BuildingID); err != nil {
+		return nil, err
 	}
 
-	res, err := s.db.CreateRoom(context.Background(), room)
+	res, err := s.db.CreateRoom(ctx, room)
 	if err != nil {
 		s.logger.Error("unable to create room", zap.Error(err))
-		return nil, status.Error(codes.Internal, "unable to create room")
+		return nil, dbErrorToStatus(err, "unable to create room")
 	}
 
 	return roomDBToAPI(*res), nil
 }
 
 func (s *Service) UpdateRoom(ctx context.Context, req *api2.UpdateRoomRequest) (*api2.Room, error) {
+	if req.Config == nil {
+		return nil, status.Error(codes.InvalidArgument, "room config is required")
+	}
+
 	room, err := s.db.GetRoom(ctx, req.Id)
 	if err != nil {
 		s.logger.Error("unable to get room", zap.String("room_id", req.Id), zap.Error(err))
 		return nil, status.Error(codes.Internal, "unable to get room")
+	} else if room == nil {
+		return nil, status.Error(codes.NotFound, "room doesn't exist")
+	}
+
+	if err := s.checkFloorInBuilding(ctx, req.Config.FloorId, room.BuildingID); err != nil {
+		return nil, err
+	}
+
+	roomType, err := roomTypeAPIToDB(req.Config.Type)
+	if err != nil {
+		return nil, err
 	}
 
 	room.Name = req.Config.Name
-	room.Type = db.RoomType(req.Config.Type)
+	room.FloorID = req.Config.FloorId
+	room.Type = roomType
 
 	res, err := s.db.UpdateRoom(ctx, room)
 	if err != nil {
 		s.logger.Error("unable to update room", zap.String("room_id", req.Id), zap.Error(err))
-		return nil, status.Error(codes.Internal, "unable to update room")
+		return nil, dbErrorToStatus(err, "unable to update room")
 	}
 
 	return roomDBToAPI(*res), nil
@@ -141,23 +176,174 @@ func (s *Service) DeleteRoom(ctx context.Context, req *api2.DeleteRoomRequest) (
 	err := s.db.DeleteRoom(ctx, req.Id)
 	if err != nil {
 		s.logger.Error("unable to delete room", zap.String("room_id", req.Id), zap.Error(err))
-		return nil, status.Error(codes.Internal, "unable to delete room")
+		return nil, dbErrorToStatus(err, "unable to delete room")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Service) CreateFloor(ctx context.Context, req *api2.CreateFloorRequest) (*api2.Floor, error) {
+	if req.Config == nil {
+		return nil, status.Error(codes.InvalidArgument, "floor config is required")
+	}
+
+	building, err := s.db.GetBuilding(ctx, req.BuildingId)
+	if err != nil {
+		s.logger.Error("unable to get building", zap.String("building_id", req.BuildingId), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get building")
+	} else if building == nil {
+		return nil, status.Error(codes.NotFound, "building doesn't exist")
+	}
+
+	floor := &db.Floor{
+		BuildingID: req.BuildingId,
+		Name:       req.Config.Name,
+		Level:      int(req.Config.Level),
+	}
+
+	res, err := s.db.CreateFloor(ctx, floor)
+	if err != nil {
+		s.logger.Error("unable to create floor", zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to create floor")
+	}
+
+	return floorDBToAPI(*res), nil
+}
+
+func (s *Service) UpdateFloor(ctx context.Context, req *api2.UpdateFloorRequest) (*api2.Floor, error) {
+	if req.Config == nil {
+		return nil, status.Error(codes.InvalidArgument, "floor config is required")
+	}
+
+	floor, err := s.db.GetFloor(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("unable to get floor", zap.String("floor_id", req.Id), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get floor")
+	} else if floor == nil {
+		return nil, status.Error(codes.NotFound, "floor doesn't exist")
+	}
+
+	floor.Name = req.Config.Name
+	floor.Level = int(req.Config.Level)
+
+	res, err := s.db.UpdateFloor(ctx, floor)
+	if err != nil {
+		s.logger.Error("unable to update floor", zap.String("floor_id", req.Id), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to update floor")
+	}
+
+	return floorDBToAPI(*res), nil
+}
+
+func (s *Service) DeleteFloor(ctx context.Context, req *api2.DeleteFloorRequest) (*emptypb.Empty, error) {
+	err := s.db.DeleteFloor(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("unable to delete floor", zap.String("floor_id", req.Id), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to delete floor")
 	}
 
 	return &emptypb.Empty{}, nil
 }
 
+// checkFloorInBuilding confirms that the specified floor, if any, exists in the specified building.
+func (s *Service) checkFloorInBuilding(ctx context.Context, floorID string, buildingID string) error {
+	if len(floorID) < 1 {
+		return nil
+	}
+
+	floor, err := s.db.GetFloor(ctx, floorID)
+	if err != nil {
+		s.logger.Error("unable to get floor", zap.String("floor_id", floorID), zap.Error(err))
+		return status.Error(codes.Internal, "unable to get floor")
+	} else if floor == nil {
+		return status.Error(codes.NotFound, "floor doesn't exist")
+	} else if floor.BuildingID != buildingID {
+		return status.Error(codes.InvalidArgument, "floor is not in the room's building")
+	}
+	return nil
+}
+
+// roomTypes maps the stored room types to their API equivalents.
+var roomTypes = map[db.RoomType]api2.RoomType{
+	db.Unspecified: api2.RoomType_ROOM_TYPE_UNSPECIFIED,
+	db.Bedroom:     api2.RoomType_ROOM_TYPE_BEDROOM,
+	db.Bathroom:    api2.RoomType_ROOM_TYPE_BATHROOM,
+	db.Office:      api2.RoomType_ROOM_TYPE_OFFICE,
+	db.Foyer:       api2.RoomType_ROOM_TYPE_FOYER,
+	db.Landing:     api2.RoomType_ROOM_TYPE_LANDING,
+	db.Porch:       api2.RoomType_ROOM_TYPE_PORCH,
+	db.Kitchen:     api2.RoomType_ROOM_TYPE_KITCHEN,
+	db.LivingRoom:  api2.RoomType_ROOM_TYPE_LIVING_ROOM,
+	db.DiningRoom:  api2.RoomType_ROOM_TYPE_DINING_ROOM,
+	db.FamilyRoom:  api2.RoomType_ROOM_TYPE_FAMILY_ROOM,
+	db.FurnaceRoom: api2.RoomType_ROOM_TYPE_FURNACE_ROOM,
+	db.UtilityRoom: api2.RoomType_ROOM_TYPE_UTILITY_ROOM,
+}
+
+func roomTypeDBToAPI(roomType db.RoomType) api2.RoomType {
+	if apiType, found := roomTypes[roomType]; found {
+		return apiType
+	}
+	return api2.RoomType_ROOM_TYPE_UNSPECIFIED
+}
+
+func roomTypeAPIToDB(roomType api2.RoomType) (db.RoomType, error) {
+	for dbType, apiType := range roomTypes {
+		if apiType == roomType {
+			return dbType, nil
+		}
+	}
+	return db.Unspecified, status.Error(codes.InvalidArgument, "invalid room type specified")
+}
+
+// dbErrorToStatus converts an error returned when changing the database into the matching gRPC status.
+func dbErrorToStatus(err error, msg string) error {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return status.Error(codes.NotFound, msg)
+	case errors.Is(err, db.ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, msg)
+	case errors.Is(err, db.ErrInvalidReference):
+		return status.Error(codes.FailedPrecondition, msg)
+	}
+	return status.Error(codes.Internal, msg)
+}
+
+func floorDBToAPI(floor db.Floor) *api2.Floor {
+	ret := &api2.Floor{
+		Id: floor.ID,
+		Config: &api2.Floor_Config{
+			Name:  floor.Name,
+			Level: int32(floor.Level),
+		},
+	}
+
+	for _, room := range floor.Rooms {
+		ret.Rooms = append(ret.Rooms, roomDBToAPI(room))
+	}
+
+	return ret
+}
+
 func roomDBToAPI(room db.Room) *api2.Room {
 	ret := &api2.Room{
 		Id: room.ID,
 		Config: &api2.Room_Config{
-			Name: room.Name,
-			Type: int32(room.Type),
+			Name:    room.Name,
+			Type:    roomTypeDBToAPI(room.Type),
+			FloorId: room.FloorID,
 		},
 	}
 
 	for _, device := range room.Devices {
 		ret.Devices = append(ret.Devices, deviceToAPI(device))
+
+		if len(device.Tags) > 0 || len(device.Aliases) > 0 {
+			if ret.DeviceMetadata == nil {
+				ret.DeviceMetadata = map[string]*api2.DeviceMetadata{}
+			}
+			ret.DeviceMetadata[device.ID] = deviceMetadataDBToAPI(device)
+		}
 	}
 
 	return ret
@@ -0,0 +1,176 @@
+package house
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/service/house/db"
+)
+
+// newTestDatabase creates an empty SQLite database for a test.
+func newTestDatabase(t *testing.T) *db.Database {
+	dsn := fmt.Sprintf("file:%s?parseTime=true&_foreign_keys=on", filepath.Join(t.TempDir(), "house.db"))
+	sqlDB, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	database, err := db.NewDatabase(zaptest.NewLogger(t), sqlDB)
+	require.NoError(t, err)
+	return database
+}
+
+func newTestService(t *testing.T) *Service {
+	return NewService(zaptest.NewLogger(t), newTestDatabase(t))
+}
+
+func TestGetBuildingNestsFloors(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	building, err := svc.CreateBuilding(ctx, &api2.CreateBuildingRequest{Config: &api2.Building_Config{Name: "Home"}})
+	require.NoError(t, err)
+	floor, err := svc.CreateFloor(ctx, &api2.CreateFloorRequest{BuildingId: building.Id, Config: &api2.Floor_Config{Name: "Upstairs", Level: 1}})
+	require.NoError(t, err)
+	bedroom, err := svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: building.Id, Config: &api2.Room_Config{Name: "Bedroom", FloorId: floor.Id}})
+	require.NoError(t, err)
+	porch, err := svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: building.Id, Config: &api2.Room_Config{Name: "Porch"}})
+	require.NoError(t, err)
+
+	res, err := svc.GetBuilding(ctx, &api2.GetBuildingRequest{Id: building.Id})
+	require.NoError(t, err)
+	require.Len(t, res.Floors, 1)
+	assert.Equal(t, "Upstairs", res.Floors[0].Config.Name)
+	require.Len(t, res.Floors[0].Rooms, 1)
+	assert.Equal(t, bedroom.Id, res.Floors[0].Rooms[0].Id)
+	require.Len(t, res.Rooms, 1)
+	assert.Equal(t, porch.Id, res.Rooms[0].Id)
+
+	// Rooms on a deleted floor are listed directly in the building.
+	_, err = svc.DeleteFloor(ctx, &api2.DeleteFloorRequest{Id: floor.Id})
+	require.NoError(t, err)
+	res, err = svc.GetBuilding(ctx, &api2.GetBuildingRequest{Id: building.Id})
+	require.NoError(t, err)
+	assert.Empty(t, res.Floors)
+	assert.Len(t, res.Rooms, 2)
+
+	_, err = svc.CreateFloor(ctx, &api2.CreateFloorRequest{BuildingId: "missing", Config: &api2.Floor_Config{Name: "Ground"}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestFloorWithoutConfig(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	home, err := svc.CreateBuilding(ctx, &api2.CreateBuildingRequest{Config: &api2.Building_Config{Name: "Home"}})
+	require.NoError(t, err)
+	_, err = svc.CreateFloor(ctx, &api2.CreateFloorRequest{BuildingId: home.Id})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	ground, err := svc.CreateFloor(ctx, &api2.CreateFloorRequest{BuildingId: home.Id, Config: &api2.Floor_Config{Name: "Ground"}})
+	require.NoError(t, err)
+	_, err = svc.UpdateFloor(ctx, &api2.UpdateFloorRequest{Id: ground.Id})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestRoomFloorInOtherBuilding(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	home, err := svc.CreateBuilding(ctx, &api2.CreateBuildingRequest{Config: &api2.Building_Config{Name: "Home"}})
+	require.NoError(t, err)
+	cottage, err := svc.CreateBuilding(ctx, &api2.CreateBuildingRequest{Config: &api2.Building_Config{Name: "Cottage"}})
+	require.NoError(t, err)
+	cottageFloor, err := svc.CreateFloor(ctx, &api2.CreateFloorRequest{BuildingId: cottage.Id, Config: &api2.Floor_Config{Name: "Ground"}})
+	require.NoError(t, err)
+
+	_, err = svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: home.Id, Config: &api2.Room_Config{Name: "Kitchen", FloorId: cottageFloor.Id}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: home.Id, Config: &api2.Room_Config{Name: "Kitchen", FloorId: "missing"}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	kitchen, err := svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: home.Id, Config: &api2.Room_Config{Name: "Kitchen"}})
+	require.NoError(t, err)
+	_, err = svc.UpdateRoom(ctx, &api2.UpdateRoomRequest{Id: kitchen.Id, Config: &api2.Room_Config{Name: "Kitchen", FloorId: cottageFloor.Id}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestRoomWithoutConfig(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	home, err := svc.CreateBuilding(ctx, &api2.CreateBuildingRequest{Config: &api2.Building_Config{Name: "Home"}})
+	require.NoError(t, err)
+	_, err = svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: home.Id})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	kitchen, err := svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: home.Id, Config: &api2.Room_Config{Name: "Kitchen"}})
+	require.NoError(t, err)
+	_, err = svc.UpdateRoom(ctx, &api2.UpdateRoomRequest{Id: kitchen.Id})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSearchDevicesByZone(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	building, err := svc.CreateBuilding(ctx, &api2.CreateBuildingRequest{Config: &api2.Building_Config{Name: "Home"}})
+	require.NoError(t, err)
+	bedroom, err := svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: building.Id, Config: &api2.Room_Config{Name: "Bedroom"}})
+	require.NoError(t, err)
+	kitchen, err := svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: building.Id, Config: &api2.Room_Config{Name: "Kitchen"}})
+	require.NoError(t, err)
+	zone, err := svc.CreateZone(ctx, &api2.CreateZoneRequest{Config: &api2.Zone_Config{Name: "Bedrooms", RoomIds: []string{bedroom.Id}}})
+	require.NoError(t, err)
+	_, err = svc.LinkDevice(ctx, &api2.LinkDeviceRequest{DeviceId: "lamp", RoomId: bedroom.Id})
+	require.NoError(t, err)
+	_, err = svc.LinkDevice(ctx, &api2.LinkDeviceRequest{DeviceId: "kettle", RoomId: kitchen.Id})
+	require.NoError(t, err)
+
+	res, err := svc.SearchDevices(ctx, &api2.SearchDevicesRequest{ZoneId: zone.Id})
+	require.NoError(t, err)
+	require.Len(t, res.Results, 1)
+	assert.Equal(t, "lamp", res.Results[0].Device.Id)
+	assert.Equal(t, bedroom.Id, res.Results[0].RoomId)
+
+	_, err = svc.SearchDevices(ctx, &api2.SearchDevicesRequest{ZoneId: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGetRoom(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	building, err := svc.CreateBuilding(ctx, &api2.CreateBuildingRequest{Config: &api2.Building_Config{Name: "Home"}})
+	require.NoError(t, err)
+	kitchen, err := svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: building.Id, Config: &api2.Room_Config{Name: "Kitchen", Type: api2.RoomType_ROOM_TYPE_KITCHEN}})
+	require.NoError(t, err)
+	_, err = svc.LinkDevice(ctx, &api2.LinkDeviceRequest{DeviceId: "kettle", RoomId: kitchen.Id})
+	require.NoError(t, err)
+
+	res, err := svc.GetRoom(ctx, &api2.GetRoomRequest{Id: kitchen.Id})
+	require.NoError(t, err)
+	assert.Equal(t, "Kitchen", res.Config.Name)
+	assert.Equal(t, api2.RoomType_ROOM_TYPE_KITCHEN, res.Config.Type)
+	require.Len(t, res.Devices, 1)
+	assert.Equal(t, "kettle", res.Devices[0].Id)
+
+	pantry, err := svc.CreateRoom(ctx, &api2.CreateRoomRequest{BuildingId: building.Id, Config: &api2.Room_Config{Name: "Pantry"}})
+	require.NoError(t, err)
+	res, err = svc.GetRoom(ctx, &api2.GetRoomRequest{Id: pantry.Id})
+	require.NoError(t, err)
+	assert.Equal(t, "Pantry", res.Config.Name)
+	assert.Empty(t, res.Devices)
+
+	_, err = svc.GetRoom(ctx, &api2.GetRoomRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,113 @@
+package house
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	api2 "github.com/rmrobinson/house/api"
+	"github.com/rmrobinson/house/service/house/db"
+)
+
+func (s *Service) ListZones(ctx context.Context, req *api2.ListZonesRequest) (*api2.ListZonesResponse, error) {
+	zones, err := s.db.GetZones(ctx)
+	if err != nil {
+		s.logger.Error("unable to get zones", zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get zones")
+	}
+
+	ret := &api2.ListZonesResponse{}
+	for _, zone := range zones {
+		ret.Zones = append(ret.Zones, zoneDBToAPI(zone))
+	}
+	return ret, nil
+}
+
+func (s *Service) GetZone(ctx context.Context, req *api2.GetZoneRequest) (*api2.Zone, error) {
+	zone, err := s.db.GetZone(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("unable to get zone", zap.String("zone_id", req.Id), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get zone")
+	} else if zone == nil {
+		return nil, status.Error(codes.NotFound, "zone doesn't exist")
+	}
+
+	rooms, err := s.db.GetZoneRooms(ctx, req.Id)
+	if err != nil {
+		s.logger.Error("unable to get zone rooms", zap.String("zone_id", req.Id), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unable to get zone rooms")
+	}
+
+	ret := zoneDBToAPI(*zone)
+	for _, room := range rooms {
+		ret.Rooms = append(ret.Rooms, roomDBToAPI(room))
+	}
+	return ret, nil
+}
+
+func (s *Service) CreateZone(ctx context.Context, req *api2.CreateZoneRequest) (*api2.Zone, error) {
+	if req.Config == nil || len(req.Config.Name) < 1 {
+		return nil, status.Error(codes.InvalidArgument, "zone name is required")
+	}
+
+	zone, err := s.db.CreateZone(ctx, zoneConfigToDB(req.Config))
+	if err != nil {
+		s.logger.Error("unable to create zone", zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to create zone")
+	}
+	return zoneDBToAPI(*zone), nil
+}
+
+func (s *Service) UpdateZone(ctx context.Context, req *api2.UpdateZoneRequest) (*api2.Zone, error) {
+	if req.Config == nil || len(req.Config.Name) < 1 {
+		return nil, status.Error(codes.InvalidArgument, "zone name is required")
+	}
+
+	zone := zoneConfigToDB(req.Config)
+	zone.ID = req.Id
+
+	zone, err := s.db.UpdateZone(ctx, zone)
+	if err != nil {
+		s.logger.Error("unable to update zone", zap.String("zone_id", req.Id), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to update zone")
+	}
+	return zoneDBToAPI(*zone), nil
+}
+
+func (s *Service) DeleteZone(ctx context.Context, req *api2.DeleteZoneRequest) (*emptypb.Empty, error) {
+	if err := s.db.DeleteZone(ctx, req.Id); err != nil {
+		s.logger.Error("unable to delete zone", zap.String("zone_id", req.Id), zap.Error(err))
+		return nil, dbErrorToStatus(err, "unable to delete zone")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// zoneConfigToDB converts the supplied config, dropping any duplicated room IDs.
+func zoneConfigToDB(config *api2.Zone_Config) *db.Zone {
+	zone := &db.Zone{
+		Name: config.Name,
+	}
+
+	seen := map[string]bool{}
+	for _, roomID := range config.RoomIds {
+		if seen[roomID] {
+			continue
+		}
+		seen[roomID] = true
+		zone.RoomIDs = append(zone.RoomIDs, roomID)
+	}
+	return zone
+}
+
+func zoneDBToAPI(zone db.Zone) *api2.Zone {
+	return &api2.Zone{
+		Id: zone.ID,
+		Config: &api2.Zone_Config{
+			Name:    zone.Name,
+			RoomIds: zone.RoomIDs,
+		},
+	}
+}
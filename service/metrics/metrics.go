@@ -0,0 +1,106 @@
+// Package metrics exposes Prometheus metrics describing the house and bridge servers, and optionally the state of
+// the devices they manage, over HTTP.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Namespace prefixes the name of every metric exported by the house.
+const Namespace = "house"
+
+// shutdownTimeout is how long in-flight scrapes are given to complete once the server is stopping.
+const shutdownTimeout = 5 * time.Second
+
+// Config contains the metrics settings, read from the 'metrics' key of the bridge config.
+type Config struct {
+	// Address is where the metrics are served, i.e. ":9100". Metrics aren't served if it is empty.
+	Address string `mapstructure:"address"`
+	// Traits exports the numeric trait values of devices, such as their temperature, as gauges labelled by device.
+	Traits bool `mapstructure:"traits"`
+}
+
+var (
+	rpcsHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "grpc",
+		Name:      "server_handled_total",
+		Help:      "Number of RPCs completed by the server, by method and status code.",
+	}, []string{"method", "code"})
+	rpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "grpc",
+		Name:      "server_handling_seconds",
+		Help:      "Time taken to complete unary RPCs, by method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+)
+
+// ServerOptions returns the options needed for a gRPC server to record the count and latency of every RPC.
+// They should be applied before any other interceptors so that rejected requests are also recorded.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor()),
+	}
+}
+
+// UnaryServerInterceptor records the status code and latency of unary requests.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err).String()
+		rpcsHandled.WithLabelValues(info.FullMethod, code).Inc()
+		rpcDuration.WithLabelValues(info.FullMethod, code).Observe(time.Since(start).Seconds())
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the status code of streaming requests.
+// Their latency isn't recorded as streams such as StreamUpdates are held open for as long as the client is connected.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+
+		rpcsHandled.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+		return err
+	}
+}
+
+// Serve exposes the metrics at /metrics on the supplied address until the context is cancelled.
+func Serve(ctx context.Context, logger *zap.Logger, address string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("serving metrics", zap.String("address", address))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
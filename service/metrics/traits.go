@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/rmrobinson/house/api/device"
+	"github.com/rmrobinson/house/api/trait"
+)
+
+// traitLabels identify the device, and the trait within it, that a value was reported by. A device may have more than
+// one trait of the same type; an EV charger reports both its wall_power and vehicle_power.
+var traitLabels = []string{"device_id", "name", "trait"}
+
+var (
+	temperature = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "device",
+		Name:      "temperature_celsius",
+		Help:      "Temperature measured by the device.",
+	}, traitLabels)
+	radon = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "device",
+		Name:      "radon_becquerels_per_cubic_metre",
+		Help:      "Radon concentration measured by the device.",
+	}, traitLabels)
+	power = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "device",
+		Name:      "power_watts",
+		Help:      "Power measured by the device.",
+	}, traitLabels)
+	battery = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "device",
+		Name:      "battery_percent",
+		Help:      "Battery capacity remaining in the device.",
+	}, traitLabels)
+
+	traitGauges = []*prometheus.GaugeVec{temperature, radon, power, battery}
+)
+
+// deviceDetails is the oneof holding the traits of a device.
+var deviceDetails = (&device.Device{}).ProtoReflect().Descriptor().Oneofs().ByName("details")
+
+// RecordDevice sets the trait gauges to the values reported by the supplied device, replacing any previously
+// recorded for it. Only traits which hold a state are recorded.
+func RecordDevice(d *device.Device) {
+	ForgetDevice(d.GetId())
+
+	field := d.ProtoReflect().WhichOneof(deviceDetails)
+	if field == nil {
+		return
+	}
+
+	d.ProtoReflect().Get(field).Message().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return true
+		}
+
+		labels := prometheus.Labels{
+			"device_id": d.GetId(),
+			"name":      d.GetConfig().GetName(),
+			"trait":     string(fd.Name()),
+		}
+		switch t := v.Message().Interface().(type) {
+		case *trait.AirProperties:
+			if t.State != nil {
+				temperature.With(labels).Set(float64(t.State.TemperatureC))
+			}
+		case *trait.AirQuality:
+			if t.State != nil && t.State.RadonBqM3 != nil {
+				radon.With(labels).Set(float64(*t.State.RadonBqM3))
+			}
+		case *trait.Power:
+			if t.State != nil {
+				power.With(labels).Set(t.State.PowerW)
+			}
+		case *trait.Battery:
+			if t.State != nil {
+				battery.With(labels).Set(float64(t.State.CapacityRemainingPct))
+			}
+		}
+		return true
+	})
+}
+
+// ForgetDevice removes any trait gauges recorded for the specified device.
+func ForgetDevice(id string) {
+	for _, gauge := range traitGauges {
+		gauge.DeletePartialMatch(prometheus.Labels{"device_id": id})
+	}
+}
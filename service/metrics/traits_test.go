@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/rmrobinson/house/api/device"
+	"github.com/rmrobinson/house/api/trait"
+)
+
+func TestRecordDevice(t *testing.T) {
+	d := &device.Device{
+		Id:     "charger",
+		Config: &device.Device_Config{Name: "Garage"},
+		Details: &device.Device_EvCharger{
+			EvCharger: &device.EVCharger{
+				WallPower:    &trait.Power{State: &trait.Power_State{PowerW: 7200}},
+				VehiclePower: &trait.Power{State: &trait.Power_State{PowerW: 7000}},
+				ExteriorConditions: &trait.AirProperties{
+					State: &trait.AirProperties_State{TemperatureC: 21.5},
+				},
+			},
+		},
+	}
+	s := &device.Device{
+		Id: "sensor",
+		Details: &device.Device_Sensor{
+			Sensor: &device.Sensor{
+				AirQuality: &trait.AirQuality{State: &trait.AirQuality_State{RadonBqM3: proto.Int32(40)}},
+				// A trait without a state isn't recorded.
+				Battery: &trait.Battery{},
+			},
+		},
+	}
+
+	RecordDevice(d)
+	RecordDevice(s)
+	assert.Equal(t, 7200.0, testutil.ToFloat64(power.WithLabelValues("charger", "Garage", "wall_power")))
+	assert.Equal(t, 7000.0, testutil.ToFloat64(power.WithLabelValues("charger", "Garage", "vehicle_power")))
+	assert.Equal(t, 21.5, testutil.ToFloat64(temperature.WithLabelValues("charger", "Garage", "exterior_conditions")))
+	assert.Equal(t, 40.0, testutil.ToFloat64(radon.WithLabelValues("sensor", "", "air_quality")))
+	assert.Equal(t, 0, testutil.CollectAndCount(battery))
+
+	// Renaming the device replaces its series.
+	d.Config.Name = "Carport"
+	RecordDevice(d)
+	assert.Equal(t, 3, testutil.CollectAndCount(temperature)+testutil.CollectAndCount(power))
+
+	ForgetDevice("charger")
+	assert.Equal(t, 0, testutil.CollectAndCount(power))
+	assert.Equal(t, 1, testutil.CollectAndCount(radon))
+}
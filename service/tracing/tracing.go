@@ -0,0 +1,115 @@
+// Package tracing exports OpenTelemetry traces from the house and bridge servers, so the time taken by a request can
+// be followed from the house, across the gRPC hop to a bridge, and into the calls the bridge makes to its devices.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// ErrMultipleExporters is returned if both an endpoint and a file are configured.
+var ErrMultipleExporters = errors.New("only one of tracing.endpoint and tracing.file may be set")
+
+// Config contains the tracing settings, read from the 'tracing' key of the bridge config.
+// Traces aren't exported if neither the endpoint nor the file are set.
+type Config struct {
+	// Endpoint is the address of an OpenTelemetry collector accepting OTLP over gRPC, i.e. "localhost:4317".
+	// The connection isn't encrypted as the collector is expected to be running locally.
+	Endpoint string `mapstructure:"endpoint"`
+	// File is where spans are written as JSON, for debugging without a collector.
+	File string `mapstructure:"file"`
+}
+
+// Setup configures the global tracer provider to export spans using the supplied config, identifying them with the
+// supplied service name. The returned function flushes any spans which haven't yet been exported, and must be
+// called before exiting.
+func Setup(ctx context.Context, logger *zap.Logger, serviceName string, config Config) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	var err error
+
+	switch {
+	case len(config.Endpoint) > 0 && len(config.File) > 0:
+		return nil, ErrMultipleExporters
+	case len(config.Endpoint) > 0:
+		exporter, err = otlptracegrpc.New(ctx,
+			otlptracegrpc.WithEndpoint(config.Endpoint),
+			otlptracegrpc.WithInsecure(),
+		)
+	case len(config.File) > 0:
+		file, err = os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err == nil {
+			exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		}
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	logger.Info("exporting traces", zap.String("endpoint", config.Endpoint), zap.String("file", config.File))
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			file.Close()
+		}
+		return err
+	}, nil
+}
+
+// ServerOptions returns the options needed for a gRPC server to continue the trace of each request, or start a new
+// one if the caller isn't tracing. Health checks aren't traced as they are made frequently by monitoring systems.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler(
+			otelgrpc.WithFilter(filters.Not(filters.HealthCheck())),
+		)),
+	}
+}
+
+// DialOption returns the option needed for a gRPC client to propagate the trace of the current request.
+func DialOption() grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler())
+}
+
+// Transport wraps the supplied round tripper to trace the requests it makes. If nil, the default transport is used.
+// Requests must be created with a context for their spans to be part of the calling trace.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base)
+}